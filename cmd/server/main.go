@@ -1,13 +1,21 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
 	"github.com/sodiqit/metricpulse.git/internal/server/infra/http"
+	"github.com/sodiqit/metricpulse.git/internal/server/infra/migrate"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate()
+		return
+	}
+
 	cfg := config.ParseConfig()
 
 	err := http.RunServer(cfg)
@@ -15,3 +23,15 @@ func main() {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// runMigrate handles `server migrate [flags] up | down [steps] | version`
+func runMigrate() {
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+
+	cfg := config.ParseConfig()
+
+	err := migrate.Run(cfg, flag.Args())
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
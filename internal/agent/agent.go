@@ -2,12 +2,21 @@ package agent
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
+	"github.com/sodiqit/metricpulse.git/pkg/tlsconfig"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
 )
 
 type Reporter interface {
@@ -21,6 +30,7 @@ type Collector interface {
 type Scope interface {
 	Counter(name string) Counter
 	Gauge(name string) Gauge
+	Histogram(name string) Histogram
 	Snapshot() MetricSnapshot
 }
 
@@ -42,13 +52,61 @@ func (a *Agent) Run() error {
 
 	client := resty.New()
 
+	var tlsConfig *tls.Config
+
+	if a.config.TLS {
+		tlsConfig, err = tlsconfig.NewClientConfig(tlsconfig.ClientOptions{
+			CAFile:   a.config.TLSCA,
+			CertFile: a.config.TLSCert,
+			KeyFile:  a.config.TLSKey,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		client.SetTLSClientConfig(tlsConfig)
+	}
+
 	var s signer.Signer
 
-	if a.config.SecretKey != "" {
+	switch {
+	case a.config.SecretKey != "" && a.config.KeyID != "":
+		// server finds the key in its keyring by id
+		s, err = signer.NewKeyring(signer.Key{ID: a.config.KeyID, Secret: a.config.SecretKey})
+
+		if err != nil {
+			return err
+		}
+	case a.config.SecretKey != "":
 		s = signer.NewSHA256Signer(a.config.SecretKey)
 	}
 
-	scope := NewRootScope()
+	var encryptor encryption.Encryptor
+
+	if a.config.CryptoKey != "" {
+		if a.config.GRPCAddress != "" {
+			return errors.New("payload encryption is supported only by http transport")
+		}
+
+		encryptor, err = encryption.LoadEncryptor(a.config.CryptoKey)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	scope := NewRootScope(a.config.HistogramBuckets...)
+
+	var spool *Spool
+
+	if a.config.SpoolDir != "" {
+		spool, err = NewSpool(a.config.SpoolDir, a.config.SpoolMaxSize, time.Duration(a.config.SpoolMaxAge)*time.Second)
+
+		if err != nil {
+			return err
+		}
+	}
 
 	reporterOptions := MetricReporterOptions{
 		ServerAddr:     a.config.Address,
@@ -56,8 +114,33 @@ func (a *Agent) Run() error {
 		Scope:          scope,
 		Client:         client,
 		Signer:         s,
+		Encryptor:      encryptor,
+		TLS:            tlsConfig != nil,
 		ReportInterval: time.Duration(a.config.ReportInterval) * time.Second,
 		RateLimit:      a.config.RateLimit,
+		Labels:         a.config.Labels,
+		Spool:          spool,
+	}
+
+	if a.config.GRPCAddress != "" {
+		creds := insecure.NewCredentials()
+
+		if tlsConfig != nil {
+			creds = credentials.NewTLS(tlsConfig)
+		}
+
+		conn, err := grpc.Dial(a.config.GRPCAddress,
+			grpc.WithTransportCredentials(creds),
+			grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)),
+		)
+
+		if err != nil {
+			return err
+		}
+
+		defer conn.Close()
+
+		reporterOptions.GRPCClient = pb.NewMetricsClient(conn)
 	}
 
 	reporter := NewMetricReporter(reporterOptions)
@@ -2,29 +2,122 @@ package agent
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/caarlos0/env/v10"
 )
 
 type Config struct {
-	Address        string `env:"ADDRESS"`
-	ReportInterval int    `env:"REPORT_INTERVAL"`
-	PollInterval   int    `env:"POLL_INTERVAL"`
-	LogLevel       string `env:"LOG_LEVEL"`
-	SecretKey      string `env:"KEY"`
-	RateLimit      int    `env:"RATE_LIMIT"`
+	Address          string   `env:"ADDRESS"`
+	GRPCAddress      string   `env:"GRPC_ADDRESS"`
+	ReportInterval   int      `env:"REPORT_INTERVAL"`
+	PollInterval     int      `env:"POLL_INTERVAL"`
+	LogLevel         string   `env:"LOG_LEVEL"`
+	SecretKey        string   `env:"KEY"`
+	KeyID            string   `env:"KEY_ID"`
+	CryptoKey        string   `env:"CRYPTO_KEY"`
+	TLS              bool     `env:"TLS"`
+	TLSCA            string   `env:"TLS_CA"`
+	TLSCert          string   `env:"TLS_CERT"`
+	TLSKey           string   `env:"TLS_KEY"`
+	RateLimit        int      `env:"RATE_LIMIT"`
+	Labels           Labels   `env:"LABELS" envKeyValSeparator:"="`
+	HistogramBuckets Float64s `env:"HISTOGRAM_BUCKETS"`
+	SpoolDir         string   `env:"SPOOL_DIR"`
+	SpoolMaxSize     int64    `env:"SPOOL_MAX_SIZE"`
+	SpoolMaxAge      int      `env:"SPOOL_MAX_AGE"`
+}
+
+// Labels статические метки агента в формате host=web01,env=prod, которые добавляются к каждой метрике
+type Labels map[string]string
+
+func (l Labels) String() string {
+	pairs := make([]string, 0, len(l))
+
+	for key, value := range l {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (l *Labels) Set(value string) error {
+	labels := make(Labels)
+
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+
+		if !ok || key == "" {
+			return fmt.Errorf("invalid label %q: expected key=value", pair)
+		}
+
+		labels[key] = val
+	}
+
+	*l = labels
+
+	return nil
+}
+
+// Float64s список чисел через запятую, например границы корзин гистограмм 0.1,0.5,1
+type Float64s []float64
+
+func (f Float64s) String() string {
+	values := make([]string, 0, len(f))
+
+	for _, v := range f {
+		values = append(values, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	return strings.Join(values, ",")
+}
+
+func (f *Float64s) Set(value string) error {
+	var values Float64s
+
+	for _, item := range strings.Split(value, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid number %q: %w", item, err)
+		}
+
+		if len(values) > 0 && v <= values[len(values)-1] {
+			return fmt.Errorf("values must be in increasing order: %s", value)
+		}
+
+		values = append(values, v)
+	}
+
+	*f = values
+
+	return nil
 }
 
 func ParseConfig() *Config {
 	var cfg Config
 
 	flag.StringVar(&cfg.Address, "a", "localhost:8080", "address and port server")
+	flag.StringVar(&cfg.GRPCAddress, "g", "", "address and port server grpc: provide if want send metrics over grpc instead of http")
 	flag.IntVar(&cfg.ReportInterval, "r", 10, "report interval in seconds")
 	flag.IntVar(&cfg.PollInterval, "p", 2, "poll runtime interval in seconds")
 	flag.StringVar(&cfg.LogLevel, "l", "info", "log level")
 	flag.StringVar(&cfg.SecretKey, "k", "", "key for data encryption")
+	flag.StringVar(&cfg.KeyID, "kid", "", "id of key from -k in server keyring, sent with signature: provide empty if server has single key")
+	flag.StringVar(&cfg.CryptoKey, "crypto-key", "", "path to server public key pem for encrypting payloads: provide empty if want disable encryption")
+	flag.BoolVar(&cfg.TLS, "tls", false, "connect to server over tls, enabled if any tls file is provided")
+	flag.StringVar(&cfg.TLSCA, "tls-ca", "", "path to ca bundle for verifying server certificate: provide empty if want use system pool")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "path to client certificate pem for mutual tls, reloaded on change")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "path to client certificate key pem")
 	flag.IntVar(&cfg.RateLimit, "rl", 5, "max concurrent request for server")
+	flag.StringVar(&cfg.SpoolDir, "sd", "", "directory for batches not delivered to server: provide empty if want disable spool")
+	flag.Int64Var(&cfg.SpoolMaxSize, "ss", 64<<20, "max spool size in bytes, oldest batches are dropped when exceeded")
+	flag.IntVar(&cfg.SpoolMaxAge, "sa", 3600, "max age of spooled batch in seconds")
+	flag.Var(&cfg.HistogramBuckets, "hb", "histogram bucket boundaries in increasing order, e.g. 0.1,0.5,1")
+	flag.Var(&cfg.Labels, "labels", "static labels for every metric, e.g. host=web01,env=prod")
 
 	flag.Parse()
 
@@ -32,5 +125,9 @@ func ParseConfig() *Config {
 		log.Fatal(err)
 	}
 
+	if cfg.TLSCA != "" || cfg.TLSCert != "" {
+		cfg.TLS = true
+	}
+
 	return &cfg
 }
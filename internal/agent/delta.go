@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+)
+
+// reportDelta counter and histogram increments included in a single report
+type reportDelta struct {
+	counters   map[string]int64
+	histograms map[string]entities.Histogram
+}
+
+// deltaTracker remembers cumulative values already included in reports, so the server,
+// which adds every received counter to its total, gets only increments since the last report.
+// Increments of a failed report are rolled back and carried into the next one
+type deltaTracker struct {
+	m sync.Mutex
+
+	counters   map[string]int64
+	histograms map[string]entities.Histogram
+}
+
+func newDeltaTracker() *deltaTracker {
+	return &deltaTracker{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]entities.Histogram),
+	}
+}
+
+// take returns increments of snapshot values since previous reports and marks them as reported
+func (t *deltaTracker) take(snapshot MetricSnapshot) reportDelta {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	delta := reportDelta{
+		counters:   make(map[string]int64, len(snapshot.Counters)),
+		histograms: make(map[string]entities.Histogram, len(snapshot.Histograms)),
+	}
+
+	for name, counter := range snapshot.Counters {
+		val := counter.Value()
+
+		delta.counters[name] = val - t.counters[name]
+		t.counters[name] = val
+	}
+
+	for name, histogram := range snapshot.Histograms {
+		val := histogram.Value()
+
+		diff := val
+
+		if reported, ok := t.histograms[name]; ok {
+			// bounds of agent histogram never change, so subtraction can't fail
+			diff, _ = val.Sub(reported)
+		}
+
+		delta.histograms[name] = diff
+		t.histograms[name] = val
+	}
+
+	return delta
+}
+
+// retainedDelta returns increments of batch items which server didn't reject. Batch is saved all-or-nothing,
+// so they should be sent again. If rejected items are unknown nothing is retained
+func retainedDelta(delta reportDelta, metrics []entities.Metrics, rejected []int) reportDelta {
+	result := reportDelta{
+		counters:   make(map[string]int64),
+		histograms: make(map[string]entities.Histogram),
+	}
+
+	if len(rejected) == 0 {
+		return result
+	}
+
+	for name, val := range delta.counters {
+		result.counters[name] = val
+	}
+
+	for name, val := range delta.histograms {
+		result.histograms[name] = val
+	}
+
+	for _, i := range rejected {
+		if i < 0 || i >= len(metrics) {
+			continue
+		}
+
+		switch metrics[i].MType {
+		case constants.MetricTypeCounter:
+			delete(result.counters, metrics[i].ID)
+		case constants.MetricTypeHistogram:
+			delete(result.histograms, metrics[i].ID)
+		}
+	}
+
+	return result
+}
+
+// rollback returns increments of a not accepted report, so they are sent again with the next one
+func (t *deltaTracker) rollback(delta reportDelta) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	for name, val := range delta.counters {
+		t.counters[name] -= val
+	}
+
+	for name, val := range delta.histograms {
+		t.histograms[name], _ = t.histograms[name].Sub(val)
+	}
+}
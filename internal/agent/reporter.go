@@ -5,19 +5,24 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/sodiqit/metricpulse.git/internal/constants"
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
 	"golang.org/x/sync/errgroup"
 )
 
+var errServerError = errors.New("server error")
+
 type WorkerPool struct {
 	jobs chan func() error
 	size int
@@ -75,16 +80,26 @@ type MetricReporterOptions struct {
 	RateLimit      int
 	Logger         logger.ILogger
 	Signer         signer.Signer
+	// Encryptor encrypts gzipped body of http batch with server public key if provided
+	Encryptor encryption.Encryptor
+	// TLS sends batches to https url, Client must be configured with tls config
+	TLS    bool
+	Labels map[string]string
+	Spool  *Spool
+	// GRPCClient is used to send batches instead of http client if provided
+	GRPCClient pb.MetricsClient
 }
 
 type MetricReporter struct {
-	client         *resty.Client
+	transport      transport
 	scope          Scope
 	reportInterval time.Duration
 	rateLimit      int
 	logger         logger.ILogger
-	serverAddr     string
-	signer         signer.Signer
+	labels         map[string]string
+	tracker        *deltaTracker
+	spool          *Spool
+	spoolM         sync.Mutex
 }
 
 func (r *MetricReporter) ReportLoop(ctx context.Context) error {
@@ -116,15 +131,23 @@ func (r *MetricReporter) ReportLoop(ctx context.Context) error {
 func (r *MetricReporter) SendBatchMetrics(ctx context.Context, snapshot MetricSnapshot, backoff retry.Backoff) error {
 	var metricsList []entities.Metrics
 
-	for metricName, metricValue := range snapshot.Counters {
-		val := metricValue.Value()
-		metric := entities.Metrics{ID: metricName, MType: constants.MetricTypeCounter, Delta: &val}
+	delta := r.tracker.take(snapshot)
+
+	for metricName, metricValue := range delta.counters {
+		val := metricValue
+		metric := entities.Metrics{ID: metricName, MType: constants.MetricTypeCounter, Delta: &val, Labels: r.labels}
 		metricsList = append(metricsList, metric)
 	}
 
 	for metricName, metricValue := range snapshot.Gauges {
 		val := metricValue.Value()
-		metric := entities.Metrics{ID: metricName, MType: constants.MetricTypeGauge, Value: &val}
+		metric := entities.Metrics{ID: metricName, MType: constants.MetricTypeGauge, Value: &val, Labels: r.labels}
+		metricsList = append(metricsList, metric)
+	}
+
+	for metricName, metricValue := range delta.histograms {
+		val := metricValue
+		metric := entities.Metrics{ID: metricName, MType: constants.MetricTypeHistogram, Histogram: &val, Labels: r.labels}
 		metricsList = append(metricsList, metric)
 	}
 
@@ -132,55 +155,139 @@ func (r *MetricReporter) SendBatchMetrics(ctx context.Context, snapshot MetricSn
 		return nil
 	}
 
-	buf, err := wrapBodyInGzip(metricsList)
+	body, err := r.transport.encode(metricsList)
 	if err != nil {
-		r.logger.Errorw("error while wrapping body in gzip", "error", err)
+		r.tracker.rollback(delta)
+		r.logger.Errorw("error while encoding metrics batch", "error", err)
 		return err
 	}
 
-	// Отправка сжатого списка метрик
-	url := fmt.Sprintf("http://%s/updates/", r.serverAddr)
+	// server is unavailable while spool is not drained, keep batches order
+	if r.spool != nil {
+		if err := r.replaySpool(ctx); err != nil {
+			return r.spoolBatch(body, err)
+		}
+	}
 
-	resp, err := retry.DoWithData(ctx, backoff, func(ctx context.Context) (*resty.Response, error) {
-		r.logger.Infow("try send metric on server")
+	err = r.send(ctx, body, backoff)
+
+	// rejected batch will be rejected on every replay, so it is not spooled
+	if err != nil && r.spool != nil && !errors.Is(err, errBatchRejected) {
+		return r.spoolBatch(body, err)
+	}
+
+	var rejected *batchRejectedError
+
+	// increments of rejected items would be rejected in every next batch, so only others are sent again
+	if errors.As(err, &rejected) {
+		r.tracker.rollback(retainedDelta(delta, metricsList, rejected.items))
+		r.logger.Errorw("metrics batch rejected, increments of rejected metrics dropped", "error", err, "rejected", len(rejected.items))
 
-		req := r.client.R().
-			SetHeader("Content-Type", "application/json").
-			SetHeader("Content-Encoding", "gzip")
+		return nil
+	}
 
-		resp, err := signRequest(buf.Bytes(), req, r.signer).Post(url)
+	// deltas are committed only when server accepted the batch
+	if err != nil {
+		r.tracker.rollback(delta)
+		r.logger.Errorw("error while sending metrics batch", "error", err)
 
-		if err != nil || resp.StatusCode() >= 500 {
-			return resp, retry.RetryableError(err)
+		if errors.Is(err, errBatchRejected) || errors.Is(err, errServerError) {
+			return nil
 		}
 
-		return resp, err
+		return err
+	}
+
+	return nil
+}
+
+// send delivers encoded batch to the server. Transport errors and server errors are retried with backoff
+func (r *MetricReporter) send(ctx context.Context, body []byte, backoff retry.Backoff) error {
+	return retry.Do(ctx, backoff, func(ctx context.Context) error {
+		r.logger.Infow("try send metric on server")
+
+		return r.transport.send(ctx, body)
 	})
+}
+
+// spoolBatch saves batch which server didn't accept. Its deltas stay committed: spool delivers them later
+func (r *MetricReporter) spoolBatch(body []byte, reason error) error {
+	r.spoolM.Lock()
+	defer r.spoolM.Unlock()
+
+	dropped, err := r.spool.Push(body, r.transport.format())
 
 	if err != nil {
-		r.logger.Errorw("error while sending metrics batch", "error", err)
+		r.logger.Errorw("error while saving metrics batch in spool", "error", err)
 		return err
 	}
 
-	if resp.StatusCode() >= http.StatusBadRequest {
-		r.logger.Errorw("error while sending metrics batch", "error", resp.String())
-		return nil
+	if dropped > 0 {
+		r.logger.Warnw("spool is full, oldest batches dropped", "dropped", dropped)
 	}
 
-	r.logger.Infow("success sending metrics batch", "result", resp.String())
+	r.logger.Warnw("server unavailable, metrics batch saved in spool", "reason", reason, "spooled", r.spool.Len())
 
 	return nil
 }
 
+// replaySpool sends spooled batches in order. Returns error if server is still unavailable
+func (r *MetricReporter) replaySpool(ctx context.Context) error {
+	r.spoolM.Lock()
+	defer r.spoolM.Unlock()
+
+	for {
+		entry, ok, err := r.spool.Peek()
+
+		if err != nil || !ok {
+			return err
+		}
+
+		// batch spooled by another transport, e.g. before switch to grpc or encryption, can't be sent
+		if entry.Format != "" && entry.Format != r.transport.format() {
+			r.logger.Errorw("spooled metrics batch dropped, it is encoded for another transport", "format", entry.Format, "transport", r.transport.format())
+		} else if err := r.send(ctx, entry.Data, retry.EmptyBackoff); errors.Is(err, errBatchRejected) || errors.Is(err, errUndecodableBatch) {
+			// such batch fails on every replay, so drop it
+			r.logger.Errorw("spooled metrics batch dropped", "error", err)
+		} else if err != nil {
+			return err
+		}
+
+		if err := r.spool.Remove(entry); err != nil {
+			return err
+		}
+	}
+}
+
 func NewMetricReporter(options MetricReporterOptions) *MetricReporter {
+	scheme := "http"
+
+	if options.TLS {
+		scheme = "https"
+	}
+
+	var t transport = &httpTransport{
+		client:     options.Client,
+		serverAddr: options.ServerAddr,
+		signer:     options.Signer,
+		encryptor:  options.Encryptor,
+		scheme:     scheme,
+		logger:     options.Logger,
+	}
+
+	if options.GRPCClient != nil {
+		t = &grpcTransport{client: options.GRPCClient, signer: options.Signer, logger: options.Logger}
+	}
+
 	return &MetricReporter{
-		client:         options.Client,
+		transport:      t,
 		scope:          options.Scope,
-		serverAddr:     options.ServerAddr,
 		reportInterval: options.ReportInterval,
 		rateLimit:      options.RateLimit,
 		logger:         options.Logger,
-		signer:         options.Signer,
+		labels:         options.Labels,
+		tracker:        newDeltaTracker(),
+		spool:          options.Spool,
 	}
 }
 
@@ -208,12 +315,21 @@ func wrapBodyInGzip(body interface{}) (*bytes.Buffer, error) {
 	return buf, nil
 }
 
-func signRequest(body []byte, r *resty.Request, signer signer.Signer) *resty.Request {
-	if signer == nil {
-		return r.SetBody(body)
+// signRequest signs body with new timestamp and nonce, so server can reject replayed request
+func signRequest(body []byte, r *resty.Request, s signer.Signer) (*resty.Request, error) {
+	if s == nil {
+		return r.SetBody(body), nil
 	}
 
-	signature := signer.Sign(body)
+	stamp, err := signer.NewStamp(time.Now())
+
+	if err != nil {
+		return nil, err
+	}
 
-	return r.SetHeader(constants.HashHeader, signature).SetBody(body)
+	return r.
+		SetHeader(constants.HashHeader, s.Sign(stamp.Material(body))).
+		SetHeader(constants.TimestampHeader, stamp.TimestampString()).
+		SetHeader(constants.NonceHeader, stamp.Nonce).
+		SetBody(body), nil
 }
@@ -1,20 +1,38 @@
 package agent_test
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/jarcoal/httpmock"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/sodiqit/metricpulse.git/internal/agent"
 	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/grpc/interceptors"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
 	"github.com/stretchr/testify/assert"
@@ -38,6 +56,7 @@ func TestMetricReporter_SendMetrics(t *testing.T) {
 	tests := []struct {
 		name               string
 		signer             signer.Signer
+		labels             map[string]string
 		expectResponseBody string
 		expectedCalls      int
 	}{
@@ -47,6 +66,13 @@ func TestMetricReporter_SendMetrics(t *testing.T) {
 			expectResponseBody: `[{"id":"TestCounter","type":"counter","delta":1},{"id":"TestGauge","type":"gauge","value":1}]`,
 			expectedCalls:      1,
 		},
+		{
+			name:               "Static labels attached to every metric",
+			signer:             signerMock,
+			labels:             map[string]string{"host": "web01", "env": "prod"},
+			expectResponseBody: `[{"id":"TestCounter","type":"counter","delta":1,"labels":{"env":"prod","host":"web01"}},{"id":"TestGauge","type":"gauge","value":1,"labels":{"env":"prod","host":"web01"}}]`,
+			expectedCalls:      1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -103,6 +129,7 @@ func TestMetricReporter_SendMetrics(t *testing.T) {
 				RateLimit:      2,
 				Logger:         logger,
 				Signer:         tt.signer,
+				Labels:         tt.labels,
 			}
 
 			r := agent.NewMetricReporter(options)
@@ -113,3 +140,553 @@ func TestMetricReporter_SendMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricReporter_SendCounterDeltas(t *testing.T) {
+	client := resty.New()
+
+	httpmock.ActivateNonDefault(client.GetClient())
+
+	defer httpmock.DeactivateAndReset()
+
+	mockURL := "http://localhost:8080/updates/"
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	scope := agent.NewRootScope()
+	counter := scope.Counter("PollCount")
+
+	r := agent.NewMetricReporter(agent.MetricReporterOptions{
+		ServerAddr: "localhost:8080",
+		Scope:      scope,
+		Client:     client,
+		RateLimit:  1,
+		Logger:     logger,
+	})
+
+	steps := []struct {
+		name          string
+		inc           int64
+		status        int
+		expectedDelta string
+	}{
+		{name: "first report sends whole value", inc: 2, status: 200, expectedDelta: `[{"id":"PollCount","type":"counter","delta":2}]`},
+		{name: "next report sends only increment", inc: 3, status: 200, expectedDelta: `[{"id":"PollCount","type":"counter","delta":3}]`},
+		{name: "rejected report", inc: 1, status: 500, expectedDelta: `[{"id":"PollCount","type":"counter","delta":1}]`},
+		{name: "rejected increment is carried into next report", inc: 4, status: 200, expectedDelta: `[{"id":"PollCount","type":"counter","delta":5}]`},
+		{name: "no increment since last report", inc: 0, status: 200, expectedDelta: `[{"id":"PollCount","type":"counter","delta":0}]`},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			httpmock.Reset()
+
+			httpmock.RegisterResponder("POST", mockURL, func(req *http.Request) (*http.Response, error) {
+				data, err := gzip.NewReader(req.Body)
+				require.NoError(t, err)
+
+				res, err := io.ReadAll(data)
+				require.NoError(t, err)
+
+				require.JSONEq(t, step.expectedDelta, string(res))
+				return httpmock.NewStringResponse(step.status, ""), nil
+			})
+
+			counter.Inc(step.inc)
+
+			r.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+func TestMetricReporter_SendAfterRejectedBatch(t *testing.T) {
+	client := resty.New()
+
+	httpmock.ActivateNonDefault(client.GetClient())
+
+	defer httpmock.DeactivateAndReset()
+
+	mockURL := "http://localhost:8080/updates/"
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	scope := agent.NewRootScope()
+	good := scope.Counter("Good")
+	bad := scope.Counter("Bad")
+
+	r := agent.NewMetricReporter(agent.MetricReporterOptions{
+		ServerAddr: "localhost:8080",
+		Scope:      scope,
+		Client:     client,
+		RateLimit:  1,
+		Logger:     logger,
+	})
+
+	steps := []struct {
+		name     string
+		inc      int64
+		status   int
+		reject   string
+		expected map[string]int64
+	}{
+		{name: "server rejects one metric of batch", inc: 1, status: 400, reject: "Bad", expected: map[string]int64{"Good": 1, "Bad": 1}},
+		{name: "increment of accepted metric is sent again, rejected one is dropped", inc: 2, status: 200, expected: map[string]int64{"Good": 3, "Bad": 2}},
+		{name: "server rejects batch without item results", inc: 4, status: 400, expected: map[string]int64{"Good": 4, "Bad": 4}},
+		{name: "increments of batch rejected without item results are dropped", inc: 5, status: 200, expected: map[string]int64{"Good": 5, "Bad": 5}},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			httpmock.Reset()
+
+			httpmock.RegisterResponder("POST", mockURL, func(req *http.Request) (*http.Response, error) {
+				data, err := gzip.NewReader(req.Body)
+				require.NoError(t, err)
+
+				var metrics []entities.Metrics
+				require.NoError(t, json.NewDecoder(data).Decode(&metrics))
+
+				deltas := make(map[string]int64)
+				results := make([]map[string]string, len(metrics))
+
+				for i, metric := range metrics {
+					deltas[metric.ID] = *metric.Delta
+					results[i] = map[string]string{"id": metric.ID, "type": metric.MType}
+
+					if metric.ID == step.reject {
+						results[i]["error"] = "counter overflow"
+					}
+				}
+
+				assert.Equal(t, step.expected, deltas)
+
+				if step.status == 400 && step.reject == "" {
+					return httpmock.NewStringResponse(step.status, "invalid batch"), nil
+				}
+
+				return httpmock.NewJsonResponse(step.status, results)
+			})
+
+			good.Inc(step.inc)
+			bad.Inc(step.inc)
+
+			err := r.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+			require.NoError(t, err)
+
+			assert.Equal(t, 1, httpmock.GetTotalCallCount())
+		})
+	}
+}
+
+func TestMetricReporter_SendEncrypted(t *testing.T) {
+	client := resty.New()
+
+	httpmock.ActivateNonDefault(client.GetClient())
+
+	defer httpmock.DeactivateAndReset()
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	decryptor := encryption.NewRSADecryptor(key)
+	sha256Signer := signer.NewSHA256Signer("test")
+
+	scope := agent.NewRootScope()
+	scope.Counter("PollCount").Inc(2)
+
+	r := agent.NewMetricReporter(agent.MetricReporterOptions{
+		ServerAddr: "localhost:8080",
+		Scope:      scope,
+		Client:     client,
+		RateLimit:  1,
+		Logger:     logger,
+		Signer:     sha256Signer,
+		Encryptor:  encryption.NewRSAEncryptor(&key.PublicKey),
+	})
+
+	httpmock.RegisterResponder("POST", "http://localhost:8080/updates/", func(req *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		stamp, err := signer.ParseStamp(req.Header.Get(constants.TimestampHeader), req.Header.Get(constants.NonceHeader))
+		require.NoError(t, err)
+
+		// signature is calculated over encrypted body
+		assert.Equal(t, sha256Signer.Sign(stamp.Material(body)), req.Header.Get(constants.HashHeader))
+		assert.Equal(t, encryption.SchemeRSA, req.Header.Get(constants.EncryptionHeader))
+
+		plain, err := decryptor.Decrypt(body)
+		require.NoError(t, err)
+
+		data, err := gzip.NewReader(bytes.NewReader(plain))
+		require.NoError(t, err)
+
+		res, err := io.ReadAll(data)
+		require.NoError(t, err)
+
+		require.JSONEq(t, `[{"id":"PollCount","type":"counter","delta":2}]`, string(res))
+		return httpmock.NewStringResponse(200, ""), nil
+	})
+
+	err = r.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+// batchServer records batches received by UpdateBatch and answers with code
+type batchServer struct {
+	pb.UnimplementedMetricsServer
+	code    codes.Code
+	batches [][]*pb.Metric
+}
+
+func (s *batchServer) UpdateBatch(stream pb.Metrics_UpdateBatchServer) error {
+	var metrics []*pb.Metric
+
+	for {
+		req, err := stream.Recv()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		metrics = append(metrics, req.GetMetrics()...)
+	}
+
+	if s.code != codes.OK {
+		return status.Error(s.code, "rejected")
+	}
+
+	s.batches = append(s.batches, metrics)
+
+	return stream.SendAndClose(&pb.UpdateBatchResponse{Metrics: metrics})
+}
+
+func TestMetricReporter_SendGRPC(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+
+	server := &batchServer{}
+
+	sha256Signer := signer.NewSHA256Signer("test")
+
+	// batches are signed the same way as http ones
+	s := grpc.NewServer(
+		grpc.StreamInterceptor(interceptors.StreamSignValidator(sha256Signer, nil)),
+		grpc.StatsHandler(interceptors.PayloadRecorder{}),
+	)
+	pb.RegisterMetricsServer(s, server)
+
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	scope := agent.NewRootScope()
+	counter := scope.Counter("PollCount")
+
+	r := agent.NewMetricReporter(agent.MetricReporterOptions{
+		Scope:      scope,
+		RateLimit:  1,
+		Logger:     logger,
+		Labels:     map[string]string{"host": "web01"},
+		Signer:     sha256Signer,
+		GRPCClient: pb.NewMetricsClient(conn),
+	})
+
+	steps := []struct {
+		name          string
+		inc           int64
+		code          codes.Code
+		expectedDelta int64
+	}{
+		{name: "batch is streamed to server", inc: 2, code: codes.OK, expectedDelta: 2},
+		{name: "rejected batch", inc: 1, code: codes.InvalidArgument},
+		{name: "increment of batch rejected without item is dropped", inc: 3, code: codes.OK, expectedDelta: 3},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			server.batches = nil
+			server.code = step.code
+
+			counter.Inc(step.inc)
+
+			err := r.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+			require.NoError(t, err)
+
+			if step.code != codes.OK {
+				assert.Empty(t, server.batches)
+				return
+			}
+
+			require.Len(t, server.batches, 1)
+			require.Len(t, server.batches[0], 1)
+
+			metric := server.batches[0][0]
+			assert.Equal(t, "PollCount", metric.GetId())
+			assert.Equal(t, constants.MetricTypeCounter, metric.GetType())
+			assert.Equal(t, map[string]string{"host": "web01"}, metric.GetLabels())
+			assert.Equal(t, step.expectedDelta, metric.GetDelta())
+		})
+	}
+}
+
+func TestMetricReporter_SpoolBatchesDuringOutage(t *testing.T) {
+	client := resty.New()
+
+	httpmock.ActivateNonDefault(client.GetClient())
+
+	defer httpmock.DeactivateAndReset()
+
+	mockURL := "http://localhost:8080/updates/"
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	spool, err := agent.NewSpool(t.TempDir(), 0, time.Hour)
+	require.NoError(t, err)
+
+	scope := agent.NewRootScope()
+	counter := scope.Counter("PollCount")
+
+	r := agent.NewMetricReporter(agent.MetricReporterOptions{
+		ServerAddr: "localhost:8080",
+		Scope:      scope,
+		Client:     client,
+		RateLimit:  1,
+		Logger:     logger,
+		Spool:      spool,
+	})
+
+	var received []string
+
+	status := http.StatusInternalServerError
+
+	httpmock.RegisterResponder("POST", mockURL, func(req *http.Request) (*http.Response, error) {
+		data, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+
+		res, err := io.ReadAll(data)
+		require.NoError(t, err)
+
+		if status == http.StatusOK {
+			received = append(received, string(res))
+		}
+
+		return httpmock.NewStringResponse(status, ""), nil
+	})
+
+	for _, inc := range []int64{1, 2} {
+		counter.Inc(inc)
+
+		err = r.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, spool.Len())
+
+	status = http.StatusOK
+
+	counter.Inc(3)
+
+	err = r.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, spool.Len())
+	require.Len(t, received, 3)
+	assert.JSONEq(t, `[{"id":"PollCount","type":"counter","delta":1}]`, received[0])
+	assert.JSONEq(t, `[{"id":"PollCount","type":"counter","delta":2}]`, received[1])
+	assert.JSONEq(t, `[{"id":"PollCount","type":"counter","delta":3}]`, received[2])
+}
+
+func TestMetricReporter_SpoolTransportSwitch(t *testing.T) {
+	client := resty.New()
+
+	httpmock.ActivateNonDefault(client.GetClient())
+
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "http://localhost:8080/updates/", httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	spool, err := agent.NewSpool(dir, 0, time.Hour)
+	require.NoError(t, err)
+
+	scope := agent.NewRootScope()
+	counter := scope.Counter("PollCount")
+
+	httpReporter := agent.NewMetricReporter(agent.MetricReporterOptions{
+		ServerAddr: "localhost:8080",
+		Scope:      scope,
+		Client:     client,
+		RateLimit:  1,
+		Logger:     logger,
+		Spool:      spool,
+	})
+
+	counter.Inc(1)
+
+	err = httpReporter.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+	require.NoError(t, err)
+	require.Equal(t, 1, spool.Len())
+
+	// entry spooled by previous version has no format
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001-000001.batch"), []byte("\x1f\x8b legacy batch"), 0644))
+
+	spool, err = agent.NewSpool(dir, 0, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 2, spool.Len())
+
+	grpcClient, server := startBatchServer(t)
+
+	grpcReporter := agent.NewMetricReporter(agent.MetricReporterOptions{
+		Scope:      scope,
+		RateLimit:  1,
+		Logger:     logger,
+		Spool:      spool,
+		GRPCClient: grpcClient,
+	})
+
+	counter.Inc(2)
+
+	err = grpcReporter.SendBatchMetrics(context.Background(), scope.Snapshot(), retry.EmptyBackoff)
+	require.NoError(t, err)
+
+	// batches which grpc transport can't decode are dropped instead of blocking the spool
+	assert.Equal(t, 0, spool.Len())
+	require.Len(t, server.batches, 1)
+	// new reporter, like restarted agent, sends whole counter value
+	assert.Equal(t, int64(3), server.batches[0][0].GetDelta())
+}
+
+func startBatchServer(t *testing.T) (pb.MetricsClient, *batchServer) {
+	lis := bufconn.Listen(1 << 20)
+
+	server := &batchServer{}
+
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, server)
+
+	go s.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+
+	return pb.NewMetricsClient(conn), server
+}
+
+func TestSpool(t *testing.T) {
+	tests := []struct {
+		name  string
+		tBody func(t *testing.T)
+	}{
+		{
+			name: "should keep entries across restarts",
+			tBody: func(t *testing.T) {
+				dir := t.TempDir()
+
+				spool, err := agent.NewSpool(dir, 0, time.Hour)
+				require.NoError(t, err)
+
+				for _, data := range []string{"first", "second"} {
+					_, err = spool.Push([]byte(data), "json")
+					require.NoError(t, err)
+				}
+
+				restored, err := agent.NewSpool(dir, 0, time.Hour)
+				require.NoError(t, err)
+
+				for _, expected := range []string{"first", "second"} {
+					entry, ok, err := restored.Peek()
+					require.NoError(t, err)
+					require.True(t, ok)
+					assert.Equal(t, expected, string(entry.Data))
+
+					require.NoError(t, restored.Remove(entry))
+				}
+
+				_, ok, err := restored.Peek()
+				require.NoError(t, err)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "should drop oldest entries when max size exceeded",
+			tBody: func(t *testing.T) {
+				spool, err := agent.NewSpool(t.TempDir(), 10, time.Hour)
+				require.NoError(t, err)
+
+				for _, data := range []string{"aaaa", "bbbb", "cccc"} {
+					_, err = spool.Push([]byte(data), "json")
+					require.NoError(t, err)
+				}
+
+				assert.Equal(t, 2, spool.Len())
+
+				entry, ok, err := spool.Peek()
+				require.NoError(t, err)
+				require.True(t, ok)
+				assert.Equal(t, "bbbb", string(entry.Data))
+			},
+		},
+		{
+			name: "should expire entries older than max age",
+			tBody: func(t *testing.T) {
+				spool, err := agent.NewSpool(t.TempDir(), 0, time.Millisecond)
+				require.NoError(t, err)
+
+				_, err = spool.Push([]byte("old"), "json")
+				require.NoError(t, err)
+
+				time.Sleep(2 * time.Millisecond)
+
+				_, ok, err := spool.Peek()
+				require.NoError(t, err)
+				assert.False(t, ok)
+				assert.Equal(t, 0, spool.Len())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody(t)
+		})
+	}
+}
@@ -1,24 +1,41 @@
 package agent
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+)
 
 type MetricSnapshot struct {
-	Gauges   map[string]Gauge
-	Counters map[string]Counter
+	Gauges     map[string]Gauge
+	Counters   map[string]Counter
+	Histograms map[string]Histogram
 }
 
 type scope struct {
 	cm sync.Mutex
 	gm sync.Mutex
+	hm sync.Mutex
+
+	counters   map[string]*counter
+	gauges     map[string]*gauge
+	histograms map[string]*histogram
 
-	counters map[string]*counter
-	gauges   map[string]*gauge
+	histogramBounds []float64
 }
 
-func NewRootScope() *scope {
+// NewRootScope creates scope whose histograms use histogramBounds as bucket boundaries.
+// When no bounds are provided entities.DefaultHistogramBounds are used
+func NewRootScope(histogramBounds ...float64) *scope {
+	if len(histogramBounds) == 0 {
+		histogramBounds = entities.DefaultHistogramBounds
+	}
+
 	return &scope{
-		counters: make(map[string]*counter),
-		gauges:   make(map[string]*gauge),
+		counters:        make(map[string]*counter),
+		gauges:          make(map[string]*gauge),
+		histograms:      make(map[string]*histogram),
+		histogramBounds: histogramBounds,
 	}
 }
 
@@ -48,6 +65,19 @@ func (s *scope) Gauge(name string) Gauge {
 	return val
 }
 
+func (s *scope) Histogram(name string) Histogram {
+	s.hm.Lock()
+	defer s.hm.Unlock()
+	val, ok := s.histograms[name]
+
+	if !ok {
+		val = newHistogram(s.histogramBounds)
+		s.histograms[name] = val
+	}
+
+	return val
+}
+
 func (s *scope) Snapshot() MetricSnapshot {
 	s.cm.Lock()
 	countersSnapshot := make(map[string]Counter, len(s.counters))
@@ -63,8 +93,16 @@ func (s *scope) Snapshot() MetricSnapshot {
 	}
 	s.gm.Unlock()
 
+	s.hm.Lock()
+	histogramsSnapshot := make(map[string]Histogram, len(s.histograms))
+	for k, v := range s.histograms {
+		histogramsSnapshot[k] = v
+	}
+	s.hm.Unlock()
+
 	return MetricSnapshot{
-		Counters: countersSnapshot,
-		Gauges:   gaugesSnapshot,
+		Counters:   countersSnapshot,
+		Gauges:     gaugesSnapshot,
+		Histograms: histogramsSnapshot,
 	}
 }
@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolFileSuffix = ".batch"
+
+type SpoolEntry struct {
+	name      string
+	createdAt time.Time
+	size      int64
+	// Format is encoding of Data written by transport, empty for entries spooled by previous versions
+	Format string
+	Data   []byte
+}
+
+// Spool is a bounded directory-backed FIFO queue of report bodies that the server didn't accept.
+// Every entry is a separate file named by its creation time and format, so the queue survives agent restarts.
+// When the total size exceeds maxSize the oldest entries are dropped, entries older than maxAge are expired
+type Spool struct {
+	m sync.Mutex
+
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	seq     uint64
+	entries []SpoolEntry
+	size    int64
+}
+
+func NewSpool(dir string, maxSize int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{dir: dir, maxSize: maxSize, maxAge: maxAge}
+
+	files, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), spoolFileSuffix) {
+			continue
+		}
+
+		createdAt, format, err := parseSpoolFileName(file.Name())
+
+		if err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.entries = append(s.entries, SpoolEntry{name: file.Name(), createdAt: createdAt, size: info.Size(), Format: format})
+		s.size += info.Size()
+	}
+
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].name < s.entries[j].name
+	})
+
+	return s, nil
+}
+
+// Push appends data encoded in format to the end of the queue. Returns number of oldest entries dropped to fit maxSize
+func (s *Spool) Push(data []byte, format string) (int, error) {
+	if strings.ContainsAny(format, "./") {
+		return 0, fmt.Errorf("invalid spool entry format: %s", format)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	now := time.Now()
+	s.seq++
+
+	name := fmt.Sprintf("%020d-%06d.%s%s", now.UnixNano(), s.seq%1000000, format, spoolFileSuffix)
+	path := filepath.Join(s.dir, name)
+
+	// write via temp file, so a crash never leaves a partially written entry
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return 0, err
+	}
+
+	s.entries = append(s.entries, SpoolEntry{name: name, createdAt: now, size: int64(len(data)), Format: format})
+	s.size += int64(len(data))
+
+	dropped := 0
+
+	for s.maxSize > 0 && s.size > s.maxSize && len(s.entries) > 1 {
+		if err := s.removeFirst(); err != nil {
+			return dropped, err
+		}
+
+		dropped++
+	}
+
+	return dropped, nil
+}
+
+// Peek returns the oldest not expired entry. Expired entries are removed
+func (s *Spool) Peek() (SpoolEntry, bool, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for len(s.entries) > 0 {
+		entry := s.entries[0]
+
+		if s.maxAge > 0 && time.Since(entry.createdAt) > s.maxAge {
+			if err := s.removeFirst(); err != nil {
+				return SpoolEntry{}, false, err
+			}
+
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.name))
+
+		if err != nil {
+			return SpoolEntry{}, false, err
+		}
+
+		entry.Data = data
+
+		return entry, true, nil
+	}
+
+	return SpoolEntry{}, false, nil
+}
+
+// Remove deletes entry returned by Peek
+func (s *Spool) Remove(entry SpoolEntry) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if len(s.entries) == 0 || s.entries[0].name != entry.name {
+		return nil
+	}
+
+	return s.removeFirst()
+}
+
+func (s *Spool) Len() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return len(s.entries)
+}
+
+func (s *Spool) removeFirst() error {
+	entry := s.entries[0]
+
+	if err := os.Remove(filepath.Join(s.dir, entry.name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	s.entries = s.entries[1:]
+	s.size -= entry.size
+
+	return nil
+}
+
+// parseSpoolFileName parses <nanos>-<seq>.<format>.batch, previous versions didn't write format
+func parseSpoolFileName(name string) (time.Time, string, error) {
+	nanos, rest, ok := strings.Cut(strings.TrimSuffix(name, spoolFileSuffix), "-")
+
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("invalid spool file name: %s", name)
+	}
+
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	_, format, _ := strings.Cut(rest, ".")
+
+	return time.Unix(0, ts), format, nil
+}
@@ -2,7 +2,10 @@ package agent
 
 import (
 	"math"
+	"sync"
 	"sync/atomic"
+
+	"github.com/sodiqit/metricpulse.git/internal/entities"
 )
 
 type Counter interface {
@@ -15,6 +18,11 @@ type Gauge interface {
 	Value() float64
 }
 
+type Histogram interface {
+	Observe(value float64)
+	Value() entities.Histogram
+}
+
 type counter struct {
 	value int64
 }
@@ -46,3 +54,26 @@ func (g *gauge) Update(value float64) {
 func (g *gauge) Value() float64 {
 	return math.Float64frombits(g.floatBits)
 }
+
+type histogram struct {
+	m     sync.Mutex
+	value entities.Histogram
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{value: entities.NewHistogram(bounds)}
+}
+
+func (h *histogram) Observe(value float64) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.value.Observe(value)
+}
+
+func (h *histogram) Value() entities.Histogram {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	return h.value.Copy()
+}
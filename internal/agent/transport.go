@@ -0,0 +1,290 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
+	"github.com/sodiqit/metricpulse.git/pkg/retry"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+// grpcChunkSize is max number of metrics in one message of batch stream
+const grpcChunkSize = 500
+
+var (
+	errBatchRejected = errors.New("batch rejected by server")
+	// errUndecodableBatch is returned for spooled batch encoded by another transport, it can't be ever sent
+	errUndecodableBatch = errors.New("batch can't be decoded by transport")
+)
+
+// batchRejectedError is errBatchRejected with indexes of batch items which server rejected.
+// Items are empty if server didn't report them
+type batchRejectedError struct {
+	items  []int
+	reason string
+}
+
+func (e *batchRejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", errBatchRejected, e.reason)
+}
+
+func (e *batchRejectedError) Unwrap() error {
+	return errBatchRejected
+}
+
+// transport delivers metrics batch to the server. Batch is encoded once, so the same body
+// is replayed from spool. Spooled batches can be replayed only by transport which encoded them
+type transport interface {
+	// format identifies encoding of batches, spooled batch of another format can't be sent
+	format() string
+	encode(metrics []entities.Metrics) ([]byte, error)
+	// send returns errBatchRejected if server rejected the batch,
+	// retryable error if server or network is unavailable
+	send(ctx context.Context, body []byte) error
+}
+
+// httpTransport posts gzipped json batch to /updates/. Batch is encrypted after compression
+// and signature is calculated over encrypted body
+type httpTransport struct {
+	client     *resty.Client
+	scheme     string
+	serverAddr string
+	signer     signer.Signer
+	encryptor  encryption.Encryptor
+	logger     logger.ILogger
+}
+
+func (t *httpTransport) format() string {
+	if t.encryptor != nil {
+		return "json-" + t.encryptor.Scheme()
+	}
+
+	return "json"
+}
+
+func (t *httpTransport) encode(metrics []entities.Metrics) ([]byte, error) {
+	buf, err := wrapBodyInGzip(metrics)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.encryptor == nil {
+		return buf.Bytes(), nil
+	}
+
+	body, err := t.encryptor.Encrypt(buf.Bytes())
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt batch: %w", err)
+	}
+
+	return body, nil
+}
+
+func (t *httpTransport) send(ctx context.Context, body []byte) error {
+	// Отправка сжатого списка метрик
+	url := fmt.Sprintf("%s://%s/updates/", t.scheme, t.serverAddr)
+
+	req := t.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Encoding", "gzip")
+
+	if t.encryptor != nil {
+		req.SetHeader(constants.EncryptionHeader, t.encryptor.Scheme())
+	}
+
+	req, err := signRequest(body, req, t.signer)
+
+	if err != nil {
+		return fmt.Errorf("cannot sign batch: %w", err)
+	}
+
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return retry.RetryableError(err)
+	}
+
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		return retry.RetryableError(fmt.Errorf("%w: status %d", errServerError, resp.StatusCode()))
+	}
+
+	if !resp.IsSuccess() {
+		return &batchRejectedError{
+			items:  t.rejectedItems(resp),
+			reason: fmt.Sprintf("status %d: %s", resp.StatusCode(), resp.String()),
+		}
+	}
+
+	t.logger.Infow("success sending metrics batch", "result", resp.String())
+
+	return nil
+}
+
+// rejectedItems logs and returns indexes of batch items which server rejected. Server responds
+// with result of every item, rejected ones have error
+func (t *httpTransport) rejectedItems(resp *resty.Response) []int {
+	var results []struct {
+		entities.Metrics
+		Error string `json:"error"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &results); err != nil {
+		return nil
+	}
+
+	var items []int
+
+	for i, result := range results {
+		if result.Error != "" {
+			t.logger.Errorw("metric rejected by server", "id", result.ID, "type", result.MType, "labels", result.Labels, "error", result.Error)
+			items = append(items, i)
+		}
+	}
+
+	return items
+}
+
+// grpcTransport streams protobuf batch to UpdateBatch in chunks
+type grpcTransport struct {
+	client pb.MetricsClient
+	signer signer.Signer
+	logger logger.ILogger
+}
+
+func (t *grpcTransport) format() string {
+	return "proto"
+}
+
+func (t *grpcTransport) encode(metrics []entities.Metrics) ([]byte, error) {
+	req := &pb.UpdateBatchRequest{Metrics: make([]*pb.Metric, 0, len(metrics))}
+
+	for _, metric := range metrics {
+		req.Metrics = append(req.Metrics, pb.FromMetrics(metric))
+	}
+
+	return proto.Marshal(req)
+}
+
+func (t *grpcTransport) send(ctx context.Context, body []byte) error {
+	var batch pb.UpdateBatchRequest
+
+	if err := proto.Unmarshal(body, &batch); err != nil {
+		return fmt.Errorf("%w: %s", errUndecodableBatch, err)
+	}
+
+	resp, err := t.stream(ctx, batch.GetMetrics())
+
+	if err != nil {
+		return grpcError(err)
+	}
+
+	t.logger.Infow("success sending metrics batch", "metrics", len(resp.GetMetrics()))
+
+	return nil
+}
+
+func (t *grpcTransport) stream(ctx context.Context, metrics []*pb.Metric) (*pb.UpdateBatchResponse, error) {
+	var chunks []*pb.UpdateBatchRequest
+
+	for start := 0; start < len(metrics); start += grpcChunkSize {
+		end := start + grpcChunkSize
+
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+
+		chunks = append(chunks, &pb.UpdateBatchRequest{Metrics: metrics[start:end]})
+	}
+
+	ctx, err := signStream(ctx, t.signer, chunks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// chunks are sent encoded the same way as they were signed
+	stream, err := t.client.UpdateBatch(ctx, grpc.ForceCodec(pb.Codec{}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunk := range chunks {
+		// error of Send is returned by CloseAndRecv
+		if err := stream.Send(chunk); err != nil {
+			break
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
+
+// signStream passes signature of all stream messages with new timestamp and nonce in metadata,
+// server verifies it when stream is closed
+func signStream(ctx context.Context, s signer.Signer, chunks []*pb.UpdateBatchRequest) (context.Context, error) {
+	if s == nil {
+		return ctx, nil
+	}
+
+	stamp, err := signer.NewStamp(time.Now())
+
+	if err != nil {
+		return ctx, fmt.Errorf("cannot sign batch: %w", err)
+	}
+
+	var body []byte
+
+	for _, chunk := range chunks {
+		data, err := pb.Marshal(chunk)
+
+		if err != nil {
+			return ctx, fmt.Errorf("cannot sign batch: %w", err)
+		}
+
+		body = pb.AppendSignedBytes(body, data)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx,
+		constants.HashHeader, s.Sign(stamp.Material(body)),
+		constants.TimestampHeader, stamp.TimestampString(),
+		constants.NonceHeader, stamp.Nonce,
+	), nil
+}
+
+// grpcError maps status of failed call: invalid request is rejected, others mean server is unavailable
+func grpcError(err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.ResourceExhausted, codes.FailedPrecondition, codes.PermissionDenied, codes.Unauthenticated, codes.Unimplemented:
+		rejected := &batchRejectedError{reason: err.Error()}
+
+		// server reports rejected item as storage.ErrBatchItem
+		var item int
+
+		if _, scanErr := fmt.Sscanf(status.Convert(err).Message(), "batch item %d rejected", &item); scanErr == nil {
+			rejected.items = []int{item}
+		}
+
+		return rejected
+	default:
+		return retry.RetryableError(fmt.Errorf("%w: %s", errServerError, err))
+	}
+}
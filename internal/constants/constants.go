@@ -1,7 +1,11 @@
 package constants
 
 const (
-	MetricTypeGauge   = "gauge"
-	MetricTypeCounter = "counter"
-	HashHeader        = "HashSHA256"
+	MetricTypeGauge     = "gauge"
+	MetricTypeCounter   = "counter"
+	MetricTypeHistogram = "histogram"
+	HashHeader          = "HashSHA256"
+	EncryptionHeader    = "Content-Encryption"
+	TimestampHeader     = "X-Request-Timestamp"
+	NonceHeader         = "X-Request-Nonce"
 )
@@ -0,0 +1,113 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrHistogramBoundsMismatch = errors.New("histogram bounds mismatch")
+
+// DefaultHistogramBounds границы корзин по умолчанию, подходят для задержек запросов в секундах
+var DefaultHistogramBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram распределение наблюдений по корзинам.
+// Bounds — верхние границы корзин по возрастанию, Counts — число наблюдений в каждой корзине
+// (не накопительно), последний элемент Counts соответствует корзине +Inf
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int64   `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  int64     `json:"count"`
+}
+
+func NewHistogram(bounds []float64) Histogram {
+	return Histogram{
+		Bounds: append([]float64(nil), bounds...),
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe добавляет наблюдение в корзину с наименьшей границей, не меньшей v
+func (h *Histogram) Observe(v float64) {
+	h.ObserveN(v, 1)
+}
+
+// ObserveN добавляет n одинаковых наблюдений v, например сэмплированное значение с весом 1/rate
+func (h *Histogram) ObserveN(v float64, n int64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+
+	h.Counts[i] += n
+	h.Sum += v * float64(n)
+	h.Count += n
+}
+
+func (h Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram must have %d counts for %d bounds, got %d", len(h.Bounds)+1, len(h.Bounds), len(h.Counts))
+	}
+
+	for i := 1; i < len(h.Bounds); i++ {
+		if h.Bounds[i] <= h.Bounds[i-1] {
+			return errors.New("histogram bounds must be sorted in increasing order")
+		}
+	}
+
+	var total int64
+
+	for _, count := range h.Counts {
+		if count < 0 {
+			return errors.New("histogram counts must not be negative")
+		}
+
+		total += count
+	}
+
+	if total != h.Count {
+		return fmt.Errorf("histogram count %d does not match sum of bucket counts %d", h.Count, total)
+	}
+
+	return nil
+}
+
+// Merge возвращает новую гистограмму с суммой наблюдений обеих гистограмм. Границы корзин должны совпадать
+func (h Histogram) Merge(other Histogram) (Histogram, error) {
+	return h.combine(other, 1)
+}
+
+// Sub возвращает новую гистограмму с наблюдениями h, которых нет в other. Границы корзин должны совпадать
+func (h Histogram) Sub(other Histogram) (Histogram, error) {
+	return h.combine(other, -1)
+}
+
+func (h Histogram) combine(other Histogram, sign int64) (Histogram, error) {
+	if len(h.Bounds) != len(other.Bounds) || len(h.Counts) != len(other.Counts) {
+		return Histogram{}, ErrHistogramBoundsMismatch
+	}
+
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return Histogram{}, ErrHistogramBoundsMismatch
+		}
+	}
+
+	result := h.Copy()
+
+	for i := range result.Counts {
+		result.Counts[i] += sign * other.Counts[i]
+	}
+
+	result.Sum += float64(sign) * other.Sum
+	result.Count += sign * other.Count
+
+	return result, nil
+}
+
+func (h Histogram) Copy() Histogram {
+	return Histogram{
+		Bounds: append([]float64(nil), h.Bounds...),
+		Counts: append([]int64(nil), h.Counts...),
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
+}
@@ -1,13 +1,74 @@
 package entities
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+)
+
+var ErrCounterOverflow = errors.New("counter overflow")
+
 type Metrics struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	ID        string            `json:"id"`                  // имя метрики
+	MType     string            `json:"type"`                // параметр, принимающий значение gauge, counter или histogram
+	Delta     *int64            `json:"delta,omitempty"`     // значение метрики в случае передачи counter
+	Value     *float64          `json:"value,omitempty"`     // значение метрики в случае передачи gauge
+	Histogram *Histogram        `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Labels    map[string]string `json:"labels,omitempty"`    // метки серии, например host и env
+}
+
+// Validate проверяет, что у метрики есть имя, известный тип, значение этого типа и корректные метки
+func (m Metrics) Validate() error {
+	if m.ID == "" {
+		return errors.New("metric id not provided")
+	}
+
+	switch m.MType {
+	case constants.MetricTypeGauge:
+		if m.Value == nil {
+			return errors.New("metric value not provided: provide float64")
+		}
+	case constants.MetricTypeCounter:
+		if m.Delta == nil {
+			return errors.New("metric value not provided: provide int64")
+		}
+	case constants.MetricTypeHistogram:
+		if m.Histogram == nil {
+			return errors.New("metric value not provided: provide histogram with bounds, counts, sum and count")
+		}
+
+		if err := m.Histogram.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown metricType: %s", m.MType)
+	}
+
+	return ValidateLabels(m.Labels)
 }
 
 type TotalMetrics struct {
-	Gauge   map[string]float64 `json:"gauge"`
-	Counter map[string]int64   `json:"counter"`
+	Gauge     map[string]float64   `json:"gauge"`
+	Counter   map[string]int64     `json:"counter"`
+	Histogram map[string]Histogram `json:"histogram,omitempty"`
+}
+
+// MetricSample значение метрики после обновления, зафиксированное в момент времени
+type MetricSample struct {
+	Timestamp time.Time  `json:"timestamp"`
+	Gauge     float64    `json:"gauge"`
+	Counter   int64      `json:"counter"`
+	Histogram *Histogram `json:"histogram,omitempty"`
+}
+
+// AddCounter складывает значение счётчика с приращением, возвращает ErrCounterOverflow при выходе за пределы int64
+func AddCounter(value int64, delta int64) (int64, error) {
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return value, ErrCounterOverflow
+	}
+
+	return value + delta, nil
 }
@@ -0,0 +1,101 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RetentionTier уровень хранения истории: образцы с шагом Resolution хранятся TTL.
+// Resolution 0 означает исходные образцы
+type RetentionTier struct {
+	Resolution time.Duration
+	TTL        time.Duration
+}
+
+func (t RetentionTier) IsRaw() bool {
+	return t.Resolution == 0
+}
+
+func (t RetentionTier) String() string {
+	resolution := "raw"
+
+	if !t.IsRaw() {
+		resolution = t.Resolution.String()
+	}
+
+	return fmt.Sprintf("%s:%s", resolution, t.TTL)
+}
+
+// ParseRetentionTiers разбирает уровни хранения вида raw:24h,1m:720h,1h:8760h.
+// Первым должен идти уровень raw, шаг каждого следующего уровня кратен шагу предыдущего,
+// а срок хранения больше предыдущего. Пустая строка означает хранение исходных образцов без ограничения
+func ParseRetentionTiers(spec string) ([]RetentionTier, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var tiers []RetentionTier
+
+	for i, part := range strings.Split(spec, ",") {
+		resolution, ttl, ok := strings.Cut(strings.TrimSpace(part), ":")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid retention tier %q: expected <resolution>:<ttl>", part)
+		}
+
+		var tier RetentionTier
+		var err error
+
+		if resolution != "raw" {
+			if tier.Resolution, err = time.ParseDuration(resolution); err != nil || tier.Resolution <= 0 || tier.Resolution%time.Second != 0 {
+				return nil, fmt.Errorf("invalid retention tier %q: resolution must be raw or positive whole number of seconds", part)
+			}
+		}
+
+		if tier.TTL, err = time.ParseDuration(ttl); err != nil || tier.TTL <= 0 {
+			return nil, fmt.Errorf("invalid retention tier %q: ttl must be positive duration", part)
+		}
+
+		if i == 0 && !tier.IsRaw() {
+			return nil, errors.New("first retention tier must be raw")
+		}
+
+		if i > 0 {
+			prev := tiers[i-1]
+
+			if tier.IsRaw() || tier.Resolution <= prev.Resolution || (!prev.IsRaw() && tier.Resolution%prev.Resolution != 0) {
+				return nil, fmt.Errorf("invalid retention tier %q: resolution must be multiple of previous tier resolution %s", part, prev.Resolution)
+			}
+
+			if tier.TTL <= prev.TTL {
+				return nil, fmt.Errorf("invalid retention tier %q: ttl must be greater than previous tier ttl %s", part, prev.TTL)
+			}
+		}
+
+		if tier.TTL < tier.Resolution {
+			return nil, fmt.Errorf("invalid retention tier %q: ttl must not be less than resolution", part)
+		}
+
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// SelectRetentionTier возвращает самый подробный уровень, в котором ещё хранятся данные с момента start.
+// Если такого нет, возвращается уровень с самым долгим хранением
+func SelectRetentionTier(tiers []RetentionTier, start time.Time, now time.Time) RetentionTier {
+	if len(tiers) == 0 {
+		return RetentionTier{}
+	}
+
+	for _, tier := range tiers {
+		if !start.Before(now.Add(-tier.TTL)) {
+			return tier
+		}
+	}
+
+	return tiers[len(tiers)-1]
+}
@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"math"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+)
+
+// Rollup агрегат значений серии за интервал, начинающийся в Timestamp
+type Rollup struct {
+	Timestamp time.Time `json:"timestamp"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Sum       float64   `json:"sum"`
+	Count     int64     `json:"count"`
+}
+
+// NewRollup агрегат из одного значения
+func NewRollup(timestamp time.Time, value float64) Rollup {
+	return Rollup{Timestamp: timestamp, Min: value, Max: value, Sum: value, Count: 1}
+}
+
+// Merge возвращает агрегат значений обоих агрегатов с временем начала r
+func (r Rollup) Merge(other Rollup) Rollup {
+	if r.Count == 0 {
+		other.Timestamp = r.Timestamp
+		return other
+	}
+
+	if other.Count == 0 {
+		return r
+	}
+
+	return Rollup{
+		Timestamp: r.Timestamp,
+		Min:       math.Min(r.Min, other.Min),
+		Max:       math.Max(r.Max, other.Max),
+		Sum:       r.Sum + other.Sum,
+		Count:     r.Count + other.Count,
+	}
+}
+
+func (r Rollup) Avg() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+
+	return r.Sum / float64(r.Count)
+}
+
+// SampleValue значение образца серии типа metricType в виде числа, гистограмма представлена числом наблюдений
+func SampleValue(metricType string, sample MetricSample) float64 {
+	switch metricType {
+	case constants.MetricTypeGauge:
+		return sample.Gauge
+	case constants.MetricTypeHistogram:
+		if sample.Histogram == nil {
+			return 0
+		}
+
+		return float64(sample.Histogram.Count)
+	default:
+		return float64(sample.Counter)
+	}
+}
@@ -0,0 +1,113 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SeriesID возвращает идентификатор серии: имя метрики и отсортированный набор меток,
+// например Alloc{env="prod",host="web01"}. Для метрики без меток идентификатор совпадает с именем.
+func SeriesID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	b.WriteString(name)
+	b.WriteByte('{')
+
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[key]))
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// ParseSeriesID разбирает идентификатор серии на имя метрики и метки.
+// Если идентификатор не содержит корректного набора меток, он целиком считается именем.
+func ParseSeriesID(id string) (string, map[string]string) {
+	start := strings.IndexByte(id, '{')
+
+	if start <= 0 || !strings.HasSuffix(id, "}") {
+		return id, nil
+	}
+
+	labels := make(map[string]string)
+	rest := id[start+1 : len(id)-1]
+
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+
+		if eq <= 0 {
+			return id, nil
+		}
+
+		key := rest[:eq]
+
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+
+		if err != nil {
+			return id, nil
+		}
+
+		value, err := strconv.Unquote(quoted)
+
+		if err != nil {
+			return id, nil
+		}
+
+		labels[key] = value
+		rest = strings.TrimPrefix(rest[eq+1+len(quoted):], ",")
+	}
+
+	return id[:start], labels
+}
+
+// ValidateLabels проверяет, что имена меток имеют вид [a-zA-Z_][a-zA-Z0-9_]*
+func ValidateLabels(labels map[string]string) error {
+	for key := range labels {
+		if !isValidLabelName(key) {
+			return fmt.Errorf("invalid label name: %q", key)
+		}
+	}
+
+	return nil
+}
+
+func isValidLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_'
+		isDigit := r >= '0' && r <= '9'
+
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Metrics) SeriesID() string {
+	return SeriesID(m.ID, m.Labels)
+}
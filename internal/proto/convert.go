@@ -0,0 +1,49 @@
+package proto
+
+import (
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+)
+
+// FromMetrics converts metric to protobuf message, value is set from field of its type
+func FromMetrics(metric entities.Metrics) *Metric {
+	result := &Metric{Id: metric.ID, Type: metric.MType, Labels: metric.Labels}
+
+	switch {
+	case metric.Value != nil:
+		result.Value = &Metric_Gauge{Gauge: *metric.Value}
+	case metric.Delta != nil:
+		result.Value = &Metric_Delta{Delta: *metric.Delta}
+	case metric.Histogram != nil:
+		result.Value = &Metric_Histogram{Histogram: &Histogram{
+			Bounds: metric.Histogram.Bounds,
+			Counts: metric.Histogram.Counts,
+			Sum:    metric.Histogram.Sum,
+			Count:  metric.Histogram.Count,
+		}}
+	}
+
+	return result
+}
+
+// ToMetrics converts protobuf message to metric, value which is not set stays nil
+func (m *Metric) ToMetrics() entities.Metrics {
+	result := entities.Metrics{ID: m.GetId(), MType: m.GetType(), Labels: m.GetLabels()}
+
+	switch value := m.GetValue().(type) {
+	case *Metric_Gauge:
+		result.Value = &value.Gauge
+	case *Metric_Delta:
+		result.Delta = &value.Delta
+	case *Metric_Histogram:
+		if value.Histogram != nil {
+			result.Histogram = &entities.Histogram{
+				Bounds: value.Histogram.GetBounds(),
+				Counts: value.Histogram.GetCounts(),
+				Sum:    value.Histogram.GetSum(),
+				Count:  value.Histogram.GetCount(),
+			}
+		}
+	}
+
+	return result
+}
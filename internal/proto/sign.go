@@ -0,0 +1,47 @@
+package proto
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Codec encodes messages deterministically, so signature computed by Marshal before the call
+// matches bytes sent on the wire. It is passed to calls and servers explicitly instead of default codec
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(protobuf.Message)
+
+	if !ok {
+		return nil, fmt.Errorf("proto: cannot marshal %T, message is not protobuf message", v)
+	}
+
+	return Marshal(msg)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(protobuf.Message)
+
+	if !ok {
+		return fmt.Errorf("proto: cannot unmarshal %T, message is not protobuf message", v)
+	}
+
+	return protobuf.Unmarshal(data, msg)
+}
+
+func (Codec) Name() string {
+	return "proto"
+}
+
+// Marshal encodes message the same way as Codec
+func Marshal(msg protobuf.Message) ([]byte, error) {
+	return protobuf.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
+
+// AppendSignedBytes appends encoded message to bytes which are signed for a call. Message is prefixed
+// with its length, messages of stream are signed as their concatenation
+func AppendSignedBytes(buf []byte, data []byte) []byte {
+	return protowire.AppendBytes(buf, data)
+}
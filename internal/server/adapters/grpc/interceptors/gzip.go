@@ -0,0 +1,33 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+)
+
+// Gzip compressed requests are decompressed by registered gzip codec, interceptors compress
+// responses for clients which accept gzip even if request was not compressed
+
+func UnaryGzip(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	setGzipCompressor(ctx)
+
+	return handler(ctx, req)
+}
+
+func StreamGzip(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	setGzipCompressor(ss.Context())
+
+	return handler(srv, ss)
+}
+
+func setGzipCompressor(ctx context.Context) {
+	encodings, err := grpc.ClientSupportedCompressors(ctx)
+
+	if err == nil && shared.AcceptsGzip(encodings) {
+		grpc.SetSendCompressor(ctx, gzip.Name)
+	}
+}
@@ -0,0 +1,32 @@
+package interceptors_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/grpc/interceptors"
+)
+
+func TestGzip(t *testing.T) {
+	client, _ := setupSuite(t,
+		grpc.ChainUnaryInterceptor(interceptors.UnaryGzip),
+		grpc.ChainStreamInterceptor(interceptors.StreamGzip),
+	)
+
+	req := &pb.UpdateRequest{Metric: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1}}}
+
+	resp, err := client.Update(context.Background(), req, grpc.UseCompressor(gzip.Name))
+	require.NoError(t, err)
+	assert.Equal(t, "temp", resp.GetMetric().GetId())
+
+	resp, err = client.Update(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "temp", resp.GetMetric().GetId())
+}
@@ -0,0 +1,86 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+)
+
+func UnaryLogger(logger logger.ILogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		ctx, requestInfo := shared.WithRequestInfo(ctx)
+
+		resp, err := handler(ctx, req)
+
+		requestInfo.URI = info.FullMethod
+		requestInfo.Method = "unary"
+		requestInfo.Status = status.Code(err).String()
+		requestInfo.Duration = time.Since(start)
+		requestInfo.Size = messageSize(resp)
+
+		shared.LogRequest(logger, *requestInfo)
+
+		return resp, err
+	}
+}
+
+func StreamLogger(logger logger.ILogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		ctx, requestInfo := shared.WithRequestInfo(ss.Context())
+
+		ls := &loggingServerStream{ServerStream: ss, ctx: ctx}
+
+		err := handler(srv, ls)
+
+		requestInfo.URI = info.FullMethod
+		requestInfo.Method = "stream"
+		requestInfo.Status = status.Code(err).String()
+		requestInfo.Duration = time.Since(start)
+		requestInfo.Size = ls.size
+
+		shared.LogRequest(logger, *requestInfo)
+
+		return err
+	}
+}
+
+// loggingServerStream counts size of sent messages
+type loggingServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	size int
+}
+
+func (s *loggingServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggingServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+
+	if err == nil {
+		s.size += messageSize(m)
+	}
+
+	return err
+}
+
+func messageSize(m interface{}) int {
+	msg, ok := m.(proto.Message)
+
+	if !ok {
+		return 0
+	}
+
+	return proto.Size(msg)
+}
@@ -0,0 +1,208 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+// PayloadRecorder records messages of the call as they were received from the wire, sign validators
+// verify them like http middleware verifies raw body. Server with sign validators must use it as stats handler
+type PayloadRecorder struct{}
+
+type payloadKey struct{}
+
+type payload struct {
+	received []byte
+}
+
+func (PayloadRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, payloadKey{}, &payload{})
+}
+
+// HandleRPC copies received message, its buffer is reused by grpc after decoding
+func (PayloadRecorder) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	in, ok := s.(*stats.InPayload)
+
+	if !ok || in.Client {
+		return
+	}
+
+	if p, ok := ctx.Value(payloadKey{}).(*payload); ok {
+		p.received = pb.AppendSignedBytes(p.received, in.Data)
+	}
+}
+
+func (PayloadRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (PayloadRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+// received returns messages recorded by PayloadRecorder for the call
+func received(ctx context.Context) ([]byte, error) {
+	p, ok := ctx.Value(payloadKey{}).(*payload)
+
+	if !ok {
+		return nil, status.Error(codes.Internal, "Received payload is not recorded")
+	}
+
+	return p.received, nil
+}
+
+// UnarySignValidator verifies signature of request passed in metadata and signs response in header metadata.
+// Guard is optional and rejects stale and replayed requests like http middleware
+func UnarySignValidator(signer signer.Signer, guard *shared.ReplayGuard) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		body, err := received(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verify(ctx, signer, guard, body); err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+
+		if err != nil {
+			return resp, err
+		}
+
+		if body, err := signedBytes(resp); err == nil {
+			grpc.SetHeader(ctx, metadata.Pairs(constants.HashHeader, signer.Sign(body)))
+		}
+
+		return resp, nil
+	}
+}
+
+// StreamSignValidator verifies signature of all messages received by stream, messages are signed as concatenation.
+// Client stream is verified when client closes it, so handler gets io.EOF only for valid stream
+func StreamSignValidator(signer signer.Signer, guard *shared.ReplayGuard) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &signedServerStream{
+			ServerStream: ss,
+			signer:       signer,
+			guard:        guard,
+			clientStream: info.IsClientStream,
+		})
+	}
+}
+
+type signedServerStream struct {
+	grpc.ServerStream
+	signer       signer.Signer
+	guard        *shared.ReplayGuard
+	clientStream bool
+	signed       bool
+}
+
+func (s *signedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+
+	if errors.Is(err, io.EOF) {
+		return s.verify(err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// single request of server stream is not followed by io.EOF read by handler
+	if !s.clientStream {
+		return s.verify(nil)
+	}
+
+	return nil
+}
+
+func (s *signedServerStream) verify(result error) error {
+	body, err := received(s.Context())
+
+	if err != nil {
+		return err
+	}
+
+	if err := verify(s.Context(), s.signer, s.guard, body); err != nil {
+		return err
+	}
+
+	return result
+}
+
+// SendMsg signs the first response message, header metadata is sent with it
+func (s *signedServerStream) SendMsg(m interface{}) error {
+	if !s.signed {
+		s.signed = true
+
+		if body, err := signedBytes(m); err == nil {
+			s.SetHeader(metadata.Pairs(constants.HashHeader, s.signer.Sign(body)))
+		}
+	}
+
+	return s.ServerStream.SendMsg(m)
+}
+
+// verify checks signature and stamp passed in metadata of the call
+func verify(ctx context.Context, signer signer.Signer, guard *shared.ReplayGuard, body []byte) error {
+	err := shared.VerifyStampedSignature(
+		ctx,
+		signer,
+		guard,
+		body,
+		incomingValue(ctx, constants.HashHeader),
+		incomingValue(ctx, constants.TimestampHeader),
+		incomingValue(ctx, constants.NonceHeader),
+	)
+
+	if errors.Is(err, shared.ErrInvalidSignature) {
+		return status.Error(codes.Unauthenticated, "Invalid signature")
+	}
+
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "Invalid request stamp: "+err.Error())
+	}
+
+	return nil
+}
+
+func incomingValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// signedBytes encodes response, server sends the same bytes when it uses pb.Codec
+func signedBytes(m interface{}) ([]byte, error) {
+	msg, ok := m.(proto.Message)
+
+	if !ok {
+		return nil, errors.New("message is not protobuf message")
+	}
+
+	data, err := pb.Marshal(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pb.AppendSignedBytes(nil, data), nil
+}
@@ -0,0 +1,296 @@
+package interceptors_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/grpc/interceptors"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+// echoServer answers with received metrics and records batches which reached io.EOF
+type echoServer struct {
+	pb.UnimplementedMetricsServer
+	batches int
+}
+
+func (s *echoServer) Update(_ context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	return &pb.UpdateResponse{Metric: req.GetMetric()}, nil
+}
+
+func (s *echoServer) UpdateBatch(stream pb.Metrics_UpdateBatchServer) error {
+	var metrics []*pb.Metric
+
+	for {
+		req, err := stream.Recv()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		metrics = append(metrics, req.GetMetrics()...)
+	}
+
+	s.batches++
+
+	return stream.SendAndClose(&pb.UpdateBatchResponse{Metrics: metrics})
+}
+
+func setupSuite(t *testing.T, opts ...grpc.ServerOption) (pb.MetricsClient, *echoServer) {
+	lis := bufconn.Listen(1 << 20)
+
+	server := &echoServer{}
+
+	// sign validators verify messages recorded as they were received
+	s := grpc.NewServer(append(opts, grpc.StatsHandler(interceptors.PayloadRecorder{}))...)
+	pb.RegisterMetricsServer(s, server)
+
+	go s.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+
+	return pb.NewMetricsClient(conn), server
+}
+
+func sign(t *testing.T, s signer.Signer, msgs ...*pb.UpdateBatchRequest) string {
+	var messages []proto.Message
+
+	for _, msg := range msgs {
+		messages = append(messages, msg)
+	}
+
+	return s.Sign(signedBytes(t, messages...))
+}
+
+func signedBytes(t *testing.T, msgs ...proto.Message) []byte {
+	var body []byte
+
+	for _, msg := range msgs {
+		data, err := pb.Marshal(msg)
+		require.NoError(t, err)
+
+		body = pb.AppendSignedBytes(body, data)
+	}
+
+	return body
+}
+
+func TestUnarySignValidator(t *testing.T) {
+	s := signer.NewSHA256Signer("test")
+
+	client, _ := setupSuite(t, grpc.UnaryInterceptor(interceptors.UnarySignValidator(s, nil)))
+
+	req := &pb.UpdateRequest{Metric: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1}}}
+
+	body := signedBytes(t, req)
+
+	tests := []struct {
+		name      string
+		signature string
+		code      codes.Code
+	}{
+		{name: "should return result if provided valid signature", signature: s.Sign(body), code: codes.OK},
+		{name: "should return error if provided invalid signature", signature: signer.NewSHA256Signer("other").Sign(body), code: codes.Unauthenticated},
+		{name: "should return error if signature not provided", code: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if tt.signature != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, constants.HashHeader, tt.signature)
+			}
+
+			var header metadata.MD
+
+			resp, err := client.Update(ctx, req, grpc.Header(&header))
+
+			require.Equal(t, tt.code, status.Code(err))
+
+			if tt.code != codes.OK {
+				return
+			}
+
+			signature := header.Get(constants.HashHeader)
+			require.Len(t, signature, 1)
+			assert.True(t, s.Verify(signedBytes(t, resp), signature[0]))
+		})
+	}
+}
+
+func TestUnarySignValidator_Replay(t *testing.T) {
+	s := signer.NewSHA256Signer("test")
+	guard := shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10})
+
+	client, _ := setupSuite(t, grpc.UnaryInterceptor(interceptors.UnarySignValidator(s, guard)))
+
+	req := &pb.UpdateRequest{Metric: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1}}}
+
+	body := signedBytes(t, req)
+
+	stamp, err := signer.NewStamp(time.Now())
+	require.NoError(t, err)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		constants.HashHeader, s.Sign(stamp.Material(body)),
+		constants.TimestampHeader, stamp.TimestampString(),
+		constants.NonceHeader, stamp.Nonce,
+	)
+
+	_, err = client.Update(ctx, req)
+	require.NoError(t, err)
+
+	_, err = client.Update(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "replayed request")
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), constants.HashHeader, s.Sign(body))
+
+	_, err = client.Update(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "request without stamp")
+}
+
+// wireCodec sends request bytes as they are, like client with another protobuf encoder
+type wireCodec struct {
+	data []byte
+}
+
+func (c wireCodec) Marshal(interface{}) ([]byte, error) {
+	return c.data, nil
+}
+
+func (c wireCodec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (c wireCodec) Name() string {
+	return "proto"
+}
+
+func TestUnarySignValidator_WireBytes(t *testing.T) {
+	s := signer.NewSHA256Signer("test")
+
+	client, _ := setupSuite(t, grpc.UnaryInterceptor(interceptors.UnarySignValidator(s, nil)))
+
+	id, err := pb.Marshal(&pb.Metric{Id: "temp"})
+	require.NoError(t, err)
+
+	value, err := pb.Marshal(&pb.Metric{Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1}})
+	require.NoError(t, err)
+
+	// metric is split into two fields which are merged when decoded, so encoded request differs from sent one
+	var data []byte
+
+	for _, part := range [][]byte{id, value} {
+		data = protowire.AppendTag(data, 1, protowire.BytesType)
+		data = protowire.AppendBytes(data, part)
+	}
+
+	var req pb.UpdateRequest
+	require.NoError(t, proto.Unmarshal(data, &req))
+	require.NotEqual(t, signedBytes(t, &req), pb.AppendSignedBytes(nil, data))
+
+	tests := []struct {
+		name string
+		body []byte
+		code codes.Code
+	}{
+		{name: "should accept signature of sent bytes", body: pb.AppendSignedBytes(nil, data), code: codes.OK},
+		{name: "should reject signature of encoded request", body: signedBytes(t, &req), code: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), constants.HashHeader, s.Sign(tt.body))
+
+			resp, err := client.Update(ctx, &req, grpc.ForceCodec(wireCodec{data: data}))
+
+			require.Equal(t, tt.code, status.Code(err))
+
+			if tt.code == codes.OK {
+				assert.Equal(t, "temp", resp.GetMetric().GetId())
+			}
+		})
+	}
+}
+
+func TestStreamSignValidator(t *testing.T) {
+	s := signer.NewSHA256Signer("test")
+
+	chunks := []*pb.UpdateBatchRequest{
+		{Metrics: []*pb.Metric{{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}}}},
+		{Metrics: []*pb.Metric{{Id: "Alloc", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1}}}},
+	}
+
+	tests := []struct {
+		name      string
+		signature string
+		code      codes.Code
+	}{
+		{name: "should save batch if provided valid signature", signature: sign(t, s, chunks...), code: codes.OK},
+		{name: "should reject batch if signature does not cover all messages", signature: sign(t, s, chunks[0]), code: codes.Unauthenticated},
+		{name: "should reject batch if signature not provided", code: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := setupSuite(t, grpc.StreamInterceptor(interceptors.StreamSignValidator(s, nil)))
+
+			ctx := context.Background()
+
+			if tt.signature != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, constants.HashHeader, tt.signature)
+			}
+
+			stream, err := client.UpdateBatch(ctx)
+			require.NoError(t, err)
+
+			for _, chunk := range chunks {
+				err = stream.Send(chunk)
+				require.NoError(t, err)
+			}
+
+			resp, err := stream.CloseAndRecv()
+
+			require.Equal(t, tt.code, status.Code(err))
+
+			if tt.code != codes.OK {
+				assert.Equal(t, 0, server.batches)
+				return
+			}
+
+			assert.Equal(t, 1, server.batches)
+			assert.Len(t, resp.GetMetrics(), 2)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package metric
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/grpc/interceptors"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+// Batch is buffered until client closes the stream, so its size is limited
+const (
+	maxBatchItems = 100_000
+	maxBatchBytes = 16 << 20
+)
+
+type Adapter struct {
+	pb.UnimplementedMetricsServer
+	metricService metricprocessor.MetricService
+	storage       storage.Storage
+	logger        logger.ILogger
+	signer        signer.Signer
+	replayGuard   *shared.ReplayGuard
+	tlsConfig     *tls.Config
+}
+
+// ListenAndServe listens tcp address and serves grpc requests until ctx is done
+func (a *Adapter) ListenAndServe(ctx context.Context, address string) error {
+	lis, err := net.Listen("tcp", address)
+
+	if err != nil {
+		return err
+	}
+
+	return a.Serve(ctx, lis)
+}
+
+// Serve serves grpc requests on lis. When ctx is done active requests are drained and lis is closed
+func (a *Adapter) Serve(ctx context.Context, lis net.Listener) error {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.unaryInterceptors()...),
+		grpc.ChainStreamInterceptor(a.streamInterceptors()...),
+		// responses are signed before sending, so they are encoded the same way as by signer
+		grpc.ForceServerCodec(pb.Codec{}),
+	}
+
+	if a.signer != nil {
+		opts = append(opts, grpc.StatsHandler(interceptors.PayloadRecorder{}))
+	}
+
+	if a.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(a.tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterMetricsServer(server, a)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		<-ctx.Done()
+		a.logger.Infow("grpc: stop server", "reason", ctx.Err())
+		server.GracefulStop()
+	}()
+
+	a.logger.Infow("start grpc server", "address", lis.Addr().String())
+
+	err := server.Serve(lis)
+
+	// Serve returns as soon as listener is closed, active requests are drained by GracefulStop after it
+	cancel()
+	<-stopped
+
+	return err
+}
+
+// unaryInterceptors are applied in the same order as http middlewares
+func (a *Adapter) unaryInterceptors() []grpc.UnaryServerInterceptor {
+	result := []grpc.UnaryServerInterceptor{interceptors.UnaryLogger(a.logger)}
+
+	if a.signer != nil {
+		result = append(result, interceptors.UnarySignValidator(a.signer, a.replayGuard))
+	}
+
+	return append(result, interceptors.UnaryGzip)
+}
+
+func (a *Adapter) streamInterceptors() []grpc.StreamServerInterceptor {
+	result := []grpc.StreamServerInterceptor{interceptors.StreamLogger(a.logger)}
+
+	if a.signer != nil {
+		result = append(result, interceptors.StreamSignValidator(a.signer, a.replayGuard))
+	}
+
+	return append(result, interceptors.StreamGzip)
+}
+
+func (a *Adapter) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	metric := req.GetMetric().ToMetrics()
+
+	if err := metric.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	updatedValue, err := a.metricService.SaveMetric(ctx, metric.MType, metric.SeriesID(), metricValue(metric))
+
+	if err != nil {
+		return nil, saveError(err)
+	}
+
+	return &pb.UpdateResponse{Metric: pb.FromMetrics(withValue(metric, updatedValue))}, nil
+}
+
+// UpdateBatch receives batch in chunks and saves it all-or-nothing when client closes the stream.
+// Rejected batch is answered with InvalidArgument which names the rejected item
+func (a *Adapter) UpdateBatch(stream pb.Metrics_UpdateBatchServer) error {
+	var metrics []entities.Metrics
+
+	size := 0
+
+	for {
+		req, err := stream.Recv()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		size += proto.Size(req)
+
+		if size > maxBatchBytes {
+			return status.Errorf(codes.ResourceExhausted, "batch exceeds %d bytes", maxBatchBytes)
+		}
+
+		if len(metrics)+len(req.GetMetrics()) > maxBatchItems {
+			return status.Errorf(codes.ResourceExhausted, "batch exceeds %d metrics", maxBatchItems)
+		}
+
+		for _, metric := range req.GetMetrics() {
+			metrics = append(metrics, metric.ToMetrics())
+		}
+	}
+
+	for i, metric := range metrics {
+		if err := metric.Validate(); err != nil {
+			return status.Error(codes.InvalidArgument, storage.NewErrBatchItem(i, err).Error())
+		}
+	}
+
+	saved, err := a.storage.SaveMetricBatch(stream.Context(), metrics)
+
+	var itemErr *storage.ErrBatchItem
+
+	if errors.As(err, &itemErr) {
+		return status.Error(codes.InvalidArgument, itemErr.Error())
+	}
+
+	if err != nil {
+		return saveError(err)
+	}
+
+	resp := &pb.UpdateBatchResponse{Metrics: make([]*pb.Metric, 0, len(saved))}
+
+	for _, metric := range saved {
+		resp.Metrics = append(resp.Metrics, pb.FromMetrics(metric))
+	}
+
+	return stream.SendAndClose(resp)
+}
+
+func (a *Adapter) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	metric := entities.Metrics{ID: req.GetId(), MType: req.GetType(), Labels: req.GetLabels()}
+
+	if !isValidMetricType(metric.MType) {
+		return nil, status.Error(codes.InvalidArgument, "Supported metrics: gauge | counter | histogram")
+	}
+
+	val, err := a.metricService.GetMetric(ctx, metric.MType, metric.SeriesID())
+
+	if storage.IsErrNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "Not found metric: %s", metric.SeriesID())
+	}
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.GetResponse{Metric: pb.FromMetrics(withValue(metric, val))}, nil
+}
+
+// List returns current value of every series ordered by type and series id
+func (a *Adapter) List(ctx context.Context, _ *pb.ListRequest) (*pb.ListResponse, error) {
+	metrics, err := a.metricService.GetAllMetrics(ctx)
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var list []entities.Metrics
+
+	for id, value := range metrics.Gauge {
+		list = append(list, seriesMetrics(id, constants.MetricTypeGauge, metricprocessor.MetricValue{Gauge: value}))
+	}
+
+	for id, value := range metrics.Counter {
+		list = append(list, seriesMetrics(id, constants.MetricTypeCounter, metricprocessor.MetricValue{Counter: value}))
+	}
+
+	for id, value := range metrics.Histogram {
+		list = append(list, seriesMetrics(id, constants.MetricTypeHistogram, metricprocessor.MetricValue{Histogram: value}))
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].MType != list[j].MType {
+			return list[i].MType < list[j].MType
+		}
+
+		return list[i].SeriesID() < list[j].SeriesID()
+	})
+
+	resp := &pb.ListResponse{Metrics: make([]*pb.Metric, 0, len(list))}
+
+	for _, metric := range list {
+		resp.Metrics = append(resp.Metrics, pb.FromMetrics(metric))
+	}
+
+	return resp, nil
+}
+
+// New returns grpc adapter, replayGuard and tlsConfig are optional: plaintext connections are served without tlsConfig
+func New(metricService metricprocessor.MetricService, storage storage.Storage, logger logger.ILogger, signer signer.Signer, replayGuard *shared.ReplayGuard, tlsConfig *tls.Config) *Adapter {
+	return &Adapter{
+		metricService: metricService,
+		storage:       storage,
+		logger:        logger,
+		signer:        signer,
+		replayGuard:   replayGuard,
+		tlsConfig:     tlsConfig,
+	}
+}
+
+func saveError(err error) error {
+	if errors.Is(err, entities.ErrHistogramBoundsMismatch) || errors.Is(err, entities.ErrCounterOverflow) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func isValidMetricType(metricType string) bool {
+	switch metricType {
+	case constants.MetricTypeGauge, constants.MetricTypeCounter, constants.MetricTypeHistogram:
+		return true
+	}
+
+	return false
+}
+
+// metricValue returns value of validated metric
+func metricValue(metric entities.Metrics) metricprocessor.MetricValue {
+	switch metric.MType {
+	case constants.MetricTypeGauge:
+		return metricprocessor.MetricValue{Gauge: *metric.Value}
+	case constants.MetricTypeCounter:
+		return metricprocessor.MetricValue{Counter: *metric.Delta}
+	default:
+		return metricprocessor.MetricValue{Histogram: *metric.Histogram}
+	}
+}
+
+// withValue returns metric with value of its type set from val
+func withValue(metric entities.Metrics, val metricprocessor.MetricValue) entities.Metrics {
+	result := entities.Metrics{ID: metric.ID, MType: metric.MType, Labels: metric.Labels}
+
+	switch metric.MType {
+	case constants.MetricTypeGauge:
+		result.Value = &val.Gauge
+	case constants.MetricTypeCounter:
+		result.Delta = &val.Counter
+	case constants.MetricTypeHistogram:
+		result.Histogram = &val.Histogram
+	}
+
+	return result
+}
+
+func seriesMetrics(seriesID string, metricType string, val metricprocessor.MetricValue) entities.Metrics {
+	name, labels := entities.ParseSeriesID(seriesID)
+
+	return withValue(entities.Metrics{ID: name, MType: metricType, Labels: labels}, val)
+}
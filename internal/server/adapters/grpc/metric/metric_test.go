@@ -0,0 +1,367 @@
+package metric_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	pb "github.com/sodiqit/metricpulse.git/internal/proto"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/grpc/metric"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+)
+
+func startServer(t *testing.T, adapter *metric.Adapter) pb.MetricsClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lis := bufconn.Listen(1 << 20)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		adapter.Serve(ctx, lis)
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+		<-done
+	})
+
+	return pb.NewMetricsClient(conn)
+}
+
+func TestAdapter_ListenAndServe(t *testing.T) {
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	adapter := metric.New(nil, nil, logger, nil, nil, nil)
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer busy.Close()
+
+	err = adapter.ListenAndServe(context.Background(), busy.Addr().String())
+
+	assert.Error(t, err)
+}
+
+func TestAdapter_Update(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	client := startServer(t, metric.New(metricServiceMock, storageMock, logger, nil, nil, nil))
+
+	tests := []struct {
+		name      string
+		metric    *pb.Metric
+		setupMock func()
+		expected  *pb.Metric
+		code      codes.Code
+	}{
+		{
+			name:   "update gauge with labels",
+			metric: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}, Value: &pb.Metric_Gauge{Gauge: 23.5}},
+			setupMock: func() {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeGauge, `temp{host="web01"}`, metricprocessor.MetricValue{Gauge: 23.5}).Times(1).Return(metricprocessor.MetricValue{Gauge: 23.5}, nil)
+			},
+			expected: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}, Value: &pb.Metric_Gauge{Gauge: 23.5}},
+			code:     codes.OK,
+		},
+		{
+			name:   "update counter returns total value",
+			metric: &pb.Metric{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 2}},
+			setupMock: func() {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeCounter, "PollCount", metricprocessor.MetricValue{Counter: 2}).Times(1).Return(metricprocessor.MetricValue{Counter: 7}, nil)
+			},
+			expected: &pb.Metric{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 7}},
+			code:     codes.OK,
+		},
+		{
+			name:      "value of other type",
+			metric:    &pb.Metric{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Gauge{Gauge: 1}},
+			setupMock: func() {},
+			code:      codes.InvalidArgument,
+		},
+		{
+			name:   "counter overflow",
+			metric: &pb.Metric{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}},
+			setupMock: func() {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeCounter, "PollCount", gomock.Any()).Times(1).Return(metricprocessor.MetricValue{}, entities.ErrCounterOverflow)
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name:   "storage error",
+			metric: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1}},
+			setupMock: func() {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeGauge, "temp", gomock.Any()).Times(1).Return(metricprocessor.MetricValue{}, errors.New("error"))
+			},
+			code: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			resp, err := client.Update(context.Background(), &pb.UpdateRequest{Metric: tt.metric})
+
+			assert.Equal(t, tt.code, status.Code(err))
+
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.expected.String(), resp.GetMetric().String())
+			}
+		})
+	}
+}
+
+func TestAdapter_UpdateBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	client := startServer(t, metric.New(metricServiceMock, storageMock, logger, nil, nil, nil))
+
+	delta, value := int64(1), 1.5
+
+	// chunks are sent like the agent sends them
+	var tooManyItems [][]*pb.Metric
+
+	for i := 0; i <= 100_000/500; i++ {
+		chunk := make([]*pb.Metric, 500)
+
+		for j := range chunk {
+			chunk[j] = &pb.Metric{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}}
+		}
+
+		tooManyItems = append(tooManyItems, chunk)
+	}
+
+	var tooManyBytes [][]*pb.Metric
+
+	for i := 0; i < 5; i++ {
+		labels := map[string]string{"host": strings.Repeat("a", 3<<20+512<<10)}
+
+		tooManyBytes = append(tooManyBytes, []*pb.Metric{{Id: "Alloc", Type: constants.MetricTypeGauge, Labels: labels, Value: &pb.Metric_Gauge{Gauge: 1}}})
+	}
+
+	tests := []struct {
+		name      string
+		chunks    [][]*pb.Metric
+		setupMock func()
+		expected  int
+		code      codes.Code
+		message   string
+	}{
+		{
+			name: "batch from all chunks is saved at once",
+			chunks: [][]*pb.Metric{
+				{{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}}},
+				{{Id: "Alloc", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1.5}}},
+			},
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), []entities.Metrics{
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &value},
+				}).Times(1).Return([]entities.Metrics{
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &value},
+				}, nil)
+			},
+			expected: 2,
+			code:     codes.OK,
+		},
+		{
+			name: "invalid item rejects whole batch",
+			chunks: [][]*pb.Metric{
+				{{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}}},
+				{{Id: "", Type: constants.MetricTypeGauge, Value: &pb.Metric_Gauge{Gauge: 1.5}}},
+			},
+			setupMock: func() {},
+			code:      codes.InvalidArgument,
+			message:   "batch item 1 rejected: metric id not provided",
+		},
+		{
+			name: "item rejected by storage",
+			chunks: [][]*pb.Metric{
+				{{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}}},
+			},
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, storage.NewErrBatchItem(0, entities.ErrCounterOverflow))
+			},
+			code:    codes.InvalidArgument,
+			message: "batch item 0 rejected: counter overflow",
+		},
+		{
+			name: "storage error",
+			chunks: [][]*pb.Metric{
+				{{Id: "PollCount", Type: constants.MetricTypeCounter, Value: &pb.Metric_Delta{Delta: 1}}},
+			},
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("error"))
+			},
+			code: codes.Internal,
+		},
+		{
+			name:      "batch with too many items",
+			chunks:    tooManyItems,
+			setupMock: func() {},
+			code:      codes.ResourceExhausted,
+			message:   "batch exceeds 100000 metrics",
+		},
+		{
+			name:      "batch with too many bytes",
+			chunks:    tooManyBytes,
+			setupMock: func() {},
+			code:      codes.ResourceExhausted,
+			message:   "batch exceeds 16777216 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			stream, err := client.UpdateBatch(context.Background())
+			require.NoError(t, err)
+
+			for _, chunk := range tt.chunks {
+				// error of Send after server rejected the stream is returned by CloseAndRecv
+				if err := stream.Send(&pb.UpdateBatchRequest{Metrics: chunk}); err != nil {
+					break
+				}
+			}
+
+			resp, err := stream.CloseAndRecv()
+
+			assert.Equal(t, tt.code, status.Code(err))
+
+			if tt.message != "" {
+				assert.Equal(t, tt.message, status.Convert(err).Message())
+			}
+
+			if tt.code == codes.OK {
+				assert.Len(t, resp.GetMetrics(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestAdapter_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	client := startServer(t, metric.New(metricServiceMock, storageMock, logger, nil, nil, nil))
+
+	tests := []struct {
+		name      string
+		req       *pb.GetRequest
+		setupMock func()
+		expected  *pb.Metric
+		code      codes.Code
+	}{
+		{
+			name: "get series with labels",
+			req:  &pb.GetRequest{Id: "temp", Type: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}},
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetMetric(gomock.Any(), constants.MetricTypeGauge, `temp{host="web01"}`).Times(1).Return(metricprocessor.MetricValue{Gauge: 2.5}, nil)
+			},
+			expected: &pb.Metric{Id: "temp", Type: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}, Value: &pb.Metric_Gauge{Gauge: 2.5}},
+			code:     codes.OK,
+		},
+		{
+			name: "not found",
+			req:  &pb.GetRequest{Id: "temp", Type: constants.MetricTypeCounter},
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetMetric(gomock.Any(), constants.MetricTypeCounter, "temp").Times(1).Return(metricprocessor.MetricValue{}, storage.NewErrNotFound(errors.New("not found"), nil))
+			},
+			code: codes.NotFound,
+		},
+		{
+			name:      "unknown type",
+			req:       &pb.GetRequest{Id: "temp", Type: "summary"},
+			setupMock: func() {},
+			code:      codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			resp, err := client.Get(context.Background(), tt.req)
+
+			assert.Equal(t, tt.code, status.Code(err))
+
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.expected.String(), resp.GetMetric().String())
+			}
+		})
+	}
+}
+
+func TestAdapter_List(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	client := startServer(t, metric.New(metricServiceMock, storageMock, logger, nil, nil, nil))
+
+	metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{
+		Gauge:   map[string]float64{`Alloc{host="web01"}`: 2, "Alloc": 1},
+		Counter: map[string]int64{"PollCount": 5},
+	}, nil)
+
+	resp, err := client.List(context.Background(), &pb.ListRequest{})
+	require.NoError(t, err)
+
+	var ids []string
+
+	for _, m := range resp.GetMetrics() {
+		ids = append(ids, m.GetType()+":"+entities.SeriesID(m.GetId(), m.GetLabels()))
+	}
+
+	assert.Equal(t, []string{"counter:PollCount", "gauge:Alloc", `gauge:Alloc{host="web01"}`}, ids)
+	assert.Equal(t, map[string]string{"host": "web01"}, resp.GetMetrics()[2].GetLabels())
+	assert.Equal(t, float64(2), resp.GetMetrics()[2].GetGauge())
+}
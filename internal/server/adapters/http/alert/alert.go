@@ -0,0 +1,48 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/middlewares"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/alerting"
+)
+
+type Adapter struct {
+	alertService alerting.AlertService
+	logger       logger.ILogger
+}
+
+func (a *Adapter) Route() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(middlewares.WithLogger(a.logger))
+	r.Use(middlewares.Gzip)
+
+	r.Get("/", a.handleGetAlerts)
+
+	return r
+}
+
+func (a *Adapter) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	result, err := json.Marshal(a.alertService.Alerts())
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	w.Write(result)
+}
+
+func New(alertService alerting.AlertService, logger logger.ILogger) *Adapter {
+	return &Adapter{
+		alertService,
+		logger,
+	}
+}
@@ -0,0 +1,99 @@
+package alert_test
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-resty/resty/v2"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/alert"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/alerting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetAlertsHandler(t *testing.T) {
+	r := chi.NewRouter()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alertServiceMock := alerting.NewMockAlertService(ctrl)
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	c := alert.New(alertServiceMock, logger)
+
+	r.Mount("/alerts", c.Route())
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	client := resty.New().SetBaseURL(ts.URL)
+
+	activeAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		method         string
+		setupMock      func()
+		expectedResult string
+		expectedStatus int
+	}{
+		{
+			name:   "valid result",
+			method: http.MethodGet,
+			setupMock: func() {
+				alertServiceMock.EXPECT().Alerts().Times(1).Return([]alerting.Alert{
+					{Name: "HighHeap", Rule: "gauge HeapAlloc > 100", State: alerting.StatePending, Value: 150, ActiveAt: activeAt},
+				})
+			},
+			expectedResult: `[{"name":"HighHeap","rule":"gauge HeapAlloc > 100","state":"pending","value":150,"activeAt":"2024-01-01T00:00:00Z"}]`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "no alerts",
+			method: http.MethodGet,
+			setupMock: func() {
+				alertServiceMock.EXPECT().Alerts().Times(1).Return([]alerting.Alert{})
+			},
+			expectedResult: `[]`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid method",
+			method:         http.MethodPost,
+			setupMock:      func() {},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			req := client.R()
+
+			req.Method = tc.method
+			req.URL = "/alerts"
+
+			resp, err := req.Send()
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+				assert.JSONEq(t, tc.expectedResult, resp.String())
+			}
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -14,8 +17,10 @@ import (
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
 	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/middlewares"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
 	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
 	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
 )
 
@@ -24,6 +29,8 @@ type Adapter struct {
 	logger        logger.ILogger
 	storage       storage.Storage
 	signer        signer.Signer
+	replayGuard   *shared.ReplayGuard
+	decryptor     encryption.Decryptor
 }
 
 func (a *Adapter) Route() *chi.Mux {
@@ -32,7 +39,12 @@ func (a *Adapter) Route() *chi.Mux {
 	r.Use(middlewares.WithLogger(a.logger))
 
 	if a.signer != nil {
-		r.Use(middlewares.WithSignValidator(a.signer))
+		r.Use(middlewares.WithSignValidator(a.signer, a.replayGuard))
+	}
+
+	// body is signed after encryption
+	if a.decryptor != nil {
+		r.Use(middlewares.WithDecryptor(a.decryptor))
 	}
 
 	r.Use(middlewares.Gzip)
@@ -42,14 +54,26 @@ func (a *Adapter) Route() *chi.Mux {
 
 	r.Get("/value/{metricType}/{metricName}", a.handleTextGetMetric)
 	r.Post("/value/", a.handleGetMetric)
+	r.Delete("/value/{metricType}/{metricName}", a.handleDeleteMetric)
 
 	r.Get("/ping", a.handlePing)
 	r.Post("/updates/", a.handleUpdatesMetric)
+	r.Post("/deletes/", a.handleDeletesMetric)
 	r.Get("/", a.handleGetAllMetrics)
+	r.Get("/metrics", a.handlePrometheusMetrics)
+	r.Get("/api/v1/query_range", a.handleQueryRange)
 
 	return r
 }
 
+// batchItemResult is value of the series after update or reason why the item was rejected
+type batchItemResult struct {
+	entities.Metrics
+	Error string `json:"error,omitempty"`
+}
+
+// handleUpdatesMetric saves batch all-or-nothing and responds with result of every item in batch order.
+// On 400 nothing is saved and rejected items have error
 func (a *Adapter) handleUpdatesMetric(w http.ResponseWriter, r *http.Request) {
 	var metrics []entities.Metrics
 
@@ -65,14 +89,132 @@ func (a *Adapter) handleUpdatesMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := a.storage.SaveMetricBatch(r.Context(), metrics)
+	results := make([]batchItemResult, len(metrics))
+	valid := true
+
+	for i, metric := range metrics {
+		results[i].Metrics = entities.Metrics{ID: metric.ID, MType: metric.MType, Labels: metric.Labels}
+
+		if err := metric.Validate(); err != nil {
+			results[i].Error = err.Error()
+			valid = false
+		}
+	}
+
+	if !valid {
+		a.writeBatchResults(w, http.StatusBadRequest, results)
+		return
+	}
+
+	saved, err := a.storage.SaveMetricBatch(r.Context(), metrics)
+
+	var itemErr *storage.ErrBatchItem
+
+	if errors.As(err, &itemErr) && itemErr.Index < len(results) {
+		results[itemErr.Index].Error = errors.Unwrap(itemErr).Error()
+		a.writeBatchResults(w, http.StatusBadRequest, results)
+		return
+	}
+
+	if errors.Is(err, entities.ErrHistogramBoundsMismatch) || errors.Is(err, entities.ErrCounterOverflow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Write([]byte(""))
+	for i := range saved {
+		results[i].Metrics = saved[i]
+	}
+
+	a.writeBatchResults(w, http.StatusOK, results)
+}
+
+func (a *Adapter) writeBatchResults(w http.ResponseWriter, status int, results []batchItemResult) {
+	body, err := json.Marshal(results)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	w.Write(body)
+}
+
+type queryRangeResponse struct {
+	Name   string                       `json:"name"`
+	Type   string                       `json:"type"`
+	Agg    string                       `json:"agg"`
+	Step   float64                      `json:"step"`
+	Points []metricprocessor.RangePoint `json:"points"`
+}
+
+// handleQueryRange returns history of the series aggregated by step. Name is series id, e.g. Alloc{host="web01"};
+// start and end are RFC3339 or unix seconds, step is duration like 1m or seconds, agg is avg by default
+func (a *Adapter) handleQueryRange(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := metricprocessor.RangeQuery{
+		MetricType: params.Get("type"),
+		MetricName: params.Get("name"),
+		Agg:        params.Get("agg"),
+	}
+
+	if query.Agg == "" {
+		query.Agg = metricprocessor.AggAvg
+	}
+
+	var err error
+
+	if query.Start, err = parseTimeParam(params.Get("start")); err != nil {
+		http.Error(w, fmt.Sprintf("invalid start: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if query.End, err = parseTimeParam(params.Get("end")); err != nil {
+		http.Error(w, fmt.Sprintf("invalid end: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if query.Step, err = parseStepParam(params.Get("step")); err != nil {
+		http.Error(w, fmt.Sprintf("invalid step: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	points, err := a.metricService.QueryRange(r.Context(), query)
+
+	if errors.Is(err, metricprocessor.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := json.Marshal(queryRangeResponse{
+		Name:   query.MetricName,
+		Type:   query.MetricType,
+		Agg:    query.Agg,
+		Step:   query.Step.Seconds(),
+		Points: points,
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	w.Write(result)
 }
 
 func (a *Adapter) handlePing(w http.ResponseWriter, r *http.Request) {
@@ -94,7 +236,7 @@ func (a *Adapter) handleTextUpdateMetric(w http.ResponseWriter, r *http.Request)
 	ok := isValidMetricType(metricType)
 
 	if !ok {
-		http.Error(w, "Supported metrics: gauge | counter", http.StatusBadRequest)
+		http.Error(w, "Supported metrics: gauge | counter | histogram", http.StatusBadRequest)
 		return
 	}
 
@@ -104,7 +246,17 @@ func (a *Adapter) handleTextUpdateMetric(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	a.metricService.SaveMetric(r.Context(), metricType, metricName, val)
+	_, err = a.metricService.SaveMetric(r.Context(), metricType, metricName, val)
+
+	if errors.Is(err, entities.ErrHistogramBoundsMismatch) || errors.Is(err, entities.ErrCounterOverflow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Write([]byte{})
 }
@@ -116,7 +268,7 @@ func (a *Adapter) handleTextGetMetric(w http.ResponseWriter, r *http.Request) {
 	ok := isValidMetricType(metricType)
 
 	if !ok {
-		http.Error(w, "Supported metrics: gauge | counter", http.StatusBadRequest)
+		http.Error(w, "Supported metrics: gauge | counter | histogram", http.StatusBadRequest)
 		return
 	}
 
@@ -137,9 +289,82 @@ func (a *Adapter) handleTextGetMetric(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(strconv.FormatFloat(val.Gauge, 'f', -1, 64)))
 	case constants.MetricTypeCounter:
 		w.Write([]byte(strconv.Itoa(int(val.Counter))))
+	case constants.MetricTypeHistogram:
+		result, err := json.Marshal(val.Histogram)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+
+		w.Write(result)
 	}
 }
 
+func (a *Adapter) handleDeleteMetric(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "metricType")
+	metricName := chi.URLParam(r, "metricName")
+
+	ok := isValidMetricType(metricType)
+
+	if !ok {
+		http.Error(w, "Supported metrics: gauge | counter | histogram", http.StatusBadRequest)
+		return
+	}
+
+	err := a.storage.DeleteMetric(r.Context(), metricType, metricName)
+
+	if storage.IsErrNotFound(err) {
+		http.Error(w, fmt.Sprintf("Not found metric: %s", metricName), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// handleDeletesMetric deletes series listed in json body, only id, type and labels of items are used
+func (a *Adapter) handleDeletesMetric(w http.ResponseWriter, r *http.Request) {
+	var metrics []entities.Metrics
+
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType != "application/json" {
+		http.Error(w, "need provide Content-Type: application/json", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, metric := range metrics {
+		if !isValidMetricType(metric.MType) {
+			http.Error(w, "Supported metrics: gauge | counter | histogram", http.StatusBadRequest)
+			return
+		}
+
+		if err := entities.ValidateLabels(metric.Labels); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := a.storage.DeleteMetricBatch(r.Context(), metrics); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte(""))
+}
+
 func (a *Adapter) handleUpdateMetric(w http.ResponseWriter, r *http.Request) {
 	var metrics entities.Metrics
 
@@ -158,7 +383,12 @@ func (a *Adapter) handleUpdateMetric(w http.ResponseWriter, r *http.Request) {
 	ok := isValidMetricType(metrics.MType)
 
 	if !ok {
-		http.Error(w, "Supported metrics: gauge | counter", http.StatusBadRequest)
+		http.Error(w, "Supported metrics: gauge | counter | histogram", http.StatusBadRequest)
+		return
+	}
+
+	if err := entities.ValidateLabels(metrics.Labels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -168,14 +398,19 @@ func (a *Adapter) handleUpdateMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedValue, err := a.metricService.SaveMetric(r.Context(), metrics.MType, metrics.ID, val)
+	updatedValue, err := a.metricService.SaveMetric(r.Context(), metrics.MType, metrics.SeriesID(), val)
+
+	if errors.Is(err, entities.ErrHistogramBoundsMismatch) || errors.Is(err, entities.ErrCounterOverflow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	result, err := marshalMetrics(metrics.MType, metrics.ID, updatedValue)
+	result, err := marshalMetrics(metrics, updatedValue)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -205,14 +440,14 @@ func (a *Adapter) handleGetMetric(w http.ResponseWriter, r *http.Request) {
 	ok := isValidMetricType(metrics.MType)
 
 	if !ok {
-		http.Error(w, "Supported metrics: gauge | counter", http.StatusBadRequest)
+		http.Error(w, "Supported metrics: gauge | counter | histogram", http.StatusBadRequest)
 		return
 	}
 
-	val, err := a.metricService.GetMetric(r.Context(), metrics.MType, metrics.ID)
+	val, err := a.metricService.GetMetric(r.Context(), metrics.MType, metrics.SeriesID())
 
 	if storage.IsErrNotFound(err) {
-		http.Error(w, fmt.Sprintf("Not found metric: %s", metrics.ID), http.StatusNotFound)
+		http.Error(w, fmt.Sprintf("Not found metric: %s", metrics.SeriesID()), http.StatusNotFound)
 		return
 	}
 
@@ -221,7 +456,7 @@ func (a *Adapter) handleGetMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := marshalMetrics(metrics.MType, metrics.ID, val)
+	result, err := marshalMetrics(metrics, val)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -246,13 +481,19 @@ func (a *Adapter) handleGetAllMetrics(w http.ResponseWriter, r *http.Request) {
 	htmlBuilder.WriteString("<h1>Gauge Metrics</h1><ul>")
 
 	for name, value := range metrics.Gauge {
-		htmlBuilder.WriteString(fmt.Sprintf("<li>%s: %f</li>", name, value))
+		htmlBuilder.WriteString(fmt.Sprintf("<li>%s: %f</li>", html.EscapeString(name), value))
 	}
 	htmlBuilder.WriteString("</ul>")
 
 	htmlBuilder.WriteString("<h1>Counter Metrics</h1><ul>")
 	for name, value := range metrics.Counter {
-		htmlBuilder.WriteString(fmt.Sprintf("<li>%s: %v</li>", name, value))
+		htmlBuilder.WriteString(fmt.Sprintf("<li>%s: %v</li>", html.EscapeString(name), value))
+	}
+	htmlBuilder.WriteString("</ul>")
+
+	htmlBuilder.WriteString("<h1>Histogram Metrics</h1><ul>")
+	for name, value := range metrics.Histogram {
+		htmlBuilder.WriteString(fmt.Sprintf("<li>%s: count=%d sum=%f buckets=%s</li>", html.EscapeString(name), value.Count, value.Sum, formatHistogramBuckets(value)))
 	}
 	htmlBuilder.WriteString("</ul></body></html>")
 
@@ -261,32 +502,78 @@ func (a *Adapter) handleGetAllMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(htmlBuilder.String()))
 }
 
-func New(metricService metricprocessor.MetricService, storage storage.Storage, logger logger.ILogger, signer signer.Signer) *Adapter {
+func (a *Adapter) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := a.metricService.GetAllMetrics(r.Context())
+
+	if err != nil {
+		http.Error(w, "Cannot find metrics", http.StatusInternalServerError)
+		return
+	}
+
+	var builder strings.Builder
+
+	err = writePrometheusMetrics(&builder, metrics)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", prometheusContentType)
+
+	w.Write([]byte(builder.String()))
+}
+
+func New(metricService metricprocessor.MetricService, storage storage.Storage, logger logger.ILogger, signer signer.Signer, replayGuard *shared.ReplayGuard, decryptor encryption.Decryptor) *Adapter {
 	return &Adapter{
 		metricService,
 		logger,
 		storage,
 		signer,
+		replayGuard,
+		decryptor,
 	}
 }
 
 func isValidMetricType(metricType string) bool {
-	if metricType != constants.MetricTypeGauge && metricType != constants.MetricTypeCounter {
-		return false
+	switch metricType {
+	case constants.MetricTypeGauge, constants.MetricTypeCounter, constants.MetricTypeHistogram:
+		return true
 	}
 
-	return true
+	return false
 }
 
-func marshalMetrics(metricType, metricName string, val metricprocessor.MetricValue) ([]byte, error) {
-	var body entities.Metrics
+// formatHistogramBuckets renders non-cumulative bucket counts, e.g. [le 0.1: 3, le 1: 5, le +Inf: 0]
+func formatHistogramBuckets(h entities.Histogram) string {
+	buckets := make([]string, 0, len(h.Counts))
 
-	if metricType == constants.MetricTypeGauge {
-		body = entities.Metrics{ID: metricName, MType: metricType, Value: &val.Gauge}
+	for i, count := range h.Counts {
+		bound := "+Inf"
+
+		if i < len(h.Bounds) {
+			bound = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+		}
+
+		buckets = append(buckets, fmt.Sprintf("le %s: %d", bound, count))
 	}
 
-	if metricType == constants.MetricTypeCounter {
-		body = entities.Metrics{ID: metricName, MType: metricType, Delta: &val.Counter}
+	return "[" + strings.Join(buckets, ", ") + "]"
+}
+
+func marshalMetrics(metric entities.Metrics, val metricprocessor.MetricValue) ([]byte, error) {
+	body := entities.Metrics{ID: metric.ID, MType: metric.MType, Labels: metric.Labels}
+
+	if metric.MType == constants.MetricTypeGauge {
+		body.Value = &val.Gauge
+	}
+
+	if metric.MType == constants.MetricTypeCounter {
+		body.Delta = &val.Counter
+	}
+
+	if metric.MType == constants.MetricTypeHistogram {
+		body.Histogram = &val.Histogram
 	}
 
 	return json.Marshal(body)
@@ -308,9 +595,63 @@ func parseMetricValue(metric entities.Metrics) (metricprocessor.MetricValue, err
 
 		return metricprocessor.MetricValue{Counter: *metric.Delta}, nil
 	}
+
+	if metric.MType == constants.MetricTypeHistogram {
+		if metric.Histogram == nil {
+			return metricprocessor.MetricValue{}, errors.New("metric value not provided: provide histogram with bounds, counts, sum and count")
+		}
+
+		if err := metric.Histogram.Validate(); err != nil {
+			return metricprocessor.MetricValue{}, err
+		}
+
+		return metricprocessor.MetricValue{Histogram: *metric.Histogram}, nil
+	}
 	return metricprocessor.MetricValue{}, fmt.Errorf("unknown metricType: %s", metric.MType)
 }
 
+// Unix timestamps are limited to years of RFC3339 timestamps, step to durations which fit time.Duration
+const (
+	minUnixSeconds = -62135596800
+	maxUnixSeconds = 253402300799
+	maxStepSeconds = float64(math.MaxInt64 / int64(time.Second))
+)
+
+// parseTimeParam parses RFC3339 time or unix timestamp in seconds with optional fraction
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("not provided")
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(seconds) || seconds < minUnixSeconds || seconds > maxUnixSeconds {
+			return time.Time{}, errors.New("unix timestamp out of range")
+		}
+
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseStepParam parses duration like 30s or number of seconds
+func parseStepParam(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, errors.New("not provided")
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(seconds) || math.Abs(seconds) > maxStepSeconds {
+			return 0, errors.New("step out of range")
+		}
+
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	return time.ParseDuration(value)
+}
+
 func parseString2MetricValue(metricType string, value string) (metricprocessor.MetricValue, error) {
 	if metricType == constants.MetricTypeGauge {
 		val, err := strconv.ParseFloat(value, 64)
@@ -329,5 +670,9 @@ func parseString2MetricValue(metricType string, value string) (metricprocessor.M
 
 		return metricprocessor.MetricValue{Counter: val}, nil
 	}
+
+	if metricType == constants.MetricTypeHistogram {
+		return metricprocessor.MetricValue{}, errors.New("histogram can be updated only with json: use POST /update/")
+	}
 	return metricprocessor.MetricValue{}, fmt.Errorf("unknown metricType: %s", metricType)
 }
@@ -1,11 +1,17 @@
 package metric_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-resty/resty/v2"
@@ -13,6 +19,7 @@ import (
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
 	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/metric"
+	"github.com/sodiqit/metricpulse.git/internal/server/config"
 	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
 	"github.com/sodiqit/metricpulse.git/internal/server/storage"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
@@ -35,7 +42,7 @@ func TestUpdateMetricHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -81,6 +88,50 @@ func TestUpdateMetricHandler(t *testing.T) {
 			expectedResult: `{"id":"temp","type":"counter","delta":23}`,
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "valid update metric with labels",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id": "temp", "type": "gauge", "value": 23.5, "labels": {"host": "web01"}}`,
+			returnValue:    metricprocessor.MetricValue{Gauge: 23.5},
+			contentType:    "application/json",
+			expectedResult: `{"id":"temp","type":"gauge","value":23.5,"labels":{"host":"web01"}}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "valid update histogram metric",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id": "latency", "type": "histogram", "histogram": {"bounds": [0.1, 1], "counts": [1, 2, 0], "sum": 1.05, "count": 3}}`,
+			returnValue:    metricprocessor.MetricValue{Histogram: entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{2, 4, 1}, Sum: 7.5, Count: 7}},
+			contentType:    "application/json",
+			expectedResult: `{"id":"latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[2,4,1],"sum":7.5,"count":7}}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "histogram counts do not match bounds",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id": "latency", "type": "histogram", "histogram": {"bounds": [0.1, 1], "counts": [1, 2], "sum": 1.05, "count": 3}}`,
+			contentType:    "application/json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "histogram value not provided",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id": "latency", "type": "histogram"}`,
+			contentType:    "application/json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid label name",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id": "temp", "type": "gauge", "value": 23.5, "labels": {"host-name": "web01"}}`,
+			contentType:    "application/json",
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "invalid metric type",
 			method:         http.MethodPost,
@@ -150,7 +201,7 @@ func TestGetMetricHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -269,7 +320,7 @@ func TestTextUpdateMetricHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -282,6 +333,7 @@ func TestTextUpdateMetricHandler(t *testing.T) {
 		name           string
 		method         string
 		url            string
+		saveErr        error
 		expectedStatus int
 	}{
 		{
@@ -296,6 +348,20 @@ func TestTextUpdateMetricHandler(t *testing.T) {
 			url:            "/update/counter/temp/10",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "counter overflow",
+			method:         http.MethodPost,
+			url:            "/update/counter/temp/10",
+			saveErr:        entities.ErrCounterOverflow,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "storage error",
+			method:         http.MethodPost,
+			url:            "/update/gauge/temp/23.5",
+			saveErr:        errors.New("storage is unavailable"),
+			expectedStatus: http.StatusInternalServerError,
+		},
 		{
 			name:           "invalid method",
 			method:         http.MethodGet,
@@ -312,8 +378,8 @@ func TestTextUpdateMetricHandler(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			if tc.expectedStatus == http.StatusOK {
-				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(metricprocessor.MetricValue{}, nil)
+			if tc.expectedStatus == http.StatusOK || tc.saveErr != nil {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(metricprocessor.MetricValue{}, tc.saveErr)
 			}
 
 			req := client.R()
@@ -327,6 +393,34 @@ func TestTextUpdateMetricHandler(t *testing.T) {
 			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
 		})
 	}
+
+	t.Run("should reject counter update overflowing int64", func(t *testing.T) {
+		memStorage := storage.NewMemStorage()
+
+		r := chi.NewRouter()
+		r.Mount("/", metric.New(metricprocessor.New(memStorage, &config.Config{}), memStorage, logger, nil, nil, nil).Route())
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		client := resty.New().SetBaseURL(ts.URL)
+
+		resp, err := client.R().Post(fmt.Sprintf("/update/counter/requests/%d", int64(math.MaxInt64-1)))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+		resp, err = client.R().Post("/update/counter/requests/1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+		resp, err = client.R().Post("/update/counter/requests/1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+
+		value, err := memStorage.GetCounterMetric(context.Background(), "requests")
+		require.NoError(t, err)
+		assert.Equal(t, int64(math.MaxInt64), value)
+	})
 }
 
 func TestTextGetMetricHandler(t *testing.T) {
@@ -343,7 +437,7 @@ func TestTextGetMetricHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -426,6 +520,87 @@ func TestTextGetMetricHandler(t *testing.T) {
 	}
 }
 
+func TestDeleteMetricHandler(t *testing.T) {
+	r := chi.NewRouter()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
+
+	r.Mount("/", c.Route())
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	client := resty.New().SetBaseURL(ts.URL)
+
+	tests := []struct {
+		name           string
+		url            string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "valid delete",
+			url:  "/value/gauge/temp",
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetric(gomock.Any(), constants.MetricTypeGauge, "temp").Times(1).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "delete series with labels",
+			url:  `/value/counter/PollCount{host="web01"}`,
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetric(gomock.Any(), constants.MetricTypeCounter, `PollCount{host="web01"}`).Times(1).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "not found metric",
+			url:  "/value/gauge/temp",
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetric(gomock.Any(), constants.MetricTypeGauge, "temp").Times(1).Return(storage.NewErrNotFound(errors.New(""), map[string]interface{}{}))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "storage error",
+			url:  "/value/gauge/temp",
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetric(gomock.Any(), constants.MetricTypeGauge, "temp").Times(1).Return(errors.New("delete error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "invalid metric type",
+			url:            "/value/invalid/temp",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			resp, err := client.R().Delete(tc.url)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+		})
+	}
+}
+
 func TestGetAllMetricsHandler(t *testing.T) {
 	r := chi.NewRouter()
 
@@ -440,7 +615,7 @@ func TestGetAllMetricsHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -456,6 +631,7 @@ func TestGetAllMetricsHandler(t *testing.T) {
 		setupMock           func()
 		expectedContentType string
 		expectedStatus      int
+		expectedBody        string
 	}{
 		{
 			name:   "valid result",
@@ -467,6 +643,19 @@ func TestGetAllMetricsHandler(t *testing.T) {
 			expectedContentType: "text/html",
 			expectedStatus:      http.StatusOK,
 		},
+		{
+			name:   "series names are escaped",
+			method: http.MethodGet,
+			url:    "/",
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{
+					Gauge: map[string]float64{`Alloc{host="<script>alert(1)</script>"}`: 1},
+				}, nil)
+			},
+			expectedContentType: "text/html",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        `Alloc{host=&#34;&lt;script&gt;alert(1)&lt;/script&gt;&#34;}`,
+		},
 		{
 			name:           "Invalid method",
 			method:         http.MethodPost,
@@ -492,6 +681,267 @@ func TestGetAllMetricsHandler(t *testing.T) {
 
 			if tc.expectedStatus == http.StatusOK {
 				assert.Equal(t, tc.expectedContentType, resp.Header().Get("Content-Type"))
+				assert.NotContains(t, resp.String(), "<script>")
+				assert.Contains(t, resp.String(), tc.expectedBody)
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsHandler(t *testing.T) {
+	r := chi.NewRouter()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
+
+	r.Mount("/", c.Route())
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	client := resty.New().SetBaseURL(ts.URL)
+
+	tests := []struct {
+		name           string
+		method         string
+		setupMock      func()
+		expectedResult string
+		expectedStatus int
+	}{
+		{
+			name:   "valid result",
+			method: http.MethodGet,
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{
+					Gauge:   map[string]float64{"HeapAlloc": 1024.5, "CPUutilization1": 3},
+					Counter: map[string]int64{"PollCount": 5},
+				}, nil)
+			},
+			expectedResult: "# TYPE CPUutilization1 gauge\nCPUutilization1 3\n" +
+				"# TYPE HeapAlloc gauge\nHeapAlloc 1024.5\n" +
+				"# TYPE PollCount counter\nPollCount 5\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "sanitize metric names",
+			method: http.MethodGet,
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{
+					Gauge: map[string]float64{"1cpu.load-avg": 0.5},
+				}, nil)
+			},
+			expectedResult: "# TYPE _1cpu_load_avg gauge\n_1cpu_load_avg 0.5\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "series with labels",
+			method: http.MethodGet,
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{
+					Gauge: map[string]float64{
+						`Alloc{env="prod",host="web02"}`: 2,
+						`Alloc{env="prod",host="web01"}`: 1,
+						"Alloc_total":                    3,
+					},
+				}, nil)
+			},
+			expectedResult: "# TYPE Alloc gauge\n" +
+				"Alloc{env=\"prod\",host=\"web01\"} 1\n" +
+				"Alloc{env=\"prod\",host=\"web02\"} 2\n" +
+				"# TYPE Alloc_total gauge\nAlloc_total 3\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "histogram",
+			method: http.MethodGet,
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{
+					Histogram: map[string]entities.Histogram{
+						`latency{host="web01"}`: {Bounds: []float64{0.1, 1}, Counts: []int64{1, 2, 1}, Sum: 3.5, Count: 4},
+					},
+				}, nil)
+			},
+			expectedResult: "# TYPE latency histogram\n" +
+				"latency_bucket{host=\"web01\",le=\"0.1\"} 1\n" +
+				"latency_bucket{host=\"web01\",le=\"1\"} 3\n" +
+				"latency_bucket{host=\"web01\",le=\"+Inf\"} 4\n" +
+				"latency_sum{host=\"web01\"} 3.5\n" +
+				"latency_count{host=\"web01\"} 4\n",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "storage error",
+			method: http.MethodGet,
+			setupMock: func() {
+				metricServiceMock.EXPECT().GetAllMetrics(gomock.Any()).Times(1).Return(entities.TotalMetrics{}, errors.New("error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Invalid method",
+			method:         http.MethodPost,
+			setupMock:      func() {},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			req := client.R()
+
+			req.Method = tc.method
+			req.URL = "/metrics"
+
+			resp, err := req.Send()
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedResult, string(resp.Body()))
+			}
+		})
+	}
+}
+
+func TestQueryRangeHandler(t *testing.T) {
+	r := chi.NewRouter()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
+
+	r.Mount("/", c.Route())
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	client := resty.New().SetBaseURL(ts.URL)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		url            string
+		setupMock      func()
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "valid query",
+			url:  `/api/v1/query_range?name=Alloc{host="web01"}&type=gauge&start=2024-01-01T12:00:00Z&end=1704110460&step=1m&agg=max`,
+			setupMock: func() {
+				metricServiceMock.EXPECT().QueryRange(gomock.Any(), metricprocessor.RangeQuery{
+					MetricType: constants.MetricTypeGauge,
+					MetricName: `Alloc{host="web01"}`,
+					Start:      start,
+					End:        start.Add(time.Minute),
+					Step:       time.Minute,
+					Agg:        metricprocessor.AggMax,
+				}).Times(1).Return([]metricprocessor.RangePoint{{Timestamp: start, Value: 1.5}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"name": "Alloc{host=\"web01\"}", "type": "gauge", "agg": "max", "step": 60, "points": [{"timestamp": "2024-01-01T12:00:00Z", "value": 1.5}]}`,
+		},
+		{
+			name: "avg by default",
+			url:  "/api/v1/query_range?name=PollCount&type=counter&start=1704110400&end=1704110460&step=30",
+			setupMock: func() {
+				metricServiceMock.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(_ context.Context, query metricprocessor.RangeQuery) ([]metricprocessor.RangePoint, error) {
+					assert.Equal(t, metricprocessor.AggAvg, query.Agg)
+					assert.Equal(t, 30*time.Second, query.Step)
+					return []metricprocessor.RangePoint{}, nil
+				})
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid start",
+			url:            "/api/v1/query_range?name=Alloc&type=gauge&start=yesterday&end=1704110460&step=1m",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "start is not a number",
+			url:            "/api/v1/query_range?name=Alloc&type=gauge&start=NaN&end=1704110460&step=1m",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "infinite end",
+			url:            "/api/v1/query_range?name=Alloc&type=gauge&start=1704110400&end=%2BInf&step=1m",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "start out of range",
+			url:            "/api/v1/query_range?name=Alloc&type=gauge&start=1e300&end=1704110460&step=1m",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "step out of range",
+			url:            "/api/v1/query_range?name=Alloc&type=gauge&start=1704110400&end=1704110460&step=1e300",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing step",
+			url:            "/api/v1/query_range?name=Alloc&type=gauge&start=1704110400&end=1704110460",
+			setupMock:      func() {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid query",
+			url:  "/api/v1/query_range?name=Alloc&type=gauge&start=1704110400&end=1704110460&step=1m&agg=rate",
+			setupMock: func() {
+				metricServiceMock.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Times(1).Return(nil, fmt.Errorf("%w: rate is supported only for counter and histogram", metricprocessor.ErrInvalidQuery))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "storage error",
+			url:  "/api/v1/query_range?name=Alloc&type=gauge&start=1704110400&end=1704110460&step=1m",
+			setupMock: func() {
+				metricServiceMock.EXPECT().QueryRange(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			resp, err := client.R().Get(tc.url)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, resp.String())
 			}
 		})
 	}
@@ -511,7 +961,7 @@ func TestPingHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -586,7 +1036,7 @@ func TestBatchUpdatesMetricHandler(t *testing.T) {
 		log.Fatalf(err.Error())
 	}
 
-	c := metric.New(metricServiceMock, storageMock, logger, nil)
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 	r.Mount("/", c.Route())
 
@@ -603,6 +1053,7 @@ func TestBatchUpdatesMetricHandler(t *testing.T) {
 		setupMock           func()
 		expectedContentType string
 		expectedStatus      int
+		expectedBody        string
 	}{
 		{
 			name:   "valid batch update",
@@ -621,9 +1072,47 @@ func TestBatchUpdatesMetricHandler(t *testing.T) {
 				}
 			]`,
 			setupMock: func() {
-				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				counter, gauge := int64(150), 200.123125
+
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return([]entities.Metrics{
+					{ID: "test", MType: constants.MetricTypeCounter, Delta: &counter},
+					{ID: "test", MType: constants.MetricTypeGauge, Value: &gauge},
+				}, nil)
 			},
 			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id": "test", "type": "counter", "delta": 150}, {"id": "test", "type": "gauge", "value": 200.123125}]`,
+		},
+		{
+			name:   "missing value",
+			method: http.MethodPost,
+			url:    "/updates/",
+			body:   `[{"id": "test", "type": "counter"}, {"id": "temp", "type": "gauge", "value": 1.5}]`,
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `[{"id": "test", "type": "counter", "error": "metric value not provided: provide int64"}, {"id": "temp", "type": "gauge"}]`,
+		},
+		{
+			name:   "unknown metric type",
+			method: http.MethodPost,
+			url:    "/updates/",
+			body:   `[{"id": "test", "type": "unknown", "delta": 100}]`,
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `[{"id": "test", "type": "unknown", "error": "unknown metricType: unknown"}]`,
+		},
+		{
+			name:   "invalid label name",
+			method: http.MethodPost,
+			url:    "/updates/",
+			body:   `[{"id": "test", "type": "counter", "delta": 100, "labels": {"1host": "web01"}}]`,
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatus: http.StatusBadRequest,
 		},
 		{
 			name:   "invalid update",
@@ -631,10 +1120,31 @@ func TestBatchUpdatesMetricHandler(t *testing.T) {
 			body:   `[{"id": "test", "type": "counter", "delta": 100}]`,
 			url:    "/updates/",
 			setupMock: func() {
-				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(errors.New("save error"))
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("save error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			name:   "counter overflow",
+			method: http.MethodPost,
+			body:   `[{"id": "test", "type": "counter", "delta": 100}]`,
+			url:    "/updates/",
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, fmt.Errorf("save error: %w", entities.ErrCounterOverflow))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "rejected batch item",
+			method: http.MethodPost,
+			body:   `[{"id": "temp", "type": "gauge", "value": 1.5}, {"id": "test", "type": "counter", "delta": 100}]`,
+			url:    "/updates/",
+			setupMock: func() {
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, storage.NewErrBatchItem(1, entities.ErrCounterOverflow))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `[{"id": "temp", "type": "gauge"}, {"id": "test", "type": "counter", "error": "counter overflow"}]`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -651,6 +1161,88 @@ func TestBatchUpdatesMetricHandler(t *testing.T) {
 
 			require.NoError(t, err)
 			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, resp.String())
+			}
+		})
+	}
+}
+
+func TestBatchDeletesMetricHandler(t *testing.T) {
+	r := chi.NewRouter()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	storageMock := storage.NewMockStorage(ctrl)
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
+
+	r.Mount("/", c.Route())
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	client := resty.New().SetBaseURL(ts.URL).SetHeader("Content-Type", "application/json")
+
+	tests := []struct {
+		name           string
+		body           string
+		setupMock      func()
+		expectedStatus int
+	}{
+		{
+			name: "valid batch delete",
+			body: `[{"id": "Alloc", "type": "gauge", "labels": {"host": "web01"}}, {"id": "PollCount", "type": "counter"}]`,
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetricBatch(gomock.Any(), []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}},
+					{ID: "PollCount", MType: constants.MetricTypeCounter},
+				}).Times(1).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "invalid metric type",
+			body: `[{"id": "Alloc", "type": "invalid"}]`,
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetricBatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid body",
+			body: `{"id": "Alloc"`,
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetricBatch(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "storage error",
+			body: `[{"id": "Alloc", "type": "gauge"}]`,
+			setupMock: func() {
+				storageMock.EXPECT().DeleteMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(errors.New("delete error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			resp, err := client.R().SetBody(tc.body).Post("/deletes/")
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
 		})
 	}
 }
@@ -691,13 +1283,13 @@ func TestSetupSignerInAdapter(t *testing.T) {
 			name: "should validate sign if provide signer",
 			setupSuite: func() *httptest.Server {
 				r := chi.NewRouter()
-				c := metric.New(metricServiceMock, storageMock, logger, signerMock)
+				c := metric.New(metricServiceMock, storageMock, logger, signerMock, nil, nil)
 
 				r.Mount("/", c.Route())
 
 				ts := httptest.NewServer(r)
 
-				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
 				signerMock.EXPECT().Verify(gomock.Any(), "test-signature").Times(1).Return(true)
 				signerMock.EXPECT().Sign(gomock.Any()).MinTimes(1).Return("signature")
 
@@ -708,13 +1300,13 @@ func TestSetupSignerInAdapter(t *testing.T) {
 			name: "should not validate sign if signer not provided",
 			setupSuite: func() *httptest.Server {
 				r := chi.NewRouter()
-				c := metric.New(metricServiceMock, storageMock, logger, nil)
+				c := metric.New(metricServiceMock, storageMock, logger, nil, nil, nil)
 
 				r.Mount("/", c.Route())
 
 				ts := httptest.NewServer(r)
 
-				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				storageMock.EXPECT().SaveMetricBatch(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
 				signerMock.EXPECT().Verify(gomock.Any(), gomock.Any()).Times(0)
 				signerMock.EXPECT().Sign(gomock.Any()).Times(0)
 
@@ -735,3 +1327,81 @@ func TestSetupSignerInAdapter(t *testing.T) {
 		})
 	}
 }
+
+func newUpdatesRouter(tb testing.TB) (*chi.Mux, *storage.MemStorage) {
+	logger, err := logger.Initialize("error")
+	require.NoError(tb, err)
+
+	store := storage.NewMemStorage()
+	c := metric.New(metricprocessor.New(store, &config.Config{}), store, logger, nil, nil, nil)
+
+	r := chi.NewRouter()
+	r.Mount("/", c.Route())
+
+	return r, store
+}
+
+func newUpdatesRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	return req
+}
+
+const updatesBody = `[{"id": "PollCount", "type": "counter", "delta": 1}, {"id": "Alloc", "type": "gauge", "value": 1.5}]`
+
+func TestBatchUpdatesMetricHandler_Parallel(t *testing.T) {
+	r, store := newUpdatesRouter(t)
+
+	const workers, requests = 8, 100
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < requests; j++ {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, newUpdatesRequest(updatesBody))
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}()
+
+		// html page iterates all metrics while they are updated
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < requests; j++ {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	val, err := store.GetCounterMetric(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers*requests), val)
+}
+
+func BenchmarkBatchUpdatesMetricHandler(b *testing.B) {
+	r, _ := newUpdatesRouter(b)
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, newUpdatesRequest(updatesBody))
+
+			if w.Code != http.StatusOK {
+				b.Fatalf("unexpected status: %d", w.Code)
+			}
+		}
+	})
+}
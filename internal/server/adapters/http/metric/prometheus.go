@@ -0,0 +1,186 @@
+package metric
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// sanitizePrometheusName converts metric name to valid prometheus identifier: [a-zA-Z_:][a-zA-Z0-9_:]*
+func sanitizePrometheusName(name string) string {
+	var b strings.Builder
+
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == ':'
+		isDigit := r >= '0' && r <= '9'
+
+		switch {
+		case isLetter:
+			b.WriteRune(r)
+		case isDigit && i == 0:
+			b.WriteRune('_')
+			b.WriteRune(r)
+		case isDigit:
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
+
+// formatPrometheusLabels renders labels sorted by name, e.g. {env="prod",host="web01"}
+func formatPrometheusLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	b.WriteByte('{')
+
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[key])
+
+		fmt.Fprintf(&b, "%s=\"%s\"", sanitizePrometheusName(key), value)
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+type prometheusSeries[T any] struct {
+	name      string
+	labels    map[string]string
+	rawLabels string
+	value     T
+}
+
+// collectPrometheusSeries splits series ids into names and labels and sorts them by name,
+// so series of one metric are written together under a single TYPE line
+func collectPrometheusSeries[T any](values map[string]T) []prometheusSeries[T] {
+	series := make([]prometheusSeries[T], 0, len(values))
+
+	for id, value := range values {
+		name, labels := entities.ParseSeriesID(id)
+		series = append(series, prometheusSeries[T]{sanitizePrometheusName(name), labels, formatPrometheusLabels(labels), value})
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].name != series[j].name {
+			return series[i].name < series[j].name
+		}
+
+		return series[i].rawLabels < series[j].rawLabels
+	})
+
+	return series
+}
+
+func writePrometheusType[T any](w io.Writer, metricType string, series []prometheusSeries[T], i int) error {
+	if i > 0 && series[i-1].name == series[i].name {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "# TYPE %s %s\n", series[i].name, metricType)
+
+	return err
+}
+
+func writePrometheusSeries[T any](w io.Writer, metricType string, values map[string]T, format func(T) string) error {
+	series := collectPrometheusSeries(values)
+
+	for i, s := range series {
+		if err := writePrometheusType(w, metricType, series, i); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", s.name, s.rawLabels, format(s.value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writePrometheusHistograms renders histograms as cumulative _bucket series with le label plus _sum and _count
+func writePrometheusHistograms(w io.Writer, histograms map[string]entities.Histogram) error {
+	series := collectPrometheusSeries(histograms)
+
+	for i, s := range series {
+		if err := writePrometheusType(w, constants.MetricTypeHistogram, series, i); err != nil {
+			return err
+		}
+
+		bucketLabels := map[string]string{}
+		for key, value := range s.labels {
+			bucketLabels[key] = value
+		}
+
+		var cumulative int64
+
+		for j, count := range s.value.Counts {
+			cumulative += count
+
+			bucketLabels["le"] = "+Inf"
+
+			if j < len(s.value.Bounds) {
+				bucketLabels["le"] = formatPrometheusFloat(s.value.Bounds[j])
+			}
+
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, formatPrometheusLabels(bucketLabels), cumulative); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", s.name, s.rawLabels, formatPrometheusFloat(s.value.Sum), s.name, s.rawLabels, s.value.Count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatPrometheusFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writePrometheusMetrics renders metrics in prometheus text exposition format sorted by name
+func writePrometheusMetrics(w io.Writer, metrics entities.TotalMetrics) error {
+	if err := writePrometheusSeries(w, constants.MetricTypeGauge, metrics.Gauge, formatPrometheusFloat); err != nil {
+		return err
+	}
+
+	err := writePrometheusSeries(w, constants.MetricTypeCounter, metrics.Counter, func(v int64) string {
+		return strconv.FormatInt(v, 10)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return writePrometheusHistograms(w, metrics.Histogram)
+}
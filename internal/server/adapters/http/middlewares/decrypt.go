@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
+)
+
+// WithDecryptor decrypts body of requests which have encryption header with scheme of the decryptor.
+// Requests without the header are passed as is
+func WithDecryptor(decryptor encryption.Decryptor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme := r.Header.Get(constants.EncryptionHeader)
+
+			if scheme == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if scheme != decryptor.Scheme() {
+				http.Error(w, "Unsupported encryption scheme", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+
+			if err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+
+			plain, err := decryptor.Decrypt(body)
+
+			if err != nil {
+				http.Error(w, "Invalid encrypted body", http.StatusBadRequest)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(plain))
+			r.ContentLength = int64(len(plain))
+			r.Header.Del(constants.EncryptionHeader)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
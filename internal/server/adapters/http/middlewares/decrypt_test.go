@@ -0,0 +1,114 @@
+package middlewares_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-resty/resty/v2"
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/middlewares"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptorMiddleware(t *testing.T) {
+	client := resty.New()
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	otherKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+
+	r.Use(middlewares.WithDecryptor(encryption.NewX25519Decryptor(key)))
+
+	r.Post("/test", func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			http.Error(w, "error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("X-Encryption", r.Header.Get(constants.EncryptionHeader))
+		w.Write(b)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	encrypt := func(key *ecdh.PrivateKey, body string) []byte {
+		encrypted, err := encryption.NewX25519Encryptor(key.PublicKey()).Encrypt([]byte(body))
+		require.NoError(t, err)
+
+		return encrypted
+	}
+
+	tests := []struct {
+		name           string
+		body           []byte
+		scheme         string
+		expectedStatus int
+		expectedResult string
+	}{
+		{
+			name:           "should decrypt body",
+			body:           encrypt(key, `{"test": true}`),
+			scheme:         encryption.SchemeX25519,
+			expectedStatus: http.StatusOK,
+			expectedResult: `{"test": true}`,
+		},
+		{
+			name:           "should pass body as is if encryption header not provided",
+			body:           []byte(`{"test": true}`),
+			expectedStatus: http.StatusOK,
+			expectedResult: `{"test": true}`,
+		},
+		{
+			name:           "should return error if body encrypted with another key",
+			body:           encrypt(otherKey, `{"test": true}`),
+			scheme:         encryption.SchemeX25519,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should return error if body is not encrypted",
+			body:           []byte(`{"test": true}`),
+			scheme:         encryption.SchemeX25519,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should return error if scheme is not supported",
+			body:           encrypt(key, `{"test": true}`),
+			scheme:         encryption.SchemeRSA,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := client.R().SetBody(tc.body)
+
+			if tc.scheme != "" {
+				req.SetHeader(constants.EncryptionHeader, tc.scheme)
+			}
+
+			resp, err := req.Post(ts.URL + "/test")
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedResult, resp.String())
+				assert.Empty(t, resp.Header().Get("X-Encryption"))
+			}
+		})
+	}
+}
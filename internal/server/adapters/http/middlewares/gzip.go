@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
 )
 
 type compressWriter struct {
@@ -66,20 +68,11 @@ func (c *compressReader) Close() error {
 	return c.zr.Close()
 }
 
-func supportsGzip(headers http.Header) bool {
-	for _, encoding := range headers["Accept-Encoding"] {
-		if strings.Contains(encoding, "gzip") {
-			return true
-		}
-	}
-	return false
-}
-
 func Gzip(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		writer := w
 
-		if supportsGzip(r.Header) {
+		if shared.AcceptsGzip(r.Header.Values("Accept-Encoding")) {
 			w.Header().Set("Content-Encoding", "gzip")
 			compressWriter := newCompressWriter(w)
 			writer = compressWriter
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
 )
 
 type (
@@ -44,18 +45,17 @@ func WithLogger(logger logger.ILogger) func(http.Handler) http.Handler {
 				responseData:   responseData,
 			}
 
-			next.ServeHTTP(lw, r)
+			ctx, info := shared.WithRequestInfo(r.Context())
 
-			duration := time.Since(start)
+			next.ServeHTTP(lw, r.WithContext(ctx))
 
-			logger.Infow(
-				"New request",
-				"uri", r.RequestURI,
-				"method", r.Method,
-				"status", lw.responseData.status,
-				"duration", duration.String(),
-				"size", lw.responseData.size,
-			)
+			info.URI = r.RequestURI
+			info.Method = r.Method
+			info.Status = lw.responseData.status
+			info.Duration = time.Since(start)
+			info.Size = lw.responseData.size
+
+			shared.LogRequest(logger, *info)
 		})
 	}
 }
@@ -2,10 +2,12 @@ package middlewares
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 
 	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
 )
 
@@ -26,10 +28,12 @@ func (r *hashResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
-func WithSignValidator(signer signer.Signer) func(http.Handler) http.Handler {
+// WithSignValidator verifies signature of every request which changes state. Timestamp and nonce of the request
+// are signed with body if provided, guard rejects stale and replayed requests. Guard is optional
+func WithSignValidator(signer signer.Signer, guard *shared.ReplayGuard) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != http.MethodPost {
+			if isSafeMethod(r.Method) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -42,16 +46,48 @@ func WithSignValidator(signer signer.Signer) func(http.Handler) http.Handler {
 
 			r.Body = io.NopCloser(bytes.NewBuffer(body))
 
-			signature := r.Header.Get(constants.HashHeader)
+			err = shared.VerifyStampedSignature(
+				r.Context(),
+				signer,
+				guard,
+				SignedBody(r.Method, r.URL.RequestURI(), body),
+				r.Header.Get(constants.HashHeader),
+				r.Header.Get(constants.TimestampHeader),
+				r.Header.Get(constants.NonceHeader),
+			)
 
-			if !signer.Verify(body, signature) {
+			if errors.Is(err, shared.ErrInvalidSignature) {
 				http.Error(w, "Invalid signature", http.StatusBadRequest)
 				return
 			}
 
+			if err != nil {
+				http.Error(w, "Invalid request stamp: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
 			hw := &hashResponseWriter{ResponseWriter: w, signer: signer}
 
 			next.ServeHTTP(hw, r)
 		})
 	}
 }
+
+// SignedBody returns data signed by client. POST request signs its body only; other methods
+// sign method and uri with body, so signature of DELETE can't be reused for another series
+func SignedBody(method string, uri string, body []byte) []byte {
+	if method == http.MethodPost {
+		return body
+	}
+
+	return append([]byte(method+" "+uri+"\n"), body...)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
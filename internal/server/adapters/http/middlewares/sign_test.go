@@ -5,11 +5,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-resty/resty/v2"
 	"github.com/sodiqit/metricpulse.git/internal/constants"
 	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/middlewares"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +23,7 @@ func setupSuite(config *config.Config) (*httptest.Server, signer.Signer) {
 
 	s := signer.NewSHA256Signer(config.SecretKey)
 
-	r.Use(middlewares.WithSignValidator(s))
+	r.Use(middlewares.WithSignValidator(s, nil))
 
 	r.Post("/test", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
@@ -146,3 +148,102 @@ func TestSignValidatorMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestSignValidatorMiddleware_Replay(t *testing.T) {
+	client := resty.New()
+
+	s := signer.NewSHA256Signer("test")
+
+	r := chi.NewRouter()
+	r.Use(middlewares.WithSignValidator(s, shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10})))
+	r.Post("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	body := []byte(`{"test": true}`)
+
+	send := func(stamp signer.Stamp, signature string) *resty.Response {
+		resp, err := client.R().
+			SetHeader(constants.HashHeader, signature).
+			SetHeader(constants.TimestampHeader, stamp.TimestampString()).
+			SetHeader(constants.NonceHeader, stamp.Nonce).
+			SetBody(body).
+			Post(ts.URL + "/test")
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	stamp, err := signer.NewStamp(time.Now())
+	require.NoError(t, err)
+
+	signature := s.Sign(stamp.Material(body))
+
+	assert.Equal(t, http.StatusOK, send(stamp, signature).StatusCode())
+	assert.Equal(t, http.StatusBadRequest, send(stamp, signature).StatusCode(), "replayed request")
+
+	staleStamp, err := signer.NewStamp(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, send(staleStamp, s.Sign(staleStamp.Material(body))).StatusCode(), "stale request")
+
+	freshStamp, err := signer.NewStamp(time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, send(freshStamp, signature).StatusCode(), "signature of another stamp")
+}
+
+func TestSignValidatorMiddleware_Delete(t *testing.T) {
+	client := resty.New()
+
+	s := signer.NewSHA256Signer("test")
+
+	r := chi.NewRouter()
+	r.Use(middlewares.WithSignValidator(s, shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10})))
+	r.Delete("/value/{metricType}/{metricName}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("deleted"))
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	sign := func(uri string) (signer.Stamp, string) {
+		stamp, err := signer.NewStamp(time.Now())
+		require.NoError(t, err)
+
+		return stamp, s.Sign(stamp.Material(middlewares.SignedBody(http.MethodDelete, uri, nil)))
+	}
+
+	tests := []struct {
+		name           string
+		uri            string
+		signedURI      string
+		sign           bool
+		expectedStatus int
+	}{
+		{name: "should delete if request is signed", uri: "/value/gauge/temp", signedURI: "/value/gauge/temp", sign: true, expectedStatus: http.StatusOK},
+		{name: "should return error if request is not signed", uri: "/value/gauge/temp", expectedStatus: http.StatusBadRequest},
+		{name: "should return error if signature is made for another series", uri: "/value/gauge/other", signedURI: "/value/gauge/temp", sign: true, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := client.R()
+
+			if tc.sign {
+				stamp, signature := sign(tc.signedURI)
+
+				req.SetHeader(constants.HashHeader, signature).
+					SetHeader(constants.TimestampHeader, stamp.TimestampString()).
+					SetHeader(constants.NonceHeader, stamp.Nonce)
+			}
+
+			resp, err := req.Delete(ts.URL + tc.uri)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package shared
+
+import "strings"
+
+// AcceptsGzip reports whether client accepts gzip response. Every value may list several encodings
+func AcceptsGzip(encodings []string) bool {
+	for _, encoding := range encodings {
+		if strings.Contains(encoding, "gzip") {
+			return true
+		}
+	}
+
+	return false
+}
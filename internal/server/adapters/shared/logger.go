@@ -0,0 +1,58 @@
+package shared
+
+import (
+	"context"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+)
+
+// RequestInfo describes served request independently of transport
+type RequestInfo struct {
+	URI      string
+	Method   string
+	Status   interface{}
+	Duration time.Duration
+	Size     int
+	// KeyID is id of the key which verified request signature
+	KeyID string
+	// Unstamped is set for signed request accepted without timestamp and nonce
+	Unstamped bool
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns context carrying info of the request. Middlewares fill in details known only to them
+func WithRequestInfo(ctx context.Context) (context.Context, *RequestInfo) {
+	info := &RequestInfo{}
+
+	return context.WithValue(ctx, requestInfoKey{}, info), info
+}
+
+func requestInfo(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+
+	return info
+}
+
+func LogRequest(logger logger.ILogger, info RequestInfo) {
+	fields := []interface{}{
+		"uri", info.URI,
+		"method", info.Method,
+		"status", info.Status,
+		"duration", info.Duration.String(),
+		"size", info.Size,
+	}
+
+	if info.KeyID != "" {
+		fields = append(fields, "key_id", info.KeyID)
+	}
+
+	// such requests can be replayed, warning shows which agents still need update
+	if info.Unstamped {
+		logger.Warnw("New request signed without timestamp and nonce", fields...)
+		return
+	}
+
+	logger.Infow("New request", fields...)
+}
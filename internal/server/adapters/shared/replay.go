@@ -0,0 +1,159 @@
+package shared
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+var (
+	ErrMissingStamp    = errors.New("signed request has no timestamp and nonce")
+	ErrStaleRequest    = errors.New("request timestamp is outside of allowed window")
+	ErrReplayedRequest = errors.New("request nonce is already used")
+)
+
+type ReplayGuardOptions struct {
+	// Window is allowed clock skew between agent and server, nonces are remembered for it
+	Window time.Duration
+	// CacheSize is max number of remembered nonces
+	CacheSize int
+	// AllowUnstamped accepts signed requests without timestamp and nonce sent by agents of previous versions.
+	// Only body of such requests is verified, so they can be replayed
+	AllowUnstamped bool
+}
+
+// ReplayGuard rejects signed requests with stale timestamp or with nonce seen within the window.
+// When the cache is full the oldest nonce is evicted and requests which are not newer than it are rejected,
+// so eviction never lets request to be replayed
+type ReplayGuard struct {
+	window         time.Duration
+	cacheSize      int
+	allowUnstamped bool
+
+	mu     sync.Mutex
+	nonces map[string]struct{}
+	queue  stampQueue
+	// floor is timestamp of the newest evicted stamp which was not expired yet
+	floor int64
+}
+
+func (g *ReplayGuard) Check(stamp signer.Stamp) error {
+	now := time.Now()
+
+	if stamp.Timestamp < now.Add(-g.window).Unix() || stamp.Timestamp > now.Add(g.window).Unix() {
+		return ErrStaleRequest
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.evictExpired(now)
+
+	if stamp.Timestamp <= g.floor {
+		return ErrStaleRequest
+	}
+
+	if _, ok := g.nonces[stamp.Nonce]; ok {
+		return ErrReplayedRequest
+	}
+
+	if len(g.queue) >= g.cacheSize {
+		oldest := heap.Pop(&g.queue).(signer.Stamp)
+		delete(g.nonces, oldest.Nonce)
+		g.floor = oldest.Timestamp
+
+		if stamp.Timestamp <= g.floor {
+			return ErrStaleRequest
+		}
+	}
+
+	g.nonces[stamp.Nonce] = struct{}{}
+	heap.Push(&g.queue, stamp)
+
+	return nil
+}
+
+// evictExpired removes nonces with timestamp outside of the window, such requests are rejected by timestamp
+func (g *ReplayGuard) evictExpired(now time.Time) {
+	oldest := now.Add(-g.window).Unix()
+
+	for len(g.queue) > 0 && g.queue[0].Timestamp < oldest {
+		expired := heap.Pop(&g.queue).(signer.Stamp)
+		delete(g.nonces, expired.Nonce)
+	}
+}
+
+func NewReplayGuard(options ReplayGuardOptions) *ReplayGuard {
+	cacheSize := options.CacheSize
+
+	if cacheSize < 1 {
+		cacheSize = 1
+	}
+
+	return &ReplayGuard{
+		window:         options.Window,
+		cacheSize:      cacheSize,
+		allowUnstamped: options.AllowUnstamped,
+		nonces:         make(map[string]struct{}),
+	}
+}
+
+// VerifyStampedSignature verifies signature of request with timestamp and nonce. Stamp is checked by guard
+// only after signature, so forged requests don't fill the nonce cache. Guard is optional, without it
+// requests without stamp are accepted
+func VerifyStampedSignature(ctx context.Context, s signer.Signer, guard *ReplayGuard, body []byte, signature string, timestamp string, nonce string) error {
+	if timestamp == "" && nonce == "" {
+		if guard != nil && !guard.allowUnstamped {
+			return ErrMissingStamp
+		}
+
+		if err := VerifySignature(ctx, s, body, signature); err != nil {
+			return err
+		}
+
+		if info := requestInfo(ctx); info != nil {
+			info.Unstamped = true
+		}
+
+		return nil
+	}
+
+	stamp, err := signer.ParseStamp(timestamp, nonce)
+
+	if err != nil {
+		return err
+	}
+
+	if err := VerifySignature(ctx, s, stamp.Material(body), signature); err != nil {
+		return err
+	}
+
+	if guard == nil {
+		return nil
+	}
+
+	return guard.Check(stamp)
+}
+
+// stampQueue is min-heap of stamps by timestamp
+type stampQueue []signer.Stamp
+
+func (q stampQueue) Len() int           { return len(q) }
+func (q stampQueue) Less(i, j int) bool { return q[i].Timestamp < q[j].Timestamp }
+func (q stampQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *stampQueue) Push(x interface{}) {
+	*q = append(*q, x.(signer.Stamp))
+}
+
+func (q *stampQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
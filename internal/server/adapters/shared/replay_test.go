@@ -0,0 +1,120 @@
+package shared_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayGuard_Check(t *testing.T) {
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name  string
+		tBody func(guard *shared.ReplayGuard)
+	}{
+		{
+			name: "should accept new nonce and reject it when it is seen again",
+			tBody: func(guard *shared.ReplayGuard) {
+				assert.NoError(t, guard.Check(signer.Stamp{Timestamp: now, Nonce: "a"}))
+				assert.NoError(t, guard.Check(signer.Stamp{Timestamp: now, Nonce: "b"}))
+				assert.ErrorIs(t, guard.Check(signer.Stamp{Timestamp: now, Nonce: "a"}), shared.ErrReplayedRequest)
+			},
+		},
+		{
+			name: "should reject timestamp outside of window",
+			tBody: func(guard *shared.ReplayGuard) {
+				assert.ErrorIs(t, guard.Check(signer.Stamp{Timestamp: now - 120, Nonce: "a"}), shared.ErrStaleRequest)
+				assert.ErrorIs(t, guard.Check(signer.Stamp{Timestamp: now + 120, Nonce: "b"}), shared.ErrStaleRequest)
+				assert.NoError(t, guard.Check(signer.Stamp{Timestamp: now - 30, Nonce: "c"}))
+				assert.NoError(t, guard.Check(signer.Stamp{Timestamp: now + 30, Nonce: "d"}))
+			},
+		},
+		{
+			name: "should reject requests not newer than evicted nonce when cache is full",
+			tBody: func(guard *shared.ReplayGuard) {
+				for i := 0; i < 3; i++ {
+					require.NoError(t, guard.Check(signer.Stamp{Timestamp: now - 10 + int64(i), Nonce: fmt.Sprint("n", i)}))
+				}
+
+				// evicts n0, its replay is rejected by timestamp
+				assert.NoError(t, guard.Check(signer.Stamp{Timestamp: now, Nonce: "n3"}))
+				assert.ErrorIs(t, guard.Check(signer.Stamp{Timestamp: now - 10, Nonce: "n0"}), shared.ErrStaleRequest)
+
+				assert.ErrorIs(t, guard.Check(signer.Stamp{Timestamp: now - 9, Nonce: "n1"}), shared.ErrReplayedRequest)
+
+				// evicts n1
+				assert.NoError(t, guard.Check(signer.Stamp{Timestamp: now, Nonce: "n4"}))
+				assert.ErrorIs(t, guard.Check(signer.Stamp{Timestamp: now - 9, Nonce: "n1"}), shared.ErrStaleRequest)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody(shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 3}))
+		})
+	}
+}
+
+func TestVerifyStampedSignature(t *testing.T) {
+	ctx := context.Background()
+	body := []byte(`{"test": true}`)
+	s := signer.NewSHA256Signer("test")
+
+	stamp, err := signer.NewStamp(time.Now())
+	require.NoError(t, err)
+
+	signature := s.Sign(stamp.Material(body))
+
+	t.Run("should verify body signed with timestamp and nonce", func(t *testing.T) {
+		guard := shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10})
+
+		err := shared.VerifyStampedSignature(ctx, s, guard, body, signature, stamp.TimestampString(), stamp.Nonce)
+		assert.NoError(t, err)
+
+		err = shared.VerifyStampedSignature(ctx, s, guard, body, signature, stamp.TimestampString(), stamp.Nonce)
+		assert.ErrorIs(t, err, shared.ErrReplayedRequest)
+	})
+
+	t.Run("should not remember nonce of request with invalid signature", func(t *testing.T) {
+		guard := shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10})
+
+		err := shared.VerifyStampedSignature(ctx, s, guard, []byte("forged"), signature, stamp.TimestampString(), stamp.Nonce)
+		assert.ErrorIs(t, err, shared.ErrInvalidSignature)
+
+		err = shared.VerifyStampedSignature(ctx, s, guard, body, signature, stamp.TimestampString(), stamp.Nonce)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject signature if timestamp is changed", func(t *testing.T) {
+		err := shared.VerifyStampedSignature(ctx, s, nil, body, signature, fmt.Sprint(stamp.Timestamp+1), stamp.Nonce)
+		assert.ErrorIs(t, err, shared.ErrInvalidSignature)
+	})
+
+	t.Run("should reject invalid nonce", func(t *testing.T) {
+		for _, nonce := range []string{"a:b", "nonce with spaces", string(make([]byte, signer.MaxNonceLength+1))} {
+			err := shared.VerifyStampedSignature(ctx, s, nil, body, signature, stamp.TimestampString(), nonce)
+			assert.ErrorIs(t, err, signer.ErrInvalidStamp)
+		}
+	})
+
+	t.Run("should verify request without stamp only if it is allowed", func(t *testing.T) {
+		required := shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10})
+		optional := shared.NewReplayGuard(shared.ReplayGuardOptions{Window: time.Minute, CacheSize: 10, AllowUnstamped: true})
+
+		err := shared.VerifyStampedSignature(ctx, s, required, body, s.Sign(body), "", "")
+		assert.ErrorIs(t, err, shared.ErrMissingStamp)
+
+		infoCtx, info := shared.WithRequestInfo(ctx)
+
+		err = shared.VerifyStampedSignature(infoCtx, s, optional, body, s.Sign(body), "", "")
+		assert.NoError(t, err)
+		assert.True(t, info.Unstamped)
+	})
+}
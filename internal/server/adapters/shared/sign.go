@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// VerifySignature checks signature of request body received by any transport.
+// Id of the key which verified signature is reported in request info of ctx
+func VerifySignature(ctx context.Context, s signer.Signer, body []byte, signature string) error {
+	keyVerifier, ok := s.(signer.KeyVerifier)
+
+	if !ok {
+		if !s.Verify(body, signature) {
+			return ErrInvalidSignature
+		}
+
+		return nil
+	}
+
+	keyID, ok := keyVerifier.VerifyKey(body, signature)
+
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	if info := requestInfo(ctx); info != nil {
+		info.KeyID = keyID
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package shared_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"test": true}`)
+
+	keyring, err := signer.NewKeyring(signer.Key{ID: "k2", Secret: "new"}, signer.Key{ID: "k1", Secret: "old"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		signer        signer.Signer
+		signature     string
+		expectedErr   error
+		expectedKeyID string
+	}{
+		{
+			name:          "should report key which verified signature",
+			signer:        keyring,
+			signature:     "k1:" + signer.NewSHA256Signer("old").Sign(body),
+			expectedKeyID: "k1",
+		},
+		{
+			name:        "should return error if keyring rejected signature",
+			signer:      keyring,
+			signature:   "k1:" + signer.NewSHA256Signer("new").Sign(body),
+			expectedErr: shared.ErrInvalidSignature,
+		},
+		{
+			name:      "should not report key of single key signer",
+			signer:    signer.NewSHA256Signer("old"),
+			signature: signer.NewSHA256Signer("old").Sign(body),
+		},
+		{
+			name:        "should return error if single key signer rejected signature",
+			signer:      signer.NewSHA256Signer("old"),
+			signature:   signer.NewSHA256Signer("new").Sign(body),
+			expectedErr: shared.ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, info := shared.WithRequestInfo(context.Background())
+
+			err := shared.VerifySignature(ctx, tt.signer, body, tt.signature)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedKeyID, info.KeyID)
+		})
+	}
+
+	t.Run("should verify signature without request info", func(t *testing.T) {
+		err := shared.VerifySignature(context.Background(), keyring, body, keyring.Sign(body))
+
+		assert.NoError(t, err)
+	})
+}
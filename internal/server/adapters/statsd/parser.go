@@ -0,0 +1,107 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	typeCounter = "c"
+	typeGauge   = "g"
+	typeTimer   = "ms"
+
+	// minSampleRate bounds weight of sampled line, every line is counted as 1/rate values
+	minSampleRate = 1e-6
+)
+
+// line is a single parsed statsd metric: <name>:<value>|<type>[|@<sample rate>]
+type line struct {
+	name  string
+	mType string
+	value float64
+	rate  float64
+	// relative is set for gauges sent with explicit sign, e.g. "+3" or "-3", which change current value
+	relative bool
+}
+
+func parseLine(raw string) (line, error) {
+	name, rest, ok := strings.Cut(raw, ":")
+
+	if !ok || name == "" {
+		return line{}, fmt.Errorf("invalid statsd line %q: expected <name>:<value>|<type>", raw)
+	}
+
+	parts := strings.Split(rest, "|")
+
+	if len(parts) < 2 {
+		return line{}, fmt.Errorf("invalid statsd line %q: metric type not provided", raw)
+	}
+
+	result := line{name: name, mType: parts[1], rate: 1}
+
+	value, err := strconv.ParseFloat(parts[0], 64)
+
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return line{}, fmt.Errorf("invalid statsd line %q: value must be a number", raw)
+	}
+
+	result.value = value
+
+	switch result.mType {
+	case typeCounter, typeTimer:
+	case typeGauge:
+		result.relative = strings.HasPrefix(parts[0], "+") || strings.HasPrefix(parts[0], "-")
+	default:
+		return line{}, fmt.Errorf("invalid statsd line %q: unsupported metric type %q", raw, result.mType)
+	}
+
+	for _, part := range parts[2:] {
+		if !strings.HasPrefix(part, "@") {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(part[1:], 64)
+
+		if err != nil || rate < minSampleRate || rate > 1 {
+			return line{}, fmt.Errorf("invalid statsd line %q: sample rate must be in [%g, 1]", raw, minSampleRate)
+		}
+
+		result.rate = rate
+	}
+
+	// float64(math.MaxInt64) is 2^63, so scaled counter must be strictly less than it
+	if delta := math.Round(result.value / result.rate); result.mType == typeCounter && (delta < math.MinInt64 || delta >= math.MaxInt64) {
+		return line{}, fmt.Errorf("invalid statsd line %q: counter value is out of range", raw)
+	}
+
+	return result, nil
+}
+
+// parsePacket splits packet into newline separated lines and parses every non-empty line.
+// Invalid lines are reported in error, valid ones are still returned
+func parsePacket(packet []byte) ([]line, error) {
+	var lines []line
+	var errs []error
+
+	for _, raw := range strings.Split(string(packet), "\n") {
+		raw = strings.TrimSpace(raw)
+
+		if raw == "" {
+			continue
+		}
+
+		l, err := parseLine(raw)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		lines = append(lines, l)
+	}
+
+	return lines, errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+)
+
+const maxPacketSize = 65535
+
+type Adapter struct {
+	metricService metricprocessor.MetricService
+	logger        logger.ILogger
+}
+
+// ListenAndServe listens udp address and serves statsd packets until ctx is done
+func (a *Adapter) ListenAndServe(ctx context.Context, address string) error {
+	conn, err := net.ListenPacket("udp", address)
+
+	if err != nil {
+		return err
+	}
+
+	return a.Serve(ctx, conn)
+}
+
+// Serve reads statsd packets from conn and saves parsed metrics. Conn is closed when ctx is done
+func (a *Adapter) Serve(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	a.logger.Infow("start statsd listener", "address", conn.LocalAddr().String())
+
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+
+		if ctx.Err() != nil {
+			a.logger.Infow("statsd: stop listener", "reason", ctx.Err())
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		lines, err := parsePacket(buf[:n])
+
+		if err != nil {
+			a.logger.Warnw("statsd: skip invalid lines", "error", err)
+		}
+
+		for _, l := range lines {
+			if err := a.save(ctx, l); err != nil {
+				a.logger.Errorw("statsd: error while saving metric", "metricName", l.name, "error", err)
+			}
+		}
+	}
+}
+
+func (a *Adapter) save(ctx context.Context, l line) error {
+	switch l.mType {
+	case typeCounter:
+		delta := int64(math.Round(l.value / l.rate))
+
+		_, err := a.metricService.SaveMetric(ctx, constants.MetricTypeCounter, l.name, metricprocessor.MetricValue{Counter: delta})
+
+		return err
+	case typeGauge:
+		if l.relative {
+			_, err := a.metricService.AddGauge(ctx, l.name, l.value)
+
+			return err
+		}
+
+		_, err := a.metricService.SaveMetric(ctx, constants.MetricTypeGauge, l.name, metricprocessor.MetricValue{Gauge: l.value})
+
+		return err
+	case typeTimer:
+		// timers are stored as histograms in seconds; sampled timer counts as 1/rate observations
+		h := entities.NewHistogram(entities.DefaultHistogramBounds)
+		h.ObserveN(l.value/1000, int64(math.Round(1/l.rate)))
+
+		_, err := a.metricService.SaveMetric(ctx, constants.MetricTypeHistogram, l.name, metricprocessor.MetricValue{Histogram: h})
+
+		return err
+	}
+
+	return errors.New("unsupported statsd metric type: " + l.mType)
+}
+
+func New(metricService metricprocessor.MetricService, logger logger.ILogger) *Adapter {
+	return &Adapter{
+		metricService,
+		logger,
+	}
+}
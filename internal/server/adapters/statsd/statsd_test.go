@@ -0,0 +1,149 @@
+package statsd_test
+
+import (
+	"context"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/statsd"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type saveFunc = func(ctx context.Context, metricType string, metricName string, metricValue metricprocessor.MetricValue)
+
+func TestStatsdAdapter_Serve(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		packet    string
+		setupMock func(done saveFunc)
+	}{
+		{
+			name:   "counter",
+			packet: "requests:3|c",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeCounter, "requests", metricprocessor.MetricValue{Counter: 3}).Times(1).Do(done)
+			},
+		},
+		{
+			name:   "sampled counter",
+			packet: "requests:1|c|@0.1",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeCounter, "requests", metricprocessor.MetricValue{Counter: 10}).Times(1).Do(done)
+			},
+		},
+		{
+			name:   "gauge",
+			packet: "temperature:21.5|g",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeGauge, "temperature", metricprocessor.MetricValue{Gauge: 21.5}).Times(1).Do(done)
+			},
+		},
+		{
+			name:   "gauge delta",
+			packet: "connections:-2|g",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().AddGauge(gomock.Any(), "connections", -2.0).Times(1).Do(func(ctx context.Context, name string, delta float64) {
+					done(ctx, constants.MetricTypeGauge, name, metricprocessor.MetricValue{Gauge: delta})
+				})
+			},
+		},
+		{
+			name:   "gauge increment",
+			packet: "connections:+2|g",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().AddGauge(gomock.Any(), "connections", 2.0).Times(1).Do(func(ctx context.Context, name string, delta float64) {
+					done(ctx, constants.MetricTypeGauge, name, metricprocessor.MetricValue{Gauge: delta})
+				})
+			},
+		},
+		{
+			name:   "timer",
+			packet: "latency:320|ms",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeHistogram, "latency", gomock.Any()).Times(1).Do(func(_ context.Context, _ string, _ string, val metricprocessor.MetricValue) {
+					assert.Equal(t, int64(1), val.Histogram.Count)
+					assert.Equal(t, 0.32, val.Histogram.Sum)
+					done(context.Background(), constants.MetricTypeHistogram, "latency", val)
+				})
+			},
+		},
+		{
+			name:   "sampled timer",
+			packet: "latency:320|ms|@0.001",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeHistogram, "latency", gomock.Any()).Times(1).Do(func(_ context.Context, _ string, _ string, val metricprocessor.MetricValue) {
+					assert.Equal(t, int64(1000), val.Histogram.Count)
+					assert.InDelta(t, 320, val.Histogram.Sum, 1e-9)
+					done(context.Background(), constants.MetricTypeHistogram, "latency", val)
+				})
+			},
+		},
+		{
+			name:   "skip lines with too small sample rate or counter out of range",
+			packet: "latency:320|ms|@0.0000001\nrequests:9223372036854775807|c|@0.5\nrequests:-9223372036854775808|c|@0.5\nrequests:1|c",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeCounter, "requests", metricprocessor.MetricValue{Counter: 1}).Times(1).Do(done)
+			},
+		},
+		{
+			name:   "skip invalid lines in packet",
+			packet: "broken\nunknown:1|x\nrequests:1|c",
+			setupMock: func(done saveFunc) {
+				metricServiceMock.EXPECT().SaveMetric(gomock.Any(), constants.MetricTypeCounter, "requests", metricprocessor.MetricValue{Counter: 1}).Times(1).Do(done)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			saved := make(chan struct{})
+			tc.setupMock(func(context.Context, string, string, metricprocessor.MetricValue) { close(saved) })
+
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			a := statsd.New(metricServiceMock, logger)
+
+			served := make(chan error)
+			go func() {
+				served <- a.Serve(ctx, conn)
+			}()
+
+			client, err := net.Dial("udp", conn.LocalAddr().String())
+			require.NoError(t, err)
+			defer client.Close()
+
+			_, err = client.Write([]byte(tc.packet))
+			require.NoError(t, err)
+
+			select {
+			case <-saved:
+			case <-time.After(time.Second):
+				t.Fatal("metric was not saved")
+			}
+
+			cancel()
+			require.NoError(t, <-served)
+		})
+	}
+}
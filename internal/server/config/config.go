@@ -16,7 +16,26 @@ type Config struct {
 	FileStoragePath string `env:"FILE_STORAGE_PATH"`
 	Restore         bool   `env:"RESTORE"`
 	DatabaseDSN     string `env:"DATABASE_DSN"`
+	SQLitePath      string `env:"SQLITE_PATH"`
 	SecretKey       string `env:"KEY"`
+	KeyringFile     string `env:"KEYRING_FILE"`
+	ReplayWindow    int    `env:"REPLAY_WINDOW"`
+	ReplayCacheSize int    `env:"REPLAY_CACHE_SIZE"`
+	AllowUnstamped  bool   `env:"ALLOW_UNSTAMPED"`
+	CryptoKey       string `env:"CRYPTO_KEY"`
+	TLSCert         string `env:"TLS_CERT"`
+	TLSKey          string `env:"TLS_KEY"`
+	TLSClientCA     string `env:"TLS_CLIENT_CA"`
+	StatsdAddress   string `env:"STATSD_ADDRESS"`
+	GRPCAddress     string `env:"GRPC_ADDRESS"`
+	ShutdownTimeout int    `env:"SHUTDOWN_TIMEOUT"`
+	AlertRulesPath  string `env:"ALERT_RULES_FILE"`
+	AlertInterval   int    `env:"ALERT_INTERVAL"`
+	AlertWebhooks   string `env:"ALERT_WEBHOOKS"`
+	AlertRepeat     int    `env:"ALERT_REPEAT_INTERVAL"`
+	GaugeTTL        int    `env:"GAUGE_TTL"`
+	Retention       string `env:"RETENTION"`
+	CompactInterval int    `env:"COMPACT_INTERVAL"`
 }
 
 func ParseConfig() *Config {
@@ -27,7 +46,26 @@ func ParseConfig() *Config {
 	flag.StringVar(&config.FileStoragePath, "f", "/tmp/metrics-db.json", "file path for store metrics: provide empty if want disable file storage")
 	flag.BoolVar(&config.Restore, "r", true, "load saved metrics on bootstrap server")
 	flag.StringVar(&config.DatabaseDSN, "d", "", "database connection string")
+	flag.StringVar(&config.SQLitePath, "sq", "", "sqlite database file path: used instead of file storage if provided")
 	flag.StringVar(&config.SecretKey, "k", "", "secret key for data encryption")
+	flag.StringVar(&config.KeyringFile, "keyring", "", "file with signing keys in format id=secret per line, reloaded on change: key from -k verifies signatures without key id")
+	flag.IntVar(&config.ReplayWindow, "rw", 300, "allowed clock skew in seconds for timestamp of signed requests, nonces are remembered for it: provide 0 if want disable replay protection")
+	flag.IntVar(&config.ReplayCacheSize, "rc", 100000, "max number of remembered nonces, requests older than evicted nonce are rejected")
+	flag.BoolVar(&config.AllowUnstamped, "ru", false, "accept signed requests without timestamp and nonce sent by agents of previous versions, such requests can be replayed")
+	flag.StringVar(&config.CryptoKey, "crypto-key", "", "path to private key pem for decrypting agent payloads: provide empty if want disable decryption")
+	flag.StringVar(&config.TLSCert, "tls-cert", "", "path to server certificate pem, reloaded on change: provide empty if want serve plain http")
+	flag.StringVar(&config.TLSKey, "tls-key", "", "path to server certificate key pem")
+	flag.StringVar(&config.TLSClientCA, "tls-client-ca", "", "path to ca bundle for verifying client certificates: provide empty if want disable mutual tls")
+	flag.StringVar(&config.StatsdAddress, "s", "", "udp address for statsd listener: provide empty if want disable statsd")
+	flag.StringVar(&config.GRPCAddress, "g", "", "address and port for grpc server: provide empty if want disable grpc")
+	flag.IntVar(&config.ShutdownTimeout, "st", 10, "timeout in seconds for draining active requests on shutdown")
+	flag.StringVar(&config.AlertRulesPath, "ar", "", "file with alerting rules, one per line: provide empty if want disable alerting")
+	flag.IntVar(&config.AlertInterval, "ai", 15, "alerting rules evaluation interval in seconds")
+	flag.StringVar(&config.AlertWebhooks, "aw", "", "comma separated webhook urls notified on alert state changes")
+	flag.IntVar(&config.AlertRepeat, "arp", 3600, "interval in seconds for repeating notification about still firing alert: provide 0 if want disable repeat")
+	flag.IntVar(&config.GaugeTTL, "gt", 0, "time in seconds after which not updated gauge series is removed: provide 0 if want disable expiry")
+	flag.StringVar(&config.Retention, "rt", "", "history retention tiers, e.g. raw:24h,1m:720h,1h:8760h: provide empty if want keep raw history forever")
+	flag.IntVar(&config.CompactInterval, "ci", 60, "interval in seconds for rolling up and removing expired history")
 	flag.Parse()
 
 	if err := env.Parse(&config); err != nil {
@@ -2,18 +2,42 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-resty/resty/v2"
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
+	grpcmetric "github.com/sodiqit/metricpulse.git/internal/server/adapters/grpc/metric"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/alert"
 	"github.com/sodiqit/metricpulse.git/internal/server/adapters/http/metric"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/shared"
+	"github.com/sodiqit/metricpulse.git/internal/server/adapters/statsd"
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/alerting"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/compactor"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/expiry"
 	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
 	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 	"github.com/sodiqit/metricpulse.git/pkg/signer"
+	"github.com/sodiqit/metricpulse.git/pkg/tlsconfig"
+	"golang.org/x/sync/errgroup"
 )
 
+// webhookTimeout bounds single webhook request, so hanging receiver doesn't keep its delivery forever
+const webhookTimeout = 10 * time.Second
+
 func RunServer(config *config.Config) error {
 	logger, err := logger.Initialize(config.LogLevel)
 
@@ -23,10 +47,16 @@ func RunServer(config *config.Config) error {
 
 	defer logger.Sync()
 
-	ctx := context.Background()
+	tiers, err := entities.ParseRetentionTiers(config.Retention)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	storage := setupStorage(config, logger)
-	defer storage.Close(ctx)
 
 	err = storage.Init(ctx, retry.NewBaseBackoff())
 
@@ -34,17 +64,142 @@ func RunServer(config *config.Config) error {
 		return err
 	}
 
+	// final flush runs on a fresh context: ctx is already cancelled on shutdown
+	defer func() {
+		if err := storage.Close(context.Background()); err != nil {
+			logger.Errorw("error while closing storage", "error", err)
+		}
+	}()
+
 	metricService := metricprocessor.New(storage, config)
 
-	signer := setupSinger(config)
+	signer, err := setupSinger(config)
+
+	if err != nil {
+		return err
+	}
+
+	decryptor, err := setupDecryptor(config)
+
+	if err != nil {
+		return err
+	}
+
+	replayGuard := setupReplayGuard(config)
+
+	metricAdapter := metric.New(metricService, storage, logger, signer, replayGuard, decryptor)
+
+	tlsConfig, err := setupTLS(config)
+
+	if err != nil {
+		return err
+	}
+
+	alertEngine, err := setupAlerting(config, metricService, signer, logger)
 
-	metricAdapter := metric.New(metricService, storage, logger, signer)
+	if err != nil {
+		return err
+	}
+
+	gaugeExpirer := expiry.New(expiry.ExpirerOptions{
+		Storage:    storage,
+		MetricType: constants.MetricTypeGauge,
+		TTL:        time.Duration(config.GaugeTTL) * time.Second,
+		Logger:     logger,
+	})
+
+	historyCompactor := compactor.New(compactor.CompactorOptions{
+		Storage:  storage,
+		Tiers:    tiers,
+		Interval: time.Duration(config.CompactInterval) * time.Second,
+		Logger:   logger,
+	})
+
+	alertAdapter := alert.New(alertEngine, logger)
 
 	r := chi.NewRouter()
+	r.Mount("/alerts", alertAdapter.Route())
 	r.Mount("/", metricAdapter.Route())
 
-	logger.Infow("start server", "address", config.Address, "config", config)
-	return http.ListenAndServe(config.Address, r)
+	server := &http.Server{Addr: config.Address, Handler: r, TLSConfig: tlsConfig}
+
+	// every component is stopped and awaited before storage is closed
+	g, gCtx := errgroup.WithContext(ctx)
+
+	if config.StatsdAddress != "" {
+		statsdAdapter := statsd.New(metricService, logger)
+
+		g.Go(func() error {
+			if err := statsdAdapter.ListenAndServe(gCtx, config.StatsdAddress); err != nil {
+				logger.Errorw("statsd listener failed", "address", config.StatsdAddress, "error", err)
+			}
+
+			return nil
+		})
+	}
+
+	if config.GRPCAddress != "" {
+		grpcAdapter := grpcmetric.New(metricService, storage, logger, signer, replayGuard, tlsConfig)
+
+		// grpc failure stops the whole server, agents configured for grpc can't report otherwise
+		g.Go(func() error {
+			if err := grpcAdapter.ListenAndServe(gCtx, config.GRPCAddress); err != nil {
+				return fmt.Errorf("grpc server on %s: %w", config.GRPCAddress, err)
+			}
+
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		return alertEngine.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		return gaugeExpirer.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		return historyCompactor.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		logger.Infow("start server", "address", config.Address, "tls", tlsConfig != nil, "config", config)
+
+		var err error
+
+		if tlsConfig != nil {
+			// certificate is provided by tls config
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+
+		return err
+	})
+
+	g.Go(func() error {
+		<-gCtx.Done()
+		logger.Infow("shutdown server", "reason", gCtx.Err())
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout)*time.Second)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Warnw("shutdown timeout exceeded, close active connections", "timeout", config.ShutdownTimeout)
+			return server.Close()
+		}
+
+		return err
+	})
+
+	return g.Wait()
 }
 
 func setupStorage(cfg *config.Config, logger logger.ILogger) storage.Storage {
@@ -54,6 +209,10 @@ func setupStorage(cfg *config.Config, logger logger.ILogger) storage.Storage {
 		return storage.NewPostgresStorage(cfg, logger)
 	}
 
+	if cfg.SQLitePath != "" {
+		return storage.NewSQLiteStorage(cfg, logger)
+	}
+
 	if cfg.FileStoragePath != "" {
 		return storage.NewFileStorage(cfg, memoryStorage, logger)
 	}
@@ -61,12 +220,87 @@ func setupStorage(cfg *config.Config, logger logger.ILogger) storage.Storage {
 	return memoryStorage
 }
 
-func setupSinger(cfg *config.Config) signer.Signer {
+func setupAlerting(cfg *config.Config, metricService metricprocessor.MetricService, signer signer.Signer, logger logger.ILogger) (*alerting.Engine, error) {
+	var rules []alerting.Rule
+
+	if cfg.AlertRulesPath != "" {
+		var err error
+
+		rules, err = alerting.LoadRules(cfg.AlertRulesPath)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var notifier alerting.Notifier
+
+	if cfg.AlertWebhooks != "" {
+		notifier = alerting.NewWebhookNotifier(alerting.WebhookNotifierOptions{
+			URLs:           strings.Split(cfg.AlertWebhooks, ","),
+			Client:         resty.New().SetTimeout(webhookTimeout),
+			Signer:         signer,
+			RepeatInterval: time.Duration(cfg.AlertRepeat) * time.Second,
+			Logger:         logger,
+		})
+	}
+
+	return alerting.New(rules, metricService, notifier, logger, time.Duration(cfg.AlertInterval)*time.Second), nil
+}
+
+func setupSinger(cfg *config.Config) (signer.Signer, error) {
+	if cfg.KeyringFile != "" {
+		keyring, err := signer.LoadKeyring(cfg.KeyringFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.SecretKey != "" {
+			keyring.WithLegacyKey(cfg.SecretKey)
+		}
+
+		return keyring, nil
+	}
+
 	var sha256Signer signer.Signer
 
 	if cfg.SecretKey != "" {
 		sha256Signer = signer.NewSHA256Signer(cfg.SecretKey)
 	}
 
-	return sha256Signer
+	return sha256Signer, nil
+}
+
+// setupReplayGuard returns guard shared by http and grpc, cache size bounds nonces of both
+func setupReplayGuard(cfg *config.Config) *shared.ReplayGuard {
+	if cfg.ReplayWindow <= 0 {
+		return nil
+	}
+
+	return shared.NewReplayGuard(shared.ReplayGuardOptions{
+		Window:         time.Duration(cfg.ReplayWindow) * time.Second,
+		CacheSize:      cfg.ReplayCacheSize,
+		AllowUnstamped: cfg.AllowUnstamped,
+	})
+}
+
+func setupDecryptor(cfg *config.Config) (encryption.Decryptor, error) {
+	if cfg.CryptoKey == "" {
+		return nil, nil
+	}
+
+	return encryption.LoadDecryptor(cfg.CryptoKey)
+}
+
+func setupTLS(cfg *config.Config) (*tls.Config, error) {
+	if cfg.TLSCert == "" {
+		return nil, nil
+	}
+
+	return tlsconfig.NewServerConfig(tlsconfig.ServerOptions{
+		CertFile:     cfg.TLSCert,
+		KeyFile:      cfg.TLSKey,
+		ClientCAFile: cfg.TLSClientCA,
+	})
 }
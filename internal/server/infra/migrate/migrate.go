@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage/migrations"
+)
+
+const usage = "usage: server migrate [flags] up | down [steps] | version"
+
+// Run executes migrate subcommand on database from cfg. args are positional arguments after flags
+func Run(cfg *config.Config, args []string) error {
+	if cfg.DatabaseDSN == "" {
+		return errors.New("database connection string not provided")
+	}
+
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	logger, err := logger.Initialize(cfg.LogLevel)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, cfg.DatabaseDSN)
+
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	migrator, err := migrations.New(pool, logger)
+
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps: %s; %s", args[1], usage)
+			}
+		}
+
+		err = migrator.Down(ctx, steps)
+	case "version":
+	default:
+		return errors.New(usage)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("schema version", "version", version)
+
+	return nil
+}
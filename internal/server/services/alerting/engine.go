@@ -0,0 +1,222 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+)
+
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+type Alert struct {
+	Name       string     `json:"name"`
+	Rule       string     `json:"rule"`
+	State      State      `json:"state"`
+	Value      float64    `json:"value"`
+	ActiveAt   time.Time  `json:"activeAt"`
+	FiredAt    *time.Time `json:"firedAt,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type AlertService interface {
+	Alerts() []Alert
+}
+
+type ruleState struct {
+	alert *Alert
+
+	// previous counter value for rate rules
+	prevValue float64
+	prevAt    time.Time
+}
+
+// Engine evaluates rules against current metric values on a schedule.
+// Alert becomes pending when rule condition is met, firing when condition holds for rule.For
+// and resolved when condition of firing alert is no longer met. Pending alert is dropped when condition is not met
+type Engine struct {
+	m sync.Mutex
+
+	rules         []Rule
+	states        []ruleState
+	metricService metricprocessor.MetricService
+	notifier      Notifier
+	logger        logger.ILogger
+	interval      time.Duration
+}
+
+// Run evaluates rules every interval until ctx is done
+func (e *Engine) Run(ctx context.Context) error {
+	if len(e.rules) == 0 {
+		return nil
+	}
+
+	if e.interval <= 0 {
+		return fmt.Errorf("alerting: evaluation interval must be positive, got %s", e.interval)
+	}
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			e.logger.Infow("alerting: stop evaluation", "reason", ctx.Err())
+
+			if e.notifier != nil {
+				e.notifier.Wait()
+			}
+
+			return nil
+		}
+
+		e.Evaluate(ctx, time.Now())
+	}
+}
+
+// Evaluate updates alerts states and passes resulting alerts to notifier, if it is set
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) {
+	e.evaluate(ctx, now)
+
+	if e.notifier == nil {
+		return
+	}
+
+	if err := e.notifier.Notify(ctx, e.Alerts(), now); err != nil {
+		e.logger.Errorw("alerting: error while notifying", "error", err)
+	}
+}
+
+func (e *Engine) evaluate(ctx context.Context, now time.Time) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	for i, rule := range e.rules {
+		state := &e.states[i]
+
+		value, ok, err := e.value(ctx, rule, state, now)
+
+		if err != nil {
+			e.logger.Errorw("alerting: error while evaluating rule", "rule", rule.Name, "error", err)
+			continue
+		}
+
+		if ok && rule.matches(value) {
+			activate(state, rule, value, now)
+		} else {
+			deactivate(state, now)
+		}
+	}
+}
+
+// Alerts returns active and resolved alerts sorted by name
+func (e *Engine) Alerts() []Alert {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	alerts := make([]Alert, 0, len(e.states))
+
+	for _, state := range e.states {
+		if state.alert != nil {
+			alerts = append(alerts, *state.alert)
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Name < alerts[j].Name
+	})
+
+	return alerts
+}
+
+// value returns current value of rule expression. ok is false when metric has no data yet
+func (e *Engine) value(ctx context.Context, rule Rule, state *ruleState, now time.Time) (float64, bool, error) {
+	val, err := e.metricService.GetMetric(ctx, rule.MetricType, rule.MetricName)
+
+	if storage.IsErrNotFound(err) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	value := val.Gauge
+
+	if rule.MetricType == constants.MetricTypeCounter {
+		value = float64(val.Counter)
+	}
+
+	if !rule.Rate {
+		return value, true, nil
+	}
+
+	prevValue, prevAt := state.prevValue, state.prevAt
+	state.prevValue, state.prevAt = value, now
+
+	if prevAt.IsZero() || !now.After(prevAt) {
+		return 0, false, nil
+	}
+
+	increase := value - prevValue
+
+	// counter was reset, e.g. server restarted without restore
+	if increase < 0 {
+		increase = value
+	}
+
+	return increase / now.Sub(prevAt).Seconds(), true, nil
+}
+
+func activate(state *ruleState, rule Rule, value float64, now time.Time) {
+	if state.alert == nil || state.alert.State == StateResolved {
+		state.alert = &Alert{Name: rule.Name, Rule: rule.String(), State: StatePending, ActiveAt: now}
+	}
+
+	state.alert.Value = value
+
+	if state.alert.State == StatePending && now.Sub(state.alert.ActiveAt) >= rule.For {
+		firedAt := now
+		state.alert.State = StateFiring
+		state.alert.FiredAt = &firedAt
+	}
+}
+
+func deactivate(state *ruleState, now time.Time) {
+	if state.alert == nil {
+		return
+	}
+
+	switch state.alert.State {
+	case StatePending:
+		state.alert = nil
+	case StateFiring:
+		resolvedAt := now
+		state.alert.State = StateResolved
+		state.alert.ResolvedAt = &resolvedAt
+	}
+}
+
+func New(rules []Rule, metricService metricprocessor.MetricService, notifier Notifier, logger logger.ILogger, interval time.Duration) *Engine {
+	return &Engine{
+		rules:         rules,
+		states:        make([]ruleState, len(rules)),
+		metricService: metricService,
+		notifier:      notifier,
+		logger:        logger,
+		interval:      interval,
+	}
+}
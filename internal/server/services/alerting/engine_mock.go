@@ -0,0 +1,53 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/server/services/alerting/engine.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/server/services/alerting/engine.go -destination=./internal/server/services/alerting/engine_mock.go -package=alerting
+//
+
+// Package alerting is a generated GoMock package.
+package alerting
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAlertService is a mock of AlertService interface.
+type MockAlertService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAlertServiceMockRecorder
+}
+
+// MockAlertServiceMockRecorder is the mock recorder for MockAlertService.
+type MockAlertServiceMockRecorder struct {
+	mock *MockAlertService
+}
+
+// NewMockAlertService creates a new mock instance.
+func NewMockAlertService(ctrl *gomock.Controller) *MockAlertService {
+	mock := &MockAlertService{ctrl: ctrl}
+	mock.recorder = &MockAlertServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAlertService) EXPECT() *MockAlertServiceMockRecorder {
+	return m.recorder
+}
+
+// Alerts mocks base method.
+func (m *MockAlertService) Alerts() []Alert {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Alerts")
+	ret0, _ := ret[0].([]Alert)
+	return ret0
+}
+
+// Alerts indicates an expected call of Alerts.
+func (mr *MockAlertServiceMockRecorder) Alerts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Alerts", reflect.TypeOf((*MockAlertService)(nil).Alerts))
+}
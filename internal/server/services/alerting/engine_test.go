@@ -0,0 +1,175 @@
+package alerting_test
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/alerting"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger, err := logger.Initialize("info")
+
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type step struct {
+		after         time.Duration
+		value         metricprocessor.MetricValue
+		notFound      bool
+		expectedState alerting.State
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		steps []step
+	}{
+		{
+			name: "gauge alert goes pending, firing and resolved",
+			rule: "gauge HeapAlloc > 100 for 2m",
+			steps: []step{
+				{after: 0, value: metricprocessor.MetricValue{Gauge: 50}},
+				{after: time.Minute, value: metricprocessor.MetricValue{Gauge: 150}, expectedState: alerting.StatePending},
+				{after: 2 * time.Minute, value: metricprocessor.MetricValue{Gauge: 160}, expectedState: alerting.StatePending},
+				{after: 3 * time.Minute, value: metricprocessor.MetricValue{Gauge: 170}, expectedState: alerting.StateFiring},
+				{after: 4 * time.Minute, value: metricprocessor.MetricValue{Gauge: 90}, expectedState: alerting.StateResolved},
+				{after: 5 * time.Minute, value: metricprocessor.MetricValue{Gauge: 90}, expectedState: alerting.StateResolved},
+				{after: 6 * time.Minute, value: metricprocessor.MetricValue{Gauge: 200}, expectedState: alerting.StatePending},
+			},
+		},
+		{
+			name: "pending alert is dropped when condition is not met",
+			rule: "gauge HeapAlloc > 100 for 2m",
+			steps: []step{
+				{after: 0, value: metricprocessor.MetricValue{Gauge: 150}, expectedState: alerting.StatePending},
+				{after: time.Minute, value: metricprocessor.MetricValue{Gauge: 50}},
+			},
+		},
+		{
+			name: "alert without duration fires immediately",
+			rule: "gauge HeapAlloc > 100",
+			steps: []step{
+				{after: 0, value: metricprocessor.MetricValue{Gauge: 150}, expectedState: alerting.StateFiring},
+			},
+		},
+		{
+			name: "missing metric does not match",
+			rule: "gauge HeapAlloc < 100",
+			steps: []step{
+				{after: 0, notFound: true},
+			},
+		},
+		{
+			name: "counter rate handles resets",
+			rule: "counter rate(PollCount) == 0 for 1m",
+			steps: []step{
+				{after: 0, value: metricprocessor.MetricValue{Counter: 10}},
+				{after: time.Minute, value: metricprocessor.MetricValue{Counter: 10}, expectedState: alerting.StatePending},
+				{after: 2 * time.Minute, value: metricprocessor.MetricValue{Counter: 10}, expectedState: alerting.StateFiring},
+				{after: 3 * time.Minute, value: metricprocessor.MetricValue{Counter: 3}, expectedState: alerting.StateResolved},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+
+			rule, err := alerting.ParseRule(tc.rule)
+			require.NoError(t, err)
+
+			engine := alerting.New([]alerting.Rule{rule}, metricServiceMock, nil, logger, time.Second)
+
+			for _, s := range tc.steps {
+				var getErr error
+
+				if s.notFound {
+					getErr = storage.NewErrNotFound(nil, nil)
+				}
+
+				metricServiceMock.EXPECT().GetMetric(gomock.Any(), rule.MetricType, rule.MetricName).Times(1).Return(s.value, getErr)
+
+				engine.Evaluate(ctx, start.Add(s.after))
+
+				alerts := engine.Alerts()
+
+				if s.expectedState == "" {
+					assert.Empty(t, alerts, "after %s", s.after)
+					continue
+				}
+
+				require.Len(t, alerts, 1, "after %s", s.after)
+				assert.Equal(t, s.expectedState, alerts[0].State, "after %s", s.after)
+			}
+		})
+	}
+}
+
+func TestEngine_AlertFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	metricServiceMock := metricprocessor.NewMockMetricService(ctrl)
+
+	rule, err := alerting.ParseRule("HighHeap: gauge HeapAlloc > 100")
+	require.NoError(t, err)
+
+	engine := alerting.New([]alerting.Rule{rule}, metricServiceMock, nil, logger, time.Second)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	metricServiceMock.EXPECT().GetMetric(gomock.Any(), constants.MetricTypeGauge, "HeapAlloc").Times(1).Return(metricprocessor.MetricValue{Gauge: 150}, nil)
+
+	engine.Evaluate(context.Background(), now)
+
+	assert.Equal(t, []alerting.Alert{{
+		Name:     "HighHeap",
+		Rule:     "gauge HeapAlloc > 100",
+		State:    alerting.StateFiring,
+		Value:    150,
+		ActiveAt: now,
+		FiredAt:  &now,
+	}}, engine.Alerts())
+}
+
+func TestEngine_Run(t *testing.T) {
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	rule, err := alerting.ParseRule("HighHeap: gauge HeapAlloc > 100")
+	require.NoError(t, err)
+
+	t.Run("should return error if interval is not positive", func(t *testing.T) {
+		for _, interval := range []time.Duration{0, -time.Second} {
+			engine := alerting.New([]alerting.Rule{rule}, nil, nil, logger, interval)
+
+			assert.Error(t, engine.Run(context.Background()))
+		}
+	})
+
+	t.Run("should not run without rules", func(t *testing.T) {
+		engine := alerting.New(nil, nil, nil, logger, 0)
+
+		assert.NoError(t, engine.Run(context.Background()))
+	})
+}
@@ -0,0 +1,163 @@
+package alerting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+)
+
+var supportedOperators = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+	"==": func(value, threshold float64) bool { return value == threshold },
+	"!=": func(value, threshold float64) bool { return value != threshold },
+}
+
+// Rule condition on a single metric, e.g. "gauge HeapAlloc > 5e8 for 2m"
+// or "counter rate(PollCount) == 0 for 5m". Rate is per second increase of counter between evaluations
+type Rule struct {
+	Name       string
+	MetricType string
+	MetricName string
+	Rate       bool
+	Operator   string
+	Threshold  float64
+	For        time.Duration
+}
+
+func (r Rule) String() string {
+	expr := r.MetricName
+
+	if r.Rate {
+		expr = "rate(" + expr + ")"
+	}
+
+	result := fmt.Sprintf("%s %s %s %s", r.MetricType, expr, r.Operator, strconv.FormatFloat(r.Threshold, 'g', -1, 64))
+
+	if r.For > 0 {
+		result += " for " + r.For.String()
+	}
+
+	return result
+}
+
+func (r Rule) matches(value float64) bool {
+	return supportedOperators[r.Operator](value, r.Threshold)
+}
+
+// ParseRule parses rule in format "[<alert name>:] <gauge|counter> <metric|rate(metric)> <operator> <threshold> [for <duration>]".
+// Without explicit alert name the rule text is used as name
+func ParseRule(text string) (Rule, error) {
+	var rule Rule
+
+	fields := strings.Fields(text)
+
+	if len(fields) > 0 && strings.HasSuffix(fields[0], ":") {
+		rule.Name = strings.TrimSuffix(fields[0], ":")
+		fields = fields[1:]
+	}
+
+	if len(fields) != 4 && len(fields) != 6 {
+		return Rule{}, fmt.Errorf("invalid rule %q: expected <type> <metric> <operator> <threshold> [for <duration>]", text)
+	}
+
+	rule.MetricType = fields[0]
+
+	if rule.MetricType != constants.MetricTypeGauge && rule.MetricType != constants.MetricTypeCounter {
+		return Rule{}, fmt.Errorf("invalid rule %q: supported metric types: gauge | counter", text)
+	}
+
+	rule.MetricName = fields[1]
+
+	if strings.HasPrefix(rule.MetricName, "rate(") && strings.HasSuffix(rule.MetricName, ")") {
+		rule.Rate = true
+		rule.MetricName = strings.TrimSuffix(strings.TrimPrefix(rule.MetricName, "rate("), ")")
+	}
+
+	if rule.MetricName == "" {
+		return Rule{}, fmt.Errorf("invalid rule %q: metric name not provided", text)
+	}
+
+	if rule.Rate && rule.MetricType != constants.MetricTypeCounter {
+		return Rule{}, fmt.Errorf("invalid rule %q: rate supported only for counters", text)
+	}
+
+	rule.Operator = fields[2]
+
+	if _, ok := supportedOperators[rule.Operator]; !ok {
+		return Rule{}, fmt.Errorf("invalid rule %q: unsupported operator %s", text, rule.Operator)
+	}
+
+	threshold, err := strconv.ParseFloat(fields[3], 64)
+
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid rule %q: threshold must be a number", text)
+	}
+
+	rule.Threshold = threshold
+
+	if len(fields) == 6 {
+		if fields[4] != "for" {
+			return Rule{}, fmt.Errorf("invalid rule %q: expected for <duration>", text)
+		}
+
+		duration, err := time.ParseDuration(fields[5])
+
+		if err != nil || duration < 0 {
+			return Rule{}, fmt.Errorf("invalid rule %q: invalid duration %s", text, fields[5])
+		}
+
+		rule.For = duration
+	}
+
+	if rule.Name == "" {
+		rule.Name = rule.String()
+	}
+
+	return rule, nil
+}
+
+// ParseRules parses one rule per line. Empty lines and lines starting with # are skipped
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		rule, err := ParseRule(text)
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+func LoadRules(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseRules(file)
+}
@@ -0,0 +1,87 @@
+package alerting_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/alerting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected alerting.Rule
+		err      bool
+	}{
+		{
+			name: "gauge threshold with duration",
+			text: "gauge HeapAlloc > 5e8 for 2m",
+			expected: alerting.Rule{
+				Name:       "gauge HeapAlloc > 5e+08 for 2m0s",
+				MetricType: constants.MetricTypeGauge,
+				MetricName: "HeapAlloc",
+				Operator:   ">",
+				Threshold:  5e8,
+				For:        2 * time.Minute,
+			},
+		},
+		{
+			name: "named counter rate rule",
+			text: "AgentDown: counter rate(PollCount) == 0 for 5m",
+			expected: alerting.Rule{
+				Name:       "AgentDown",
+				MetricType: constants.MetricTypeCounter,
+				MetricName: "PollCount",
+				Rate:       true,
+				Operator:   "==",
+				Threshold:  0,
+				For:        5 * time.Minute,
+			},
+		},
+		{
+			name: "rule without duration",
+			text: "gauge CPUutilization1 >= 90",
+			expected: alerting.Rule{
+				Name:       "gauge CPUutilization1 >= 90",
+				MetricType: constants.MetricTypeGauge,
+				MetricName: "CPUutilization1",
+				Operator:   ">=",
+				Threshold:  90,
+			},
+		},
+		{name: "unsupported metric type", text: "histogram latency > 1", err: true},
+		{name: "rate of gauge", text: "gauge rate(HeapAlloc) > 1", err: true},
+		{name: "unsupported operator", text: "gauge HeapAlloc => 1", err: true},
+		{name: "invalid threshold", text: "gauge HeapAlloc > big", err: true},
+		{name: "invalid duration", text: "gauge HeapAlloc > 1 for ever", err: true},
+		{name: "missing threshold", text: "gauge HeapAlloc >", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := alerting.ParseRule(tc.text)
+
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rule)
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := alerting.ParseRules(strings.NewReader("# heap\ngauge HeapAlloc > 5e8 for 2m\n\ncounter rate(PollCount) == 0 for 5m\n"))
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	_, err = alerting.ParseRules(strings.NewReader("gauge HeapAlloc > 5e8\ngauge broken\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
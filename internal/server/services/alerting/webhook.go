@@ -0,0 +1,219 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/pkg/retry"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert, now time.Time) error
+	// Wait blocks until notifications started by Notify are finished
+	Wait()
+}
+
+// WebhookPayload group of alerts sent in a single webhook request.
+// Status is firing when at least one alert in group is firing, otherwise resolved
+type WebhookPayload struct {
+	Status State   `json:"status"`
+	Alerts []Alert `json:"alerts"`
+}
+
+type WebhookNotifierOptions struct {
+	URLs []string
+	// Client should have timeout, otherwise hanging receiver keeps its delivery forever
+	Client         *resty.Client
+	Signer         signer.Signer
+	RepeatInterval time.Duration
+	Logger         logger.ILogger
+	Backoff        func() retry.Backoff
+}
+
+type notifiedAlert struct {
+	state State
+	at    time.Time
+}
+
+// webhookReceiver is delivery state of single url
+type webhookReceiver struct {
+	url string
+	// busy is set while delivery to the url is in progress
+	busy     bool
+	notified map[string]notifiedAlert
+}
+
+// WebhookNotifier posts firing and resolved alerts to webhooks. Alert is sent again only when its state
+// changed or, for still firing alert, when repeat interval elapsed. Pending alerts are not sent.
+// Every url is notified in background, so slow or failing receiver delays neither evaluation nor others
+type WebhookNotifier struct {
+	// m guards receivers state, it is never held during delivery
+	m  sync.Mutex
+	wg sync.WaitGroup
+
+	receivers      []*webhookReceiver
+	client         *resty.Client
+	signer         signer.Signer
+	repeatInterval time.Duration
+	logger         logger.ILogger
+	backoff        func() retry.Backoff
+}
+
+// Notify starts delivery of alerts to every url which has no delivery in progress. Receiver that is still busy
+// gets its missed alerts on the next call, as alerts are marked as notified only after delivery
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []Alert, now time.Time) error {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	for _, receiver := range n.receivers {
+		if receiver.busy {
+			continue
+		}
+
+		payload := n.payload(receiver, alerts, now)
+
+		if len(payload.Alerts) == 0 {
+			continue
+		}
+
+		body, err := json.Marshal(payload)
+
+		if err != nil {
+			return err
+		}
+
+		receiver.busy = true
+		n.wg.Add(1)
+
+		go func(receiver *webhookReceiver) {
+			defer n.wg.Done()
+
+			n.deliver(ctx, receiver, payload, body, now)
+		}(receiver)
+	}
+
+	return nil
+}
+
+func (n *WebhookNotifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *WebhookNotifier) payload(receiver *webhookReceiver, alerts []Alert, now time.Time) WebhookPayload {
+	payload := WebhookPayload{Status: StateResolved}
+
+	for _, alert := range alerts {
+		if n.shouldNotify(receiver.notified, alert, now) {
+			payload.Alerts = append(payload.Alerts, alert)
+
+			if alert.State == StateFiring {
+				payload.Status = StateFiring
+			}
+		}
+	}
+
+	return payload
+}
+
+func (n *WebhookNotifier) deliver(ctx context.Context, receiver *webhookReceiver, payload WebhookPayload, body []byte, now time.Time) {
+	err := n.send(ctx, receiver.url, body)
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	receiver.busy = false
+
+	// failed alerts are sent on next notification
+	if err != nil {
+		n.logger.Errorw("alerting: error while sending webhook", "url", receiver.url, "error", err)
+		return
+	}
+
+	for _, alert := range payload.Alerts {
+		if alert.State == StateResolved {
+			delete(receiver.notified, alertKey(alert))
+			continue
+		}
+
+		receiver.notified[alertKey(alert)] = notifiedAlert{alert.State, now}
+	}
+
+	n.logger.Infow("alerting: webhook sent", "url", receiver.url, "status", payload.Status, "alerts", len(payload.Alerts))
+}
+
+func (n *WebhookNotifier) shouldNotify(notified map[string]notifiedAlert, alert Alert, now time.Time) bool {
+	prev, ok := notified[alertKey(alert)]
+
+	switch alert.State {
+	case StateFiring:
+		return !ok || prev.state != StateFiring || (n.repeatInterval > 0 && now.Sub(prev.at) >= n.repeatInterval)
+	case StateResolved:
+		// resolved is interesting only for receivers that got the firing notification
+		return ok && prev.state == StateFiring
+	}
+
+	return false
+}
+
+func (n *WebhookNotifier) send(ctx context.Context, url string, body []byte) error {
+	_, err := retry.DoWithData(ctx, n.backoff(), func(ctx context.Context) (*resty.Response, error) {
+		req := n.client.R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(body)
+
+		if n.signer != nil {
+			req.SetHeader(constants.HashHeader, n.signer.Sign(body))
+		}
+
+		resp, err := req.Post(url)
+
+		if err != nil {
+			return resp, retry.RetryableError(err)
+		}
+
+		if resp.StatusCode() >= http.StatusInternalServerError {
+			return resp, retry.RetryableError(fmt.Errorf("webhook responded with status %d", resp.StatusCode()))
+		}
+
+		if !resp.IsSuccess() {
+			return resp, fmt.Errorf("webhook responded with status %d", resp.StatusCode())
+		}
+
+		return resp, nil
+	})
+
+	return err
+}
+
+func alertKey(alert Alert) string {
+	return alert.Name + "\x00" + alert.Rule
+}
+
+func NewWebhookNotifier(options WebhookNotifierOptions) *WebhookNotifier {
+	backoff := options.Backoff
+
+	if backoff == nil {
+		backoff = retry.NewBaseBackoff
+	}
+
+	receivers := make([]*webhookReceiver, 0, len(options.URLs))
+
+	for _, url := range options.URLs {
+		receivers = append(receivers, &webhookReceiver{url: url, notified: make(map[string]notifiedAlert)})
+	}
+
+	return &WebhookNotifier{
+		receivers:      receivers,
+		client:         options.Client,
+		signer:         options.Signer,
+		repeatInterval: options.RepeatInterval,
+		logger:         options.Logger,
+		backoff:        backoff,
+	}
+}
@@ -0,0 +1,233 @@
+package alerting_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/alerting"
+	"github.com/sodiqit/metricpulse.git/pkg/retry"
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := signer.NewSHA256Signer("secret")
+
+	var received []alerting.WebhookPayload
+	status := http.StatusOK
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		assert.True(t, s.Verify(body, r.Header.Get(constants.HashHeader)), "invalid webhook signature")
+
+		if status == http.StatusOK {
+			var payload alerting.WebhookPayload
+			require.NoError(t, json.Unmarshal(body, &payload))
+			received = append(received, payload)
+		}
+
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	notifier := alerting.NewWebhookNotifier(alerting.WebhookNotifierOptions{
+		URLs:           []string{ts.URL},
+		Client:         resty.New(),
+		Signer:         s,
+		RepeatInterval: time.Hour,
+		Logger:         logger,
+		Backoff:        func() retry.Backoff { return retry.EmptyBackoff },
+	})
+
+	heap := alerting.Alert{Name: "HighHeap", Rule: "gauge HeapAlloc > 100", State: alerting.StateFiring}
+	cpu := alerting.Alert{Name: "HighCPU", Rule: "gauge CPUutilization1 > 90", State: alerting.StatePending}
+
+	resolved := func(alert alerting.Alert) alerting.Alert {
+		alert.State = alerting.StateResolved
+		return alert
+	}
+
+	steps := []struct {
+		name     string
+		after    time.Duration
+		alerts   []alerting.Alert
+		status   int
+		expected []alerting.WebhookPayload
+	}{
+		{
+			name:     "firing alert is sent, pending is not",
+			alerts:   []alerting.Alert{heap, cpu},
+			expected: []alerting.WebhookPayload{{Status: alerting.StateFiring, Alerts: []alerting.Alert{heap}}},
+		},
+		{
+			name:   "still firing alert is not repeated before repeat interval",
+			after:  time.Minute,
+			alerts: []alerting.Alert{heap},
+		},
+		{
+			name:     "firing alert is repeated after repeat interval",
+			after:    time.Hour,
+			alerts:   []alerting.Alert{heap},
+			expected: []alerting.WebhookPayload{{Status: alerting.StateFiring, Alerts: []alerting.Alert{heap}}},
+		},
+		{
+			name:   "failed delivery is retried on next notification",
+			after:  time.Hour + time.Minute,
+			alerts: []alerting.Alert{resolved(heap)},
+			status: http.StatusBadGateway,
+		},
+		{
+			name:     "resolved alert is sent once",
+			after:    time.Hour + 2*time.Minute,
+			alerts:   []alerting.Alert{resolved(heap)},
+			expected: []alerting.WebhookPayload{{Status: alerting.StateResolved, Alerts: []alerting.Alert{resolved(heap)}}},
+		},
+		{
+			name:   "resolved alert is not repeated",
+			after:  time.Hour + 3*time.Minute,
+			alerts: []alerting.Alert{resolved(heap)},
+		},
+		{
+			name:   "resolved alert without firing notification is not sent",
+			after:  time.Hour + 4*time.Minute,
+			alerts: []alerting.Alert{resolved(cpu)},
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			received = nil
+			status = http.StatusOK
+
+			if step.status != 0 {
+				status = step.status
+			}
+
+			err := notifier.Notify(ctx, step.alerts, start.Add(step.after))
+			require.NoError(t, err)
+
+			notifier.Wait()
+
+			assert.Equal(t, step.expected, received)
+		})
+	}
+}
+
+func TestWebhookNotifier_NotifyFailingReceiver(t *testing.T) {
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	healthyCalls, failingCalls := 0, 0
+	failing := true
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyCalls++
+	}))
+	defer healthy.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingCalls++
+
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer broken.Close()
+
+	notifier := alerting.NewWebhookNotifier(alerting.WebhookNotifierOptions{
+		URLs:           []string{healthy.URL, broken.URL},
+		Client:         resty.New(),
+		RepeatInterval: time.Hour,
+		Logger:         logger,
+		Backoff:        func() retry.Backoff { return retry.EmptyBackoff },
+	})
+
+	heap := alerting.Alert{Name: "HighHeap", Rule: "gauge HeapAlloc > 100", State: alerting.StateFiring}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, notifier.Notify(ctx, []alerting.Alert{heap}, start.Add(time.Duration(i)*time.Minute)))
+		notifier.Wait()
+	}
+
+	// healthy receiver is notified once, failing one is retried on every notification
+	assert.Equal(t, 1, healthyCalls)
+	assert.Equal(t, 3, failingCalls)
+
+	failing = false
+
+	for i := 3; i < 5; i++ {
+		require.NoError(t, notifier.Notify(ctx, []alerting.Alert{heap}, start.Add(time.Duration(i)*time.Minute)))
+		notifier.Wait()
+	}
+
+	assert.Equal(t, 1, healthyCalls)
+	assert.Equal(t, 4, failingCalls)
+}
+
+func TestWebhookNotifier_NotifyHangingReceiver(t *testing.T) {
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	release := make(chan struct{})
+	calls := make(chan struct{}, 10)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- struct{}{}
+		<-release
+	}))
+	defer ts.Close()
+
+	notifier := alerting.NewWebhookNotifier(alerting.WebhookNotifierOptions{
+		URLs:           []string{ts.URL},
+		Client:         resty.New().SetTimeout(5 * time.Second),
+		RepeatInterval: time.Minute,
+		Logger:         logger,
+		Backoff:        func() retry.Backoff { return retry.EmptyBackoff },
+	})
+
+	heap := alerting.Alert{Name: "HighHeap", Rule: "gauge HeapAlloc > 100", State: alerting.StateFiring}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// receiver is busy with the first delivery, so the second call doesn't start another one
+		assert.NoError(t, notifier.Notify(ctx, []alerting.Alert{heap}, start))
+		<-calls
+		assert.NoError(t, notifier.Notify(ctx, []alerting.Alert{heap}, start.Add(time.Hour)))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify is blocked by hanging receiver")
+	}
+
+	close(release)
+	notifier.Wait()
+
+	assert.Empty(t, calls)
+}
@@ -0,0 +1,213 @@
+package compactor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+)
+
+type CompactorOptions struct {
+	Storage  storage.Storage
+	Tiers    []entities.RetentionTier
+	Interval time.Duration
+	Logger   logger.ILogger
+}
+
+// Compactor rolls history up into retention tiers and removes history older than tier TTL.
+// Every tier is rolled up from the previous one, the first one from raw samples
+type Compactor struct {
+	storage  storage.Storage
+	tiers    []entities.RetentionTier
+	interval time.Duration
+	logger   logger.ILogger
+	// compacted keeps end of the last rolled up bucket of every tier
+	compacted map[time.Duration]time.Time
+}
+
+type series struct {
+	metricType string
+	name       string
+}
+
+// Run compacts history every interval until ctx is done
+func (c *Compactor) Run(ctx context.Context) error {
+	if len(c.tiers) == 0 || c.interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			c.logger.Infow("compactor: stop", "reason", ctx.Err())
+			return nil
+		}
+
+		if err := c.Compact(ctx, time.Now()); err != nil {
+			c.logger.Errorw("compactor: error while compacting history", "error", err)
+		}
+	}
+}
+
+// Compact rolls up complete buckets of every tier and then removes expired history of every tier
+func (c *Compactor) Compact(ctx context.Context, now time.Time) error {
+	metrics, err := c.storage.GetAllMetrics(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	list := listSeries(metrics)
+
+	for i := 1; i < len(c.tiers); i++ {
+		if err := c.rollup(ctx, list, c.tiers[i-1], c.tiers[i], now); err != nil {
+			return err
+		}
+	}
+
+	for _, tier := range c.tiers {
+		if err := c.storage.DeleteHistory(ctx, tier.Resolution, now.Add(-tier.TTL)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollup aggregates source history into complete buckets of tier which were not rolled up yet.
+// After restart buckets are rolled up again from the first one fully kept by source, saving equal rollups is idempotent
+func (c *Compactor) rollup(ctx context.Context, list []series, source entities.RetentionTier, tier entities.RetentionTier, now time.Time) error {
+	to := now.Truncate(tier.Resolution)
+
+	from, ok := c.compacted[tier.Resolution]
+
+	if !ok {
+		kept := now.Add(-source.TTL)
+		from = kept.Truncate(tier.Resolution)
+
+		// partially removed bucket would replace its complete rollup
+		if from.Before(kept) {
+			from = from.Add(tier.Resolution)
+		}
+	}
+
+	if !from.Before(to) {
+		return nil
+	}
+
+	saved := 0
+
+	for _, s := range list {
+		rollups, err := c.sourceRollups(ctx, s, source, tier, from, to)
+
+		if err != nil {
+			return err
+		}
+
+		if len(rollups) == 0 {
+			continue
+		}
+
+		if err := c.storage.SaveRollups(ctx, s.metricType, s.name, tier.Resolution, rollups); err != nil {
+			return err
+		}
+
+		saved += len(rollups)
+	}
+
+	c.compacted[tier.Resolution] = to
+
+	c.logger.Infow("compactor: history rolled up", "tier", tier.String(), "from", from, "to", to, "rollups", saved)
+
+	return nil
+}
+
+// sourceRollups aggregates source history of the series in [from, to) into buckets of tier resolution
+func (c *Compactor) sourceRollups(ctx context.Context, s series, source entities.RetentionTier, tier entities.RetentionTier, from time.Time, to time.Time) ([]entities.Rollup, error) {
+	// history is read with inclusive bounds
+	last := to.Add(-time.Nanosecond)
+
+	var values []entities.Rollup
+
+	if source.IsRaw() {
+		samples, err := c.storage.GetMetricHistory(ctx, s.metricType, s.name, from, last)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sample := range samples {
+			values = append(values, entities.NewRollup(sample.Timestamp, entities.SampleValue(s.metricType, sample)))
+		}
+	} else {
+		var err error
+
+		values, err = c.storage.GetRollups(ctx, s.metricType, s.name, source.Resolution, from, last)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buckets := make(map[time.Time]entities.Rollup)
+
+	for _, value := range values {
+		start := value.Timestamp.Truncate(tier.Resolution)
+
+		bucket, ok := buckets[start]
+
+		if !ok {
+			bucket = entities.Rollup{Timestamp: start}
+		}
+
+		buckets[start] = bucket.Merge(value)
+	}
+
+	result := make([]entities.Rollup, 0, len(buckets))
+
+	for _, bucket := range buckets {
+		result = append(result, bucket)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+
+	return result, nil
+}
+
+func listSeries(metrics entities.TotalMetrics) []series {
+	var result []series
+
+	for name := range metrics.Gauge {
+		result = append(result, series{constants.MetricTypeGauge, name})
+	}
+
+	for name := range metrics.Counter {
+		result = append(result, series{constants.MetricTypeCounter, name})
+	}
+
+	for name := range metrics.Histogram {
+		result = append(result, series{constants.MetricTypeHistogram, name})
+	}
+
+	return result
+}
+
+func New(options CompactorOptions) *Compactor {
+	return &Compactor{
+		storage:   options.Storage,
+		tiers:     options.Tiers,
+		interval:  options.Interval,
+		logger:    options.Logger,
+		compacted: make(map[time.Duration]time.Time),
+	}
+}
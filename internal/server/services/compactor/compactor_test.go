@@ -0,0 +1,129 @@
+package compactor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/compactor"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactor_Compact(t *testing.T) {
+	ctx := context.Background()
+
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	tiers, err := entities.ParseRetentionTiers("raw:1h,1m:24h,1h:720h")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		tBody func(store *storage.MemStorage, c *compactor.Compactor)
+	}{
+		{
+			name: "should roll up raw samples into complete buckets",
+			tBody: func(store *storage.MemStorage, c *compactor.Compactor) {
+				start := time.Now()
+
+				for _, value := range []float64{1, 5, 3} {
+					_, err := store.SaveGaugeMetric(ctx, `Alloc{host="web01"}`, value)
+					require.NoError(t, err)
+				}
+
+				// bucket which is still filled is not rolled up
+				err := c.Compact(ctx, start)
+				require.NoError(t, err)
+
+				rollups, err := store.GetRollups(ctx, constants.MetricTypeGauge, `Alloc{host="web01"}`, time.Minute, start.Add(-time.Hour), start.Add(time.Hour))
+				require.NoError(t, err)
+				assert.Empty(t, rollups)
+
+				err = c.Compact(ctx, start.Add(2*time.Minute))
+				require.NoError(t, err)
+
+				rollups, err = store.GetRollups(ctx, constants.MetricTypeGauge, `Alloc{host="web01"}`, time.Minute, start.Add(-time.Hour), start.Add(time.Hour))
+				require.NoError(t, err)
+
+				bucket := start.Truncate(time.Minute)
+				require.Len(t, rollups, 1)
+				assert.Equal(t, entities.Rollup{Timestamp: bucket, Min: 1, Max: 5, Sum: 9, Count: 3}, rollups[0])
+			},
+		},
+		{
+			name: "should roll up tiers from previous tier and remove expired history",
+			tBody: func(store *storage.MemStorage, c *compactor.Compactor) {
+				start := time.Now()
+
+				_, err := store.SaveCounterMetric(ctx, "PollCount", 2)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "PollCount", 3)
+				require.NoError(t, err)
+
+				err = c.Compact(ctx, start.Add(2*time.Minute))
+				require.NoError(t, err)
+
+				// raw samples are expired, minute rollups are rolled up into hour ones
+				err = c.Compact(ctx, start.Add(2*time.Hour))
+				require.NoError(t, err)
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, "PollCount", time.Time{}, start.Add(time.Hour))
+				require.NoError(t, err)
+				assert.Empty(t, history)
+
+				rollups, err := store.GetRollups(ctx, constants.MetricTypeCounter, "PollCount", time.Hour, time.Time{}, start.Add(3*time.Hour))
+				require.NoError(t, err)
+				require.Len(t, rollups, 1)
+				assert.Equal(t, entities.Rollup{Timestamp: start.Truncate(time.Hour), Min: 2, Max: 5, Sum: 7, Count: 2}, rollups[0])
+
+				err = c.Compact(ctx, start.Add(48*time.Hour))
+				require.NoError(t, err)
+
+				rollups, err = store.GetRollups(ctx, constants.MetricTypeCounter, "PollCount", time.Minute, time.Time{}, start.Add(3*time.Hour))
+				require.NoError(t, err)
+				assert.Empty(t, rollups)
+
+				rollups, err = store.GetRollups(ctx, constants.MetricTypeCounter, "PollCount", time.Hour, time.Time{}, start.Add(3*time.Hour))
+				require.NoError(t, err)
+				assert.Len(t, rollups, 1)
+			},
+		},
+		{
+			name: "should remove rollups of deleted series",
+			tBody: func(store *storage.MemStorage, c *compactor.Compactor) {
+				start := time.Now()
+
+				_, err := store.SaveGaugeMetric(ctx, "temp", 1)
+				require.NoError(t, err)
+
+				err = c.Compact(ctx, start.Add(2*time.Minute))
+				require.NoError(t, err)
+
+				err = store.DeleteMetric(ctx, constants.MetricTypeGauge, "temp")
+				require.NoError(t, err)
+
+				rollups, err := store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, time.Time{}, start.Add(time.Hour))
+				require.NoError(t, err)
+				assert.Empty(t, rollups)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemStorage()
+
+			tt.tBody(store, compactor.New(compactor.CompactorOptions{
+				Storage:  store,
+				Tiers:    tiers,
+				Interval: time.Minute,
+				Logger:   logger,
+			}))
+		})
+	}
+}
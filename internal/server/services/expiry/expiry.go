@@ -0,0 +1,87 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+)
+
+const (
+	minCheckInterval = time.Second
+	maxCheckInterval = time.Minute
+)
+
+type ExpirerOptions struct {
+	Storage    storage.Storage
+	MetricType string
+	TTL        time.Duration
+	Logger     logger.ILogger
+}
+
+// Expirer removes series of one metric type which were not updated for TTL
+type Expirer struct {
+	storage    storage.Storage
+	metricType string
+	ttl        time.Duration
+	logger     logger.ILogger
+}
+
+// Run checks series every tenth of TTL (but not rarer than once a minute) until ctx is done
+func (e *Expirer) Run(ctx context.Context) error {
+	if e.ttl <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(checkInterval(e.ttl))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			e.logger.Infow("expiry: stop", "reason", ctx.Err())
+			return nil
+		}
+
+		e.Expire(ctx, time.Now())
+	}
+}
+
+// Expire removes series not updated since now - TTL
+func (e *Expirer) Expire(ctx context.Context, now time.Time) {
+	expired, err := e.storage.ExpireMetrics(ctx, e.metricType, now.Add(-e.ttl))
+
+	if err != nil {
+		e.logger.Errorw("expiry: error while expiring metrics", "metricType", e.metricType, "error", err)
+		return
+	}
+
+	if len(expired) > 0 {
+		e.logger.Infow("expiry: stale series removed", "metricType", e.metricType, "series", expired)
+	}
+}
+
+func checkInterval(ttl time.Duration) time.Duration {
+	interval := ttl / 10
+
+	if interval < minCheckInterval {
+		return minCheckInterval
+	}
+
+	if interval > maxCheckInterval {
+		return maxCheckInterval
+	}
+
+	return interval
+}
+
+func New(options ExpirerOptions) *Expirer {
+	return &Expirer{
+		storage:    options.Storage,
+		metricType: options.MetricType,
+		ttl:        options.TTL,
+		logger:     options.Logger,
+	}
+}
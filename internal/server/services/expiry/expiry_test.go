@@ -0,0 +1,87 @@
+package expiry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/expiry"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestExpirer_Expire(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	storageMock := storage.NewMockStorage(ctrl)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	expirer := expiry.New(expiry.ExpirerOptions{
+		Storage:    storageMock,
+		MetricType: constants.MetricTypeGauge,
+		TTL:        time.Hour,
+		Logger:     logger,
+	})
+
+	tests := []struct {
+		name      string
+		setupMock func()
+	}{
+		{
+			name: "should expire series not updated for ttl",
+			setupMock: func() {
+				storageMock.EXPECT().ExpireMetrics(gomock.Any(), constants.MetricTypeGauge, now.Add(-time.Hour)).Times(1).Return([]string{"Alloc"}, nil)
+			},
+		},
+		{
+			name: "should survive storage error",
+			setupMock: func() {
+				storageMock.EXPECT().ExpireMetrics(gomock.Any(), constants.MetricTypeGauge, now.Add(-time.Hour)).Times(1).Return(nil, errors.New("expire error"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+
+			expirer.Expire(context.Background(), now)
+		})
+	}
+}
+
+func TestExpirer_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+
+	storageMock := storage.NewMockStorage(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// ttl of 5 seconds is checked every second
+	storageMock.EXPECT().ExpireMetrics(gomock.Any(), constants.MetricTypeGauge, gomock.Any()).MinTimes(1).DoAndReturn(func(context.Context, string, time.Time) ([]string, error) {
+		cancel()
+		return nil, nil
+	})
+
+	expirer := expiry.New(expiry.ExpirerOptions{
+		Storage:    storageMock,
+		MetricType: constants.MetricTypeGauge,
+		TTL:        5 * time.Second,
+		Logger:     logger,
+	})
+
+	err = expirer.Run(ctx)
+	require.NoError(t, err)
+}
@@ -13,7 +13,11 @@ import (
 type MetricService interface {
 	SaveMetric(ctx context.Context, metricType string, metricName string, metricValue MetricValue) (MetricValue, error)
 	GetMetric(ctx context.Context, metricType string, metricName string) (MetricValue, error)
+	// AddGauge adds delta to the gauge atomically, relative statsd gauges don't lose concurrent updates
+	AddGauge(ctx context.Context, metricName string, delta float64) (float64, error)
 	GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error)
+	// QueryRange returns history of the series aggregated in buckets, empty buckets are skipped
+	QueryRange(ctx context.Context, query RangeQuery) ([]RangePoint, error)
 }
 
 type MetricProcessor struct {
@@ -22,8 +26,9 @@ type MetricProcessor struct {
 }
 
 type MetricValue struct {
-	Gauge   float64
-	Counter int64
+	Gauge     float64
+	Counter   int64
+	Histogram entities.Histogram
 }
 
 func (s *MetricProcessor) SaveMetric(ctx context.Context, metricType string, metricName string, metricValue MetricValue) (MetricValue, error) {
@@ -37,6 +42,9 @@ func (s *MetricProcessor) SaveMetric(ctx context.Context, metricType string, met
 	case constants.MetricTypeCounter:
 		val, err := s.storage.SaveCounterMetric(ctx, metricName, metricValue.Counter)
 		result, saveErr = MetricValue{Counter: val}, err
+	case constants.MetricTypeHistogram:
+		val, err := s.storage.SaveHistogramMetric(ctx, metricName, metricValue.Histogram)
+		result, saveErr = MetricValue{Histogram: val}, err
 	default:
 		saveErr = fmt.Errorf("unsupported metricType: %s", metricType)
 	}
@@ -44,6 +52,10 @@ func (s *MetricProcessor) SaveMetric(ctx context.Context, metricType string, met
 	return result, saveErr
 }
 
+func (s *MetricProcessor) AddGauge(ctx context.Context, metricName string, delta float64) (float64, error) {
+	return s.storage.AddGaugeMetric(ctx, metricName, delta)
+}
+
 func (s *MetricProcessor) GetMetric(ctx context.Context, metricType string, metricName string) (MetricValue, error) {
 	switch metricType {
 	case constants.MetricTypeGauge:
@@ -52,6 +64,9 @@ func (s *MetricProcessor) GetMetric(ctx context.Context, metricType string, metr
 	case constants.MetricTypeCounter:
 		val, err := s.storage.GetCounterMetric(ctx, metricName)
 		return MetricValue{Counter: val}, err
+	case constants.MetricTypeHistogram:
+		val, err := s.storage.GetHistogramMetric(ctx, metricName)
+		return MetricValue{Histogram: val}, err
 	}
 
 	return MetricValue{}, fmt.Errorf("unsupported metricType: %s", metricType)
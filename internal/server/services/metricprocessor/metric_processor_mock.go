@@ -40,6 +40,21 @@ func (m *MockMetricService) EXPECT() *MockMetricServiceMockRecorder {
 	return m.recorder
 }
 
+// AddGauge mocks base method.
+func (m *MockMetricService) AddGauge(ctx context.Context, metricName string, delta float64) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddGauge", ctx, metricName, delta)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddGauge indicates an expected call of AddGauge.
+func (mr *MockMetricServiceMockRecorder) AddGauge(ctx, metricName, delta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddGauge", reflect.TypeOf((*MockMetricService)(nil).AddGauge), ctx, metricName, delta)
+}
+
 // GetAllMetrics mocks base method.
 func (m *MockMetricService) GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error) {
 	m.ctrl.T.Helper()
@@ -70,6 +85,21 @@ func (mr *MockMetricServiceMockRecorder) GetMetric(ctx, metricType, metricName a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetric", reflect.TypeOf((*MockMetricService)(nil).GetMetric), ctx, metricType, metricName)
 }
 
+// QueryRange mocks base method.
+func (m *MockMetricService) QueryRange(ctx context.Context, query RangeQuery) ([]RangePoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryRange", ctx, query)
+	ret0, _ := ret[0].([]RangePoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryRange indicates an expected call of QueryRange.
+func (mr *MockMetricServiceMockRecorder) QueryRange(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRange", reflect.TypeOf((*MockMetricService)(nil).QueryRange), ctx, query)
+}
+
 // SaveMetric mocks base method.
 func (m *MockMetricService) SaveMetric(ctx context.Context, metricType, metricName string, metricValue MetricValue) (MetricValue, error) {
 	m.ctrl.T.Helper()
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
 	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
 	"github.com/sodiqit/metricpulse.git/internal/server/storage"
@@ -91,6 +92,17 @@ func TestMetricProcessor_SaveMetric(t *testing.T) {
 			metricValue: metricprocessor.MetricValue{Counter: 5},
 			returnValue: metricprocessor.MetricValue{Counter: 5},
 		},
+		{
+			name:   "success histogram metric save",
+			config: &config.Config{StoreInterval: 10},
+			setupMock: func() {
+				storage.EXPECT().SaveHistogramMetric(gomock.Any(), "latency", gomock.Any()).Times(1).Return(entities.Histogram{Bounds: []float64{1}, Counts: []int64{2, 1}, Sum: 3, Count: 3}, nil)
+			},
+			metricType:  constants.MetricTypeHistogram,
+			metricName:  "latency",
+			metricValue: metricprocessor.MetricValue{Histogram: entities.Histogram{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: 0.5, Count: 1}},
+			returnValue: metricprocessor.MetricValue{Histogram: entities.Histogram{Bounds: []float64{1}, Counts: []int64{2, 1}, Sum: 3, Count: 3}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,273 @@
+package metricprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+)
+
+const (
+	AggAvg  = "avg"
+	AggMin  = "min"
+	AggMax  = "max"
+	AggSum  = "sum"
+	AggRate = "rate"
+)
+
+// maxQueryPoints keeps single query from building huge responses
+const maxQueryPoints = 11000
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// RangeQuery selects samples of one series in [Start, End] and aggregates them in buckets of Step
+type RangeQuery struct {
+	MetricType string
+	MetricName string
+	Start      time.Time
+	End        time.Time
+	Step       time.Duration
+	Agg        string
+}
+
+func (q RangeQuery) Validate() error {
+	if q.MetricName == "" {
+		return fmt.Errorf("%w: metric name not provided", ErrInvalidQuery)
+	}
+
+	switch q.MetricType {
+	case constants.MetricTypeGauge, constants.MetricTypeCounter, constants.MetricTypeHistogram:
+	default:
+		return fmt.Errorf("%w: unknown metricType: %s", ErrInvalidQuery, q.MetricType)
+	}
+
+	switch q.Agg {
+	case AggAvg, AggMin, AggMax, AggSum:
+	case AggRate:
+		if q.MetricType == constants.MetricTypeGauge {
+			return fmt.Errorf("%w: rate is supported only for counter and histogram", ErrInvalidQuery)
+		}
+	default:
+		return fmt.Errorf("%w: unknown aggregation: %s", ErrInvalidQuery, q.Agg)
+	}
+
+	if q.Step <= 0 {
+		return fmt.Errorf("%w: step must be positive", ErrInvalidQuery)
+	}
+
+	if q.End.Before(q.Start) {
+		return fmt.Errorf("%w: end must not be before start", ErrInvalidQuery)
+	}
+
+	if q.End.Sub(q.Start)/q.Step >= maxQueryPoints {
+		return fmt.Errorf("%w: query exceeds %d points, increase step", ErrInvalidQuery, maxQueryPoints)
+	}
+
+	return nil
+}
+
+// RangePoint is aggregated value of bucket which starts at Timestamp
+type RangePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// QueryRange reads the finest retention tier which still keeps history from query start.
+// Coarser tier has only buckets rolled up so far, the rest of the range is read from finer tiers
+func (s *MetricProcessor) QueryRange(ctx context.Context, query RangeQuery) ([]RangePoint, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	tiers, err := entities.ParseRetentionTiers(s.config.Retention)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tier := entities.SelectRetentionTier(tiers, query.Start, now)
+
+	from := query.Start
+
+	// rate needs the sample before the first bucket to count increase inside it
+	if query.Agg == AggRate {
+		from = from.Add(-query.Step)
+	}
+
+	rollups, err := s.tieredHistory(ctx, query, finerTiers(tiers, tier), from, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Agg == AggRate {
+		return rate(query, rollups), nil
+	}
+
+	return aggregate(query, rollups), nil
+}
+
+// finerTiers returns the tier followed by finer tiers, from coarse to raw
+func finerTiers(tiers []entities.RetentionTier, tier entities.RetentionTier) []entities.RetentionTier {
+	result := []entities.RetentionTier{tier}
+
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if tiers[i].Resolution < tier.Resolution {
+			result = append(result, tiers[i])
+		}
+	}
+
+	return result
+}
+
+// tieredHistory reads history of the first tier and continues with every next tier after the last bucket
+// read so far, so buckets not rolled up yet are taken from finer history. Tier is skipped if it doesn't keep
+// history of query end anymore
+func (s *MetricProcessor) tieredHistory(ctx context.Context, query RangeQuery, tiers []entities.RetentionTier, from time.Time, now time.Time) ([]entities.Rollup, error) {
+	var result []entities.Rollup
+
+	for i, tier := range tiers {
+		if from.After(query.End) {
+			break
+		}
+
+		if i > 0 && query.End.Before(now.Add(-tier.TTL)) {
+			continue
+		}
+
+		rollups, err := s.history(ctx, query, tier, from)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rollups) == 0 {
+			continue
+		}
+
+		result = append(result, rollups...)
+
+		// rollup covers [timestamp, timestamp + resolution)
+		from = rollups[len(rollups)-1].Timestamp.Add(tier.Resolution)
+	}
+
+	return result, nil
+}
+
+// history returns history of the series from the tier as rollups, raw sample is rollup of one value
+func (s *MetricProcessor) history(ctx context.Context, query RangeQuery, tier entities.RetentionTier, from time.Time) ([]entities.Rollup, error) {
+	if !tier.IsRaw() {
+		return s.storage.GetRollups(ctx, query.MetricType, query.MetricName, tier.Resolution, from, query.End)
+	}
+
+	samples, err := s.storage.GetMetricHistory(ctx, query.MetricType, query.MetricName, from, query.End)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rollups := make([]entities.Rollup, 0, len(samples))
+
+	for _, sample := range samples {
+		rollups = append(rollups, entities.NewRollup(sample.Timestamp, entities.SampleValue(query.MetricType, sample)))
+	}
+
+	return rollups, nil
+}
+
+// bucketIndex returns index of bucket of the timestamp, false if it is out of query range
+func bucketIndex(query RangeQuery, ts time.Time) (int, bool) {
+	if ts.Before(query.Start) || ts.After(query.End) {
+		return 0, false
+	}
+
+	return int(ts.Sub(query.Start) / query.Step), true
+}
+
+// collect groups rollups by buckets and returns value of every non-empty bucket in time order
+func collect(query RangeQuery, add func(buckets map[int]*entities.Rollup), value func(bucket entities.Rollup) float64) []RangePoint {
+	buckets := make(map[int]*entities.Rollup)
+
+	add(buckets)
+
+	points := []RangePoint{}
+
+	for i := 0; i <= int(query.End.Sub(query.Start)/query.Step); i++ {
+		if b, ok := buckets[i]; ok {
+			points = append(points, RangePoint{Timestamp: b.Timestamp, Value: value(*b)})
+		}
+	}
+
+	return points
+}
+
+func getBucket(buckets map[int]*entities.Rollup, query RangeQuery, i int) *entities.Rollup {
+	b, ok := buckets[i]
+
+	if !ok {
+		b = &entities.Rollup{Timestamp: query.Start.Add(time.Duration(i) * query.Step)}
+		buckets[i] = b
+	}
+
+	return b
+}
+
+func aggregate(query RangeQuery, rollups []entities.Rollup) []RangePoint {
+	add := func(buckets map[int]*entities.Rollup) {
+		for _, rollup := range rollups {
+			i, ok := bucketIndex(query, rollup.Timestamp)
+
+			if !ok {
+				continue
+			}
+
+			b := getBucket(buckets, query, i)
+			*b = b.Merge(rollup)
+		}
+	}
+
+	return collect(query, add, func(bucket entities.Rollup) float64 {
+		switch query.Agg {
+		case AggMin:
+			return bucket.Min
+		case AggMax:
+			return bucket.Max
+		case AggSum:
+			return bucket.Sum
+		default:
+			return bucket.Avg()
+		}
+	})
+}
+
+// rate returns per-second increase of the counter in every bucket. Decrease of the counter means reset,
+// so value after reset is counted as increase from zero. Counter value of rollup is its max
+func rate(query RangeQuery, rollups []entities.Rollup) []RangePoint {
+	add := func(buckets map[int]*entities.Rollup) {
+		for j := 1; j < len(rollups); j++ {
+			i, ok := bucketIndex(query, rollups[j].Timestamp)
+
+			if !ok {
+				continue
+			}
+
+			prev, cur := rollups[j-1].Max, rollups[j].Max
+
+			increase := cur - prev
+
+			if cur < prev {
+				increase = cur
+			}
+
+			getBucket(buckets, query, i).Sum += increase
+		}
+	}
+
+	return collect(query, add, func(bucket entities.Rollup) float64 {
+		return bucket.Sum / query.Step.Seconds()
+	})
+}
@@ -0,0 +1,256 @@
+package metricprocessor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/internal/server/services/metricprocessor"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMetricProcessor_QueryRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage := storage.NewMockStorage(ctrl)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	gauges := []entities.MetricSample{
+		{Timestamp: at(0), Gauge: 1},
+		{Timestamp: at(30), Gauge: 3},
+		{Timestamp: at(60), Gauge: 5},
+		{Timestamp: at(150), Gauge: 2},
+	}
+
+	query := metricprocessor.RangeQuery{
+		MetricType: constants.MetricTypeGauge,
+		MetricName: "Alloc",
+		Start:      start,
+		End:        at(179),
+		Step:       time.Minute,
+	}
+
+	tests := []struct {
+		name      string
+		setupMock func()
+		query     func() metricprocessor.RangeQuery
+		expected  []metricprocessor.RangePoint
+		err       error
+	}{
+		{
+			name: "avg skips empty buckets",
+			setupMock: func() {
+				storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeGauge, "Alloc", start, at(179)).Times(1).Return(gauges, nil)
+			},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggAvg
+				return q
+			},
+			expected: []metricprocessor.RangePoint{{Timestamp: at(0), Value: 2}, {Timestamp: at(60), Value: 5}, {Timestamp: at(120), Value: 2}},
+		},
+		{
+			name: "min",
+			setupMock: func() {
+				storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeGauge, "Alloc", start, at(179)).Times(1).Return(gauges, nil)
+			},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggMin
+				return q
+			},
+			expected: []metricprocessor.RangePoint{{Timestamp: at(0), Value: 1}, {Timestamp: at(60), Value: 5}, {Timestamp: at(120), Value: 2}},
+		},
+		{
+			name: "max",
+			setupMock: func() {
+				storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeGauge, "Alloc", start, at(179)).Times(1).Return(gauges, nil)
+			},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggMax
+				return q
+			},
+			expected: []metricprocessor.RangePoint{{Timestamp: at(0), Value: 3}, {Timestamp: at(60), Value: 5}, {Timestamp: at(120), Value: 2}},
+		},
+		{
+			name: "sum",
+			setupMock: func() {
+				storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeGauge, "Alloc", start, at(179)).Times(1).Return(gauges, nil)
+			},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggSum
+				return q
+			},
+			expected: []metricprocessor.RangePoint{{Timestamp: at(0), Value: 4}, {Timestamp: at(60), Value: 5}, {Timestamp: at(120), Value: 2}},
+		},
+		{
+			name: "rate handles counter reset",
+			setupMock: func() {
+				storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeCounter, "PollCount", at(-60), at(179)).Times(1).Return([]entities.MetricSample{
+					{Timestamp: at(-10), Counter: 100},
+					{Timestamp: at(20), Counter: 160},
+					{Timestamp: at(50), Counter: 220},
+					{Timestamp: at(70), Counter: 30},
+					{Timestamp: at(100), Counter: 90},
+					{Timestamp: at(130), Counter: 90},
+				}, nil)
+			},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.MetricType = constants.MetricTypeCounter
+				q.MetricName = "PollCount"
+				q.Agg = metricprocessor.AggRate
+				return q
+			},
+			expected: []metricprocessor.RangePoint{{Timestamp: at(0), Value: 2}, {Timestamp: at(60), Value: 1.5}, {Timestamp: at(120), Value: 0}},
+		},
+		{
+			name:      "rate of gauge",
+			setupMock: func() {},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggRate
+				return q
+			},
+			err: metricprocessor.ErrInvalidQuery,
+		},
+		{
+			name:      "unknown aggregation",
+			setupMock: func() {},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = "median"
+				return q
+			},
+			err: metricprocessor.ErrInvalidQuery,
+		},
+		{
+			name:      "too many points",
+			setupMock: func() {},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggAvg
+				q.Step = time.Millisecond
+				return q
+			},
+			err: metricprocessor.ErrInvalidQuery,
+		},
+		{
+			name: "storage error",
+			setupMock: func() {
+				storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeGauge, "Alloc", start, at(179)).Times(1).Return(nil, errors.New("error"))
+			},
+			query: func() metricprocessor.RangeQuery {
+				q := query
+				q.Agg = metricprocessor.AggAvg
+				return q
+			},
+			err: errors.New("error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMock()
+			metricService := metricprocessor.New(storage, &config.Config{})
+			points, err := metricService.QueryRange(ctx, tt.query())
+
+			if tt.err != nil {
+				require.Error(t, err)
+
+				if errors.Is(tt.err, metricprocessor.ErrInvalidQuery) {
+					assert.ErrorIs(t, err, tt.err)
+				}
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, points)
+		})
+	}
+}
+
+func TestMetricProcessor_QueryRangeRetention(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage := storage.NewMockStorage(ctrl)
+
+	ctx := context.Background()
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	metricService := metricprocessor.New(storage, &config.Config{Retention: "raw:1h,1m:24h"})
+
+	storage.EXPECT().GetRollups(gomock.Any(), constants.MetricTypeGauge, "Alloc", time.Minute, start, start.Add(10*time.Minute)).Times(1).Return([]entities.Rollup{
+		{Timestamp: start, Min: 1, Max: 3, Sum: 4, Count: 2},
+		{Timestamp: start.Add(time.Minute), Min: 5, Max: 5, Sum: 5, Count: 1},
+		{Timestamp: start.Add(5 * time.Minute), Min: 0, Max: 2, Sum: 2, Count: 2},
+	}, nil)
+
+	points, err := metricService.QueryRange(ctx, metricprocessor.RangeQuery{
+		MetricType: constants.MetricTypeGauge,
+		MetricName: "Alloc",
+		Start:      start,
+		End:        start.Add(10 * time.Minute),
+		Step:       5 * time.Minute,
+		Agg:        metricprocessor.AggAvg,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []metricprocessor.RangePoint{
+		{Timestamp: start, Value: 3},
+		{Timestamp: start.Add(5 * time.Minute), Value: 1},
+	}, points)
+}
+
+func TestMetricProcessor_QueryRangeRetentionTail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage := storage.NewMockStorage(ctrl)
+
+	ctx := context.Background()
+	now := time.Now()
+	start := now.Add(-2 * time.Hour).Truncate(time.Hour)
+
+	// the latest minute buckets are not rolled up yet
+	lastRollup := now.Truncate(time.Minute).Add(-2 * time.Minute)
+
+	metricService := metricprocessor.New(storage, &config.Config{Retention: "raw:1h,1m:24h"})
+
+	gomock.InOrder(
+		storage.EXPECT().GetRollups(gomock.Any(), constants.MetricTypeGauge, "Alloc", time.Minute, start, now).Times(1).Return([]entities.Rollup{
+			{Timestamp: start, Min: 1, Max: 3, Sum: 4, Count: 2},
+			{Timestamp: lastRollup, Min: 6, Max: 6, Sum: 6, Count: 1},
+		}, nil),
+		storage.EXPECT().GetMetricHistory(gomock.Any(), constants.MetricTypeGauge, "Alloc", lastRollup.Add(time.Minute), now).Times(1).Return([]entities.MetricSample{
+			{Timestamp: lastRollup.Add(time.Minute), Gauge: 8},
+			{Timestamp: now, Gauge: 10},
+		}, nil),
+	)
+
+	points, err := metricService.QueryRange(ctx, metricprocessor.RangeQuery{
+		MetricType: constants.MetricTypeGauge,
+		MetricName: "Alloc",
+		Start:      start,
+		End:        now,
+		Step:       3 * time.Hour,
+		Agg:        metricprocessor.AggSum,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []metricprocessor.RangePoint{{Timestamp: start, Value: 28}}, points)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgerrcode"
@@ -14,37 +15,138 @@ import (
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage/migrations"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 )
 
 var ErrNotConnection = errors.New("not connection. Maybe not invoked Init() method")
 
+// getUpdateMetricQuery returns upsert of gauge or counter. Gauges are kept in value column,
+// counters in bigint counter column, so they never lose precision
 func getUpdateMetricQuery(metricType string) string {
-	baseQuery := `
-		INSERT INTO metric
-			(type, name, value)
-		VALUES
-			(@type, @name, @value)
-		ON CONFLICT(type, name)
-	`
+	column, update := "value", "EXCLUDED.value"
 
-	switch metricType {
-	case constants.MetricTypeGauge:
-		return baseQuery + " DO UPDATE SET value = EXCLUDED.value RETURNING value;"
-	case constants.MetricTypeCounter:
-		return baseQuery + " DO UPDATE SET value = metric.value + EXCLUDED.value RETURNING value;"
-	default:
-		return baseQuery
+	if metricType == constants.MetricTypeCounter {
+		column, update = "counter", "metric.counter + EXCLUDED.counter"
 	}
+
+	return updateMetricQuery(column, update)
+}
+
+// addGaugeMetricQuery adds value to the stored gauge in the same statement, so concurrent additions are not lost
+var addGaugeMetricQuery = updateMetricQuery("value", "metric.value + EXCLUDED.value")
+
+func updateMetricQuery(column string, update string) string {
+	// every update also appends the resulting value to metric_history
+	return fmt.Sprintf(`
+		WITH updated AS (
+			INSERT INTO metric
+				(type, name, labels, %[1]s)
+			VALUES
+				(@type, @name, @labels, @value)
+			ON CONFLICT(type, name, labels) DO UPDATE SET %[1]s = %[2]s, updated_at = now()
+			RETURNING type, name, labels, %[1]s
+		)
+		INSERT INTO metric_history
+			(type, name, labels, %[1]s)
+		SELECT type, name, labels, %[1]s FROM updated
+		RETURNING %[1]s;
+	`, column, update)
 }
 
-var selectMetricQuery = `SELECT value FROM metric WHERE type = @type AND name = @name`
+var selectMetricQuery = `SELECT value FROM metric WHERE type = @type AND name = @name AND labels = @labels`
+
+var selectCounterQuery = `SELECT counter FROM metric WHERE type = @type AND name = @name AND labels = @labels`
+
+var selectAllMetricsQuery = `SELECT id, type, name, labels, COALESCE(value, 0) AS value, COALESCE(counter, 0) AS counter, histogram FROM metric`
+
+var selectHistogramQuery = `SELECT histogram FROM metric WHERE type = @type AND name = @name AND labels = @labels`
+
+// histograms are merged in application code, so upsert just replaces the stored value;
+// value column keeps count of observations
+var upsertHistogramQuery = `
+	WITH updated AS (
+		INSERT INTO metric
+			(type, name, labels, value, histogram)
+		VALUES
+			(@type, @name, @labels, @value, @histogram)
+		ON CONFLICT(type, name, labels) DO UPDATE SET value = EXCLUDED.value, histogram = EXCLUDED.histogram, updated_at = now()
+		RETURNING type, name, labels, value, histogram
+	)
+	INSERT INTO metric_history
+		(type, name, labels, value, histogram)
+	SELECT type, name, labels, value, histogram FROM updated
+`
+
+var selectMetricHistoryQuery = `
+	SELECT created_at, COALESCE(value, 0) AS value, COALESCE(counter, 0) AS counter, histogram FROM metric_history
+	WHERE type = @type AND name = @name AND labels = @labels AND created_at BETWEEN @from AND @to
+	ORDER BY created_at, id
+`
+
+// series history and rollups are deleted together with the series; returns number of deleted series
+var deleteMetricQuery = `
+	WITH deleted AS (
+		DELETE FROM metric WHERE type = @type AND name = @name AND labels = @labels
+		RETURNING type, name, labels
+	), history AS (
+		DELETE FROM metric_history h USING deleted d
+		WHERE h.type = d.type AND h.name = d.name AND h.labels = d.labels
+	), rollups AS (
+		DELETE FROM metric_rollup r USING deleted d
+		WHERE r.type = d.type AND r.name = d.name AND r.labels = d.labels
+	)
+	SELECT count(*) FROM deleted
+`
+
+var expireMetricsQuery = `
+	WITH deleted AS (
+		DELETE FROM metric WHERE type = @type AND updated_at < @before
+		RETURNING type, name, labels
+	), history AS (
+		DELETE FROM metric_history h USING deleted d
+		WHERE h.type = d.type AND h.name = d.name AND h.labels = d.labels
+	), rollups AS (
+		DELETE FROM metric_rollup r USING deleted d
+		WHERE r.type = d.type AND r.name = d.name AND r.labels = d.labels
+	)
+	SELECT name, labels FROM deleted
+`
+
+var selectRollupsQuery = `
+	SELECT ts, min, max, sum, count FROM metric_rollup
+	WHERE type = @type AND name = @name AND labels = @labels AND resolution = @resolution AND ts BETWEEN @from AND @to
+	ORDER BY ts
+`
+
+var upsertRollupQuery = `
+	INSERT INTO metric_rollup
+		(type, name, labels, resolution, ts, min, max, sum, count)
+	VALUES
+		(@type, @name, @labels, @resolution, @ts, @min, @max, @sum, @count)
+	ON CONFLICT(type, name, labels, resolution, ts) DO UPDATE
+	SET min = EXCLUDED.min, max = EXCLUDED.max, sum = EXCLUDED.sum, count = EXCLUDED.count
+`
+
+var deleteHistoryQuery = `DELETE FROM metric_history WHERE created_at < @before`
+
+var deleteRollupsQuery = `DELETE FROM metric_rollup WHERE resolution = @resolution AND ts < @before`
 
 type rawMetric struct {
-	ID    int
-	MType string `db:"type"`
-	Name  string
-	Value float64
+	ID        int
+	MType     string `db:"type"`
+	Name      string
+	Labels    map[string]string
+	Value     float64
+	Counter   int64
+	Histogram *entities.Histogram
+}
+
+type rawSample struct {
+	CreatedAt time.Time
+	Value     float64
+	Counter   int64
+	Histogram *entities.Histogram
 }
 
 type PostgresStorage struct {
@@ -60,7 +162,7 @@ func (s *PostgresStorage) SaveGaugeMetric(ctx context.Context, metricType string
 
 	var result float64
 
-	err := s.pool.QueryRow(ctx, getUpdateMetricQuery(constants.MetricTypeGauge), pgx.NamedArgs{"type": constants.MetricTypeGauge, "value": value, "name": metricType}).Scan(&result)
+	err := s.pool.QueryRow(ctx, getUpdateMetricQuery(constants.MetricTypeGauge), seriesArgs(metricType, pgx.NamedArgs{"type": constants.MetricTypeGauge, "value": value})).Scan(&result)
 
 	if err != nil {
 		return 0, fmt.Errorf("error while save gauge metric; metricName: %s, metricValue: %f, err: %w", metricType, value, err)
@@ -69,10 +171,34 @@ func (s *PostgresStorage) SaveGaugeMetric(ctx context.Context, metricType string
 	return result, err
 }
 
+func (s *PostgresStorage) AddGaugeMetric(ctx context.Context, metricType string, delta float64) (float64, error) {
+	if s.pool == nil {
+		return 0, ErrNotConnection
+	}
+
+	var result float64
+
+	err := s.pool.QueryRow(ctx, addGaugeMetricQuery, seriesArgs(metricType, pgx.NamedArgs{"type": constants.MetricTypeGauge, "value": delta})).Scan(&result)
+
+	if err != nil {
+		return 0, fmt.Errorf("error while add gauge metric; metricName: %s, delta: %f, err: %w", metricType, delta, err)
+	}
+
+	return result, err
+}
+
 func (s *PostgresStorage) SaveCounterMetric(ctx context.Context, metricType string, value int64) (int64, error) {
+	if s.pool == nil {
+		return 0, ErrNotConnection
+	}
+
 	var result int64
 
-	err := s.pool.QueryRow(ctx, getUpdateMetricQuery(constants.MetricTypeCounter), pgx.NamedArgs{"type": constants.MetricTypeCounter, "value": value, "name": metricType}).Scan(&result)
+	err := s.pool.QueryRow(ctx, getUpdateMetricQuery(constants.MetricTypeCounter), seriesArgs(metricType, pgx.NamedArgs{"type": constants.MetricTypeCounter, "value": value})).Scan(&result)
+
+	if isOutOfRangeError(err) {
+		err = entities.ErrCounterOverflow
+	}
 
 	if err != nil {
 		return 0, fmt.Errorf("error while save counter metric; metricName: %s, metricValue: %d, err: %w", metricType, value, err)
@@ -81,28 +207,189 @@ func (s *PostgresStorage) SaveCounterMetric(ctx context.Context, metricType stri
 	return result, err
 }
 
-func (s *PostgresStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+func (s *PostgresStorage) SaveHistogramMetric(ctx context.Context, metricType string, value entities.Histogram) (entities.Histogram, error) {
 	if s.pool == nil {
-		return ErrNotConnection
+		return entities.Histogram{}, ErrNotConnection
 	}
 
+	var result entities.Histogram
+
+	err := pgx.BeginTxFunc(ctx, s.pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var err error
+
+		result, err = saveHistogram(ctx, tx, metricType, value)
+
+		return err
+	})
+
+	if err != nil {
+		return entities.Histogram{}, fmt.Errorf("error while save histogram metric; metricName: %s, err: %w", metricType, err)
+	}
+
+	return result, nil
+}
+
+// SaveMetricBatch saves all metrics in one transaction, so rejected item rolls back the whole batch
+func (s *PostgresStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) ([]entities.Metrics, error) {
+	if s.pool == nil {
+		return nil, ErrNotConnection
+	}
+
+	if err := validateBatch(metrics); err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.Metrics, len(metrics))
+
+	err := pgx.BeginTxFunc(ctx, s.pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		if err := saveScalarBatch(ctx, tx, metrics, result); err != nil {
+			return err
+		}
+
+		// histograms are different series from gauges and counters, so saving them last keeps order of updates
+		for i, metric := range metrics {
+			if metric.MType != constants.MetricTypeHistogram {
+				continue
+			}
+
+			val, err := saveHistogram(ctx, tx, metric.SeriesID(), *metric.Histogram)
+
+			if errors.Is(err, entities.ErrHistogramBoundsMismatch) {
+				return NewErrBatchItem(i, err)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			result[i] = batchResult(metric, entities.MetricSample{Histogram: &val})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while save metrics batch; err: %w", err)
+	}
+
+	return result, nil
+}
+
+// saveScalarBatch sends gauges and counters of the batch in one round trip and fills their results
+func saveScalarBatch(ctx context.Context, tx pgx.Tx, metrics []entities.Metrics, result []entities.Metrics) error {
 	batch := &pgx.Batch{}
 
-	for _, metric := range metrics {
-		var value float64
+	var queued []int
+
+	for i, metric := range metrics {
+		if metric.MType == constants.MetricTypeHistogram {
+			continue
+		}
+
+		var value any
 
 		if metric.MType == constants.MetricTypeGauge {
 			value = *metric.Value
 		} else {
-			value = float64(*metric.Delta)
+			value = *metric.Delta
 		}
 
-		batch.Queue(getUpdateMetricQuery(metric.MType), pgx.NamedArgs{"type": metric.MType, "name": metric.ID, "value": value})
+		batch.Queue(getUpdateMetricQuery(metric.MType), seriesArgs(metric.SeriesID(), pgx.NamedArgs{"type": metric.MType, "value": value}))
+		queued = append(queued, i)
+	}
+
+	if len(queued) == 0 {
+		return nil
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for _, i := range queued {
+		var sample entities.MetricSample
+		var err error
+
+		if metrics[i].MType == constants.MetricTypeGauge {
+			err = br.QueryRow().Scan(&sample.Gauge)
+		} else {
+			err = br.QueryRow().Scan(&sample.Counter)
+		}
+
+		if isOutOfRangeError(err) {
+			return NewErrBatchItem(i, entities.ErrCounterOverflow)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		result[i] = batchResult(metrics[i], sample)
+	}
+
+	return br.Close()
+}
+
+func (s *PostgresStorage) DeleteMetric(ctx context.Context, metricType string, metricName string) error {
+	if s.pool == nil {
+		return ErrNotConnection
 	}
 
-	err := s.pool.SendBatch(ctx, batch).Close()
+	var deleted int
+
+	err := s.pool.QueryRow(ctx, deleteMetricQuery, seriesArgs(metricName, pgx.NamedArgs{"type": metricType})).Scan(&deleted)
 
-	return err
+	if err != nil {
+		return fmt.Errorf("error while delete metric; metricName: %s, err: %w", metricName, err)
+	}
+
+	if deleted == 0 {
+		return NewErrNotFound(pgx.ErrNoRows, map[string]interface{}{"metricType": metricType, "metricName": metricName})
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) DeleteMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+	if s.pool == nil {
+		return ErrNotConnection
+	}
+
+	batch := &pgx.Batch{}
+
+	for _, metric := range metrics {
+		batch.Queue(deleteMetricQuery, seriesArgs(metric.SeriesID(), pgx.NamedArgs{"type": metric.MType}))
+	}
+
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("error while delete metrics batch; err: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) ExpireMetrics(ctx context.Context, metricType string, updatedBefore time.Time) ([]string, error) {
+	if s.pool == nil {
+		return nil, ErrNotConnection
+	}
+
+	var rawResult []struct {
+		Name   string
+		Labels map[string]string
+	}
+
+	err := pgxscan.Select(ctx, s.pool, &rawResult, expireMetricsQuery, pgx.NamedArgs{"type": metricType, "before": updatedBefore})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while expire metrics; metricType: %s, err: %w", metricType, err)
+	}
+
+	expired := make([]string, 0, len(rawResult))
+
+	for _, raw := range rawResult {
+		expired = append(expired, entities.SeriesID(raw.Name, raw.Labels))
+	}
+
+	return expired, nil
 }
 
 func (s *PostgresStorage) GetGaugeMetric(ctx context.Context, metricName string) (float64, error) {
@@ -112,7 +399,7 @@ func (s *PostgresStorage) GetGaugeMetric(ctx context.Context, metricName string)
 		return 0, ErrNotConnection
 	}
 
-	err := s.pool.QueryRow(ctx, selectMetricQuery, pgx.NamedArgs{"type": constants.MetricTypeGauge, "name": metricName}).Scan(&result)
+	err := s.pool.QueryRow(ctx, selectMetricQuery, seriesArgs(metricName, pgx.NamedArgs{"type": constants.MetricTypeGauge})).Scan(&result)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return result, NewErrNotFound(err, map[string]interface{}{"metricName": metricName})
@@ -128,7 +415,7 @@ func (s *PostgresStorage) GetCounterMetric(ctx context.Context, metricName strin
 		return 0, ErrNotConnection
 	}
 
-	err := s.pool.QueryRow(ctx, selectMetricQuery, pgx.NamedArgs{"type": constants.MetricTypeCounter, "name": metricName}).Scan(&result)
+	err := s.pool.QueryRow(ctx, selectCounterQuery, seriesArgs(metricName, pgx.NamedArgs{"type": constants.MetricTypeCounter})).Scan(&result)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return result, NewErrNotFound(err, map[string]interface{}{"metricName": metricName})
@@ -141,6 +428,26 @@ func (s *PostgresStorage) GetCounterMetric(ctx context.Context, metricName strin
 	return result, err
 }
 
+func (s *PostgresStorage) GetHistogramMetric(ctx context.Context, metricName string) (entities.Histogram, error) {
+	var result entities.Histogram
+
+	if s.pool == nil {
+		return result, ErrNotConnection
+	}
+
+	err := s.pool.QueryRow(ctx, selectHistogramQuery, seriesArgs(metricName, pgx.NamedArgs{"type": constants.MetricTypeHistogram})).Scan(&result)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return result, NewErrNotFound(err, map[string]interface{}{"metricName": metricName})
+	}
+
+	if err != nil {
+		return result, fmt.Errorf("error while get histogram metric; metricName: %s, err: %w", metricName, err)
+	}
+
+	return result, nil
+}
+
 func (s *PostgresStorage) GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error) {
 	if s.pool == nil {
 		return entities.TotalMetrics{}, ErrNotConnection
@@ -148,25 +455,139 @@ func (s *PostgresStorage) GetAllMetrics(ctx context.Context) (entities.TotalMetr
 
 	var rawResult []rawMetric
 
-	err := pgxscan.Select(ctx, s.pool, &rawResult, "SELECT * FROM metric")
+	err := pgxscan.Select(ctx, s.pool, &rawResult, selectAllMetricsQuery)
 
 	if err != nil {
 		return entities.TotalMetrics{}, fmt.Errorf("error while get metrics; err: %w", err)
 	}
 
-	result := entities.TotalMetrics{Gauge: make(map[string]float64), Counter: make(map[string]int64)}
+	result := entities.TotalMetrics{Gauge: make(map[string]float64), Counter: make(map[string]int64), Histogram: make(map[string]entities.Histogram)}
 
 	for _, rawMetric := range rawResult {
-		if rawMetric.MType == constants.MetricTypeGauge {
-			result.Gauge[rawMetric.Name] = rawMetric.Value
-		} else {
-			result.Counter[rawMetric.Name] = int64(rawMetric.Value)
+		seriesID := entities.SeriesID(rawMetric.Name, rawMetric.Labels)
+
+		switch rawMetric.MType {
+		case constants.MetricTypeGauge:
+			result.Gauge[seriesID] = rawMetric.Value
+		case constants.MetricTypeHistogram:
+			if rawMetric.Histogram != nil {
+				result.Histogram[seriesID] = *rawMetric.Histogram
+			}
+		default:
+			result.Counter[seriesID] = rawMetric.Counter
+		}
+	}
+
+	return result, nil
+}
+
+func (s *PostgresStorage) GetMetricHistory(ctx context.Context, metricType string, metricName string, from time.Time, to time.Time) ([]entities.MetricSample, error) {
+	if s.pool == nil {
+		return nil, ErrNotConnection
+	}
+
+	var rawResult []rawSample
+
+	err := pgxscan.Select(ctx, s.pool, &rawResult, selectMetricHistoryQuery, seriesArgs(metricName, pgx.NamedArgs{"type": metricType, "from": from, "to": to}))
+
+	if err != nil {
+		return nil, fmt.Errorf("error while get metric history; metricName: %s, err: %w", metricName, err)
+	}
+
+	result := make([]entities.MetricSample, 0, len(rawResult))
+
+	for _, rawSample := range rawResult {
+		sample := entities.MetricSample{Timestamp: rawSample.CreatedAt}
+
+		switch metricType {
+		case constants.MetricTypeGauge:
+			sample.Gauge = rawSample.Value
+		case constants.MetricTypeHistogram:
+			sample.Histogram = rawSample.Histogram
+		default:
+			sample.Counter = rawSample.Counter
 		}
+
+		result = append(result, sample)
+	}
+
+	return result, nil
+}
+
+func (s *PostgresStorage) GetRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, from time.Time, to time.Time) ([]entities.Rollup, error) {
+	if s.pool == nil {
+		return nil, ErrNotConnection
+	}
+
+	var rawResult []struct {
+		Ts    time.Time
+		Min   float64
+		Max   float64
+		Sum   float64
+		Count int64
+	}
+
+	args := seriesArgs(metricName, pgx.NamedArgs{"type": metricType, "resolution": int64(resolution.Seconds()), "from": from, "to": to})
+
+	err := pgxscan.Select(ctx, s.pool, &rawResult, selectRollupsQuery, args)
+
+	if err != nil {
+		return nil, fmt.Errorf("error while get metric rollups; metricName: %s, err: %w", metricName, err)
+	}
+
+	result := make([]entities.Rollup, 0, len(rawResult))
+
+	for _, raw := range rawResult {
+		result = append(result, entities.Rollup{Timestamp: raw.Ts, Min: raw.Min, Max: raw.Max, Sum: raw.Sum, Count: raw.Count})
 	}
 
 	return result, nil
 }
 
+func (s *PostgresStorage) SaveRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, rollups []entities.Rollup) error {
+	if s.pool == nil {
+		return ErrNotConnection
+	}
+
+	batch := &pgx.Batch{}
+
+	for _, rollup := range rollups {
+		batch.Queue(upsertRollupQuery, seriesArgs(metricName, pgx.NamedArgs{
+			"type":       metricType,
+			"resolution": int64(resolution.Seconds()),
+			"ts":         rollup.Timestamp,
+			"min":        rollup.Min,
+			"max":        rollup.Max,
+			"sum":        rollup.Sum,
+			"count":      rollup.Count,
+		}))
+	}
+
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("error while save metric rollups; metricName: %s, err: %w", metricName, err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) DeleteHistory(ctx context.Context, resolution time.Duration, before time.Time) error {
+	if s.pool == nil {
+		return ErrNotConnection
+	}
+
+	query, args := deleteHistoryQuery, pgx.NamedArgs{"before": before}
+
+	if resolution != 0 {
+		query, args["resolution"] = deleteRollupsQuery, int64(resolution.Seconds())
+	}
+
+	if _, err := s.pool.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("error while delete history; resolution: %s, err: %w", resolution, err)
+	}
+
+	return nil
+}
+
 func (s *PostgresStorage) Init(ctx context.Context, backoff retry.Backoff) error {
 	pool, err := pgxpool.New(ctx, s.cfg.DatabaseDSN)
 
@@ -191,26 +612,17 @@ func (s *PostgresStorage) Init(ctx context.Context, backoff retry.Backoff) error
 
 	s.pool = pool
 
-	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	migrator, err := migrations.New(pool, s.logger)
 
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback(ctx)
+	err = migrator.Up(ctx)
 
-	tx.Exec(ctx, `
-		CREATE TABLE metric (
-			id serial PRIMARY KEY,
-			type varchar(128) NOT NULL,
-			name varchar(128) NOT NULL,
-			value double precision NOT NULL
-		)
-	`)
-
-	tx.Exec(ctx, "CREATE UNIQUE INDEX idx_type_name ON metric(type, name)")
-
-	tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
 
 	s.logger.Infow("success connect to database")
 
@@ -239,6 +651,60 @@ func NewPostgresStorage(cfg *config.Config, logger logger.ILogger) *PostgresStor
 	return &PostgresStorage{cfg, logger, nil}
 }
 
+// saveHistogram merges value into the stored histogram under row lock and saves the result
+func saveHistogram(ctx context.Context, tx pgx.Tx, seriesID string, value entities.Histogram) (entities.Histogram, error) {
+	args := seriesArgs(seriesID, pgx.NamedArgs{"type": constants.MetricTypeHistogram})
+
+	var current *entities.Histogram
+
+	err := tx.QueryRow(ctx, selectHistogramQuery+" FOR UPDATE", args).Scan(&current)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return entities.Histogram{}, err
+	}
+
+	result := value
+
+	if current != nil {
+		result, err = current.Merge(value)
+
+		if err != nil {
+			return entities.Histogram{}, err
+		}
+	}
+
+	args["value"] = float64(result.Count)
+	args["histogram"] = result
+
+	_, err = tx.Exec(ctx, upsertHistogramQuery, args)
+
+	return result, err
+}
+
+// seriesArgs adds name and labels of the series to query args. Labels are never nil,
+// so series without labels match the '{}' default of the labels column
+func seriesArgs(seriesID string, args pgx.NamedArgs) pgx.NamedArgs {
+	name, labels := entities.ParseSeriesID(seriesID)
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	args["name"] = name
+	args["labels"] = labels
+
+	return args
+}
+
+// isOutOfRangeError reports whether postgres rejected value which doesn't fit column type, e.g. bigint overflow
+func isOutOfRangeError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgerrcode.NumericValueOutOfRange
+	}
+	return false
+}
+
 func isRetriableError(err error) bool {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
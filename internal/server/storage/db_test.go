@@ -0,0 +1,162 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/sodiqit/metricpulse.git/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPostgresStorage connects to database from TEST_DATABASE_DSN, tests are skipped without it
+func newTestPostgresStorage(t *testing.T) *storage.PostgresStorage {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not provided")
+	}
+
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	store := storage.NewPostgresStorage(&config.Config{DatabaseDSN: dsn}, logger)
+
+	err = store.Init(context.Background(), retry.EmptyBackoff)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		store.Close(context.Background())
+	})
+
+	return store
+}
+
+func TestPostgresStorage_CounterRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestPostgresStorage(t)
+
+	tests := []struct {
+		name  string
+		tBody func(metricName string)
+	}{
+		{
+			name: "should keep exact counter values near max int64",
+			tBody: func(metricName string) {
+				start := time.Now()
+
+				_, err := store.SaveCounterMetric(ctx, metricName, math.MaxInt64-1)
+				require.NoError(t, err)
+
+				val, err := store.SaveCounterMetric(ctx, metricName, 1)
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64), val)
+
+				val, err = store.GetCounterMetric(ctx, metricName)
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64), val)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64), metrics.Counter[metricName])
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, metricName, start, time.Now())
+				require.NoError(t, err)
+				_, counters := samplesValues(history)
+				assert.Equal(t, []int64{math.MaxInt64 - 1, math.MaxInt64}, counters)
+			},
+		},
+		{
+			name: "should detect counter overflow",
+			tBody: func(metricName string) {
+				_, err := store.SaveCounterMetric(ctx, metricName, math.MaxInt64)
+				require.NoError(t, err)
+
+				_, err = store.SaveCounterMetric(ctx, metricName, 1)
+				assert.ErrorIs(t, err, entities.ErrCounterOverflow)
+
+				val, err := store.GetCounterMetric(ctx, metricName)
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64), val)
+			},
+		},
+		{
+			name: "should reject whole batch on counter overflow",
+			tBody: func(metricName string) {
+				maxDelta, one, gauge := int64(math.MaxInt64), int64(1), 1.5
+
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: metricName, MType: constants.MetricTypeCounter, Delta: &maxDelta},
+				})
+				require.NoError(t, err)
+
+				_, err = store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: metricName, MType: constants.MetricTypeGauge, Value: &gauge},
+					{ID: metricName, MType: constants.MetricTypeCounter, Delta: &one},
+				})
+				assert.ErrorIs(t, err, entities.ErrCounterOverflow)
+
+				var itemErr *storage.ErrBatchItem
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 1, itemErr.Index)
+
+				_, err = store.GetGaugeMetric(ctx, metricName)
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody(fmt.Sprintf("test_counter_%d_%d", time.Now().UnixNano(), i))
+		})
+	}
+}
+
+func TestPostgresStorage_DeleteMetric(t *testing.T) {
+	ctx := context.Background()
+	store := newTestPostgresStorage(t)
+
+	suffix := time.Now().UnixNano()
+	stale, fresh, deleted := fmt.Sprintf("test_stale_%d", suffix), fmt.Sprintf("test_fresh_%d", suffix), fmt.Sprintf("test_deleted_%d", suffix)
+
+	_, err := store.SaveGaugeMetric(ctx, stale, 1)
+	require.NoError(t, err)
+	_, err = store.SaveGaugeMetric(ctx, deleted, 1)
+	require.NoError(t, err)
+
+	err = store.DeleteMetric(ctx, constants.MetricTypeGauge, deleted)
+	require.NoError(t, err)
+
+	err = store.DeleteMetric(ctx, constants.MetricTypeGauge, deleted)
+	assert.True(t, storage.IsErrNotFound(err))
+
+	time.Sleep(10 * time.Millisecond)
+	updatedBefore := time.Now()
+
+	_, err = store.SaveGaugeMetric(ctx, fresh, 1)
+	require.NoError(t, err)
+
+	expired, err := store.ExpireMetrics(ctx, constants.MetricTypeGauge, updatedBefore)
+	require.NoError(t, err)
+	assert.Contains(t, expired, stale)
+	assert.NotContains(t, expired, fresh)
+
+	history, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, stale, time.Time{}, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	err = store.DeleteMetricBatch(ctx, []entities.Metrics{{ID: fresh, MType: constants.MetricTypeGauge}})
+	require.NoError(t, err)
+
+	_, err = store.GetGaugeMetric(ctx, fresh)
+	assert.True(t, storage.IsErrNotFound(err))
+}
@@ -1,48 +1,106 @@
 package storage
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/sodiqit/metricpulse.git/internal/constants"
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 )
 
+const (
+	historyFileSuffix = ".history"
+	walFileSuffix     = ".wal"
+	// walCompactSize is wal size after which snapshot is written in sync mode
+	walCompactSize = 4 << 20
+)
+
+// snapshot is content of the storage file. Updated keeps time of the last update of every series,
+// so expiry of restored series continues instead of starting over
+type snapshot struct {
+	entities.TotalMetrics
+	Updated map[string]map[string]time.Time `json:"updated,omitempty"`
+}
+
+// FileStorage keeps metrics in memory and persists them as snapshot plus write-ahead log.
+// Every update is appended to the wal, snapshot is rewritten every StoreInterval seconds
+// (or when wal grows too big in sync mode) and wal is truncated after it
 type FileStorage struct {
-	cfg     *config.Config
-	storage *MemStorage
-	file    *os.File
-	logger  logger.ILogger
+	cfg         *config.Config
+	storage     *MemStorage
+	wal         *os.File
+	walSize     int64
+	historyFile *os.File
+	// historyLines is number of records in the history file, staleLines is number of them
+	// which were expired or replaced; file is rewritten when stale records make up half of it
+	historyLines int
+	staleLines   int
+	logger       logger.ILogger
+	stopStore    context.CancelFunc
+	storeDone    chan struct{}
+	// writeM serializes updates with writes to files, so wal and history get samples in the order they were saved
+	writeM sync.Mutex
 }
 
 func (s *FileStorage) SaveGaugeMetric(ctx context.Context, metricType string, value float64) (float64, error) {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
 	res, err := s.storage.SaveGaugeMetric(ctx, metricType, value)
 
-	if s.cfg.StoreInterval != 0 || err != nil {
+	if err != nil {
 		return res, err
 	}
 
-	err = s.save(ctx)
+	return res, s.appendUpdates(ctx, []seriesKey{{constants.MetricTypeGauge, metricType}}, nil)
+}
+
+func (s *FileStorage) AddGaugeMetric(ctx context.Context, metricType string, delta float64) (float64, error) {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	res, err := s.storage.AddGaugeMetric(ctx, metricType, delta)
 
-	return res, err
+	if err != nil {
+		return res, err
+	}
+
+	return res, s.appendUpdates(ctx, []seriesKey{{constants.MetricTypeGauge, metricType}}, nil)
 }
 
 func (s *FileStorage) SaveCounterMetric(ctx context.Context, metricType string, value int64) (int64, error) {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
 	res, err := s.storage.SaveCounterMetric(ctx, metricType, value)
 
-	if s.cfg.StoreInterval != 0 || err != nil {
+	if err != nil {
 		return res, err
 	}
 
-	err = s.save(ctx)
+	return res, s.appendUpdates(ctx, []seriesKey{{constants.MetricTypeCounter, metricType}}, nil)
+}
+
+func (s *FileStorage) SaveHistogramMetric(ctx context.Context, metricType string, value entities.Histogram) (entities.Histogram, error) {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
 
-	return res, err
+	res, err := s.storage.SaveHistogramMetric(ctx, metricType, value)
+
+	if err != nil {
+		return res, err
+	}
+
+	return res, s.appendUpdates(ctx, []seriesKey{{constants.MetricTypeHistogram, metricType}}, nil)
 }
 
 func (s *FileStorage) GetGaugeMetric(ctx context.Context, metricName string) (float64, error) {
@@ -53,20 +111,179 @@ func (s *FileStorage) GetCounterMetric(ctx context.Context, metricName string) (
 	return s.storage.GetCounterMetric(ctx, metricName)
 }
 
+func (s *FileStorage) GetHistogramMetric(ctx context.Context, metricName string) (entities.Histogram, error) {
+	return s.storage.GetHistogramMetric(ctx, metricName)
+}
+
 func (s *FileStorage) GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error) {
 	return s.storage.GetAllMetrics(ctx)
 }
 
-func (s *FileStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
-	err := s.storage.SaveMetricBatch(ctx, metrics)
+func (s *FileStorage) GetMetricHistory(ctx context.Context, metricType string, metricName string, from time.Time, to time.Time) ([]entities.MetricSample, error) {
+	return s.storage.GetMetricHistory(ctx, metricType, metricName, from, to)
+}
+
+func (s *FileStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) ([]entities.Metrics, error) {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	result, err := s.storage.SaveMetricBatch(ctx, metrics)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []seriesKey
+	updates := make(map[seriesKey]int)
+
+	for _, metric := range metrics {
+		key := seriesKey{metric.MType, metric.SeriesID()}
+
+		if updates[key] == 0 {
+			keys = append(keys, key)
+		}
+
+		updates[key]++
+	}
+
+	if err := s.appendUpdates(ctx, keys, updates); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *FileStorage) DeleteMetric(ctx context.Context, metricType string, metricName string) error {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	if err := s.storage.DeleteMetric(ctx, metricType, metricName); err != nil {
+		return err
+	}
+
+	return s.appendDeletes(ctx, []seriesKey{{metricType, metricName}})
+}
+
+func (s *FileStorage) DeleteMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	if err := s.storage.DeleteMetricBatch(ctx, metrics); err != nil {
+		return err
+	}
+
+	keys := make([]seriesKey, 0, len(metrics))
+
+	for _, metric := range metrics {
+		keys = append(keys, seriesKey{metric.MType, metric.SeriesID()})
+	}
+
+	return s.appendDeletes(ctx, keys)
+}
+
+func (s *FileStorage) ExpireMetrics(ctx context.Context, metricType string, updatedBefore time.Time) ([]string, error) {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	expired, err := s.storage.ExpireMetrics(ctx, metricType, updatedBefore)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]seriesKey, 0, len(expired))
+
+	for _, name := range expired {
+		keys = append(keys, seriesKey{metricType, name})
+	}
+
+	return expired, s.appendDeletes(ctx, keys)
+}
+
+func (s *FileStorage) GetRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, from time.Time, to time.Time) ([]entities.Rollup, error) {
+	return s.storage.GetRollups(ctx, metricType, metricName, resolution, from, to)
+}
+
+// SaveRollups appends rollups to the history file only: wal keeps current values, which rollups don't change
+func (s *FileStorage) SaveRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, rollups []entities.Rollup) error {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	key := seriesKey{metricType, metricName}
+
+	s.staleLines += s.storage.saveRollups(key, resolution, rollups)
+
+	records := make([]historyRecord, 0, len(rollups))
+
+	for _, rollup := range rollups {
+		rollup := rollup
+		records = append(records, historyRecord{seriesKey: key, MetricSample: entities.MetricSample{Timestamp: rollup.Timestamp}, Resolution: resolution, Rollup: &rollup})
+	}
+
+	buf, err := marshalRecords(records)
 
-	if s.cfg.StoreInterval != 0 || err != nil {
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.historyFile.Write(buf); err != nil {
 		return err
 	}
 
-	err = s.save(ctx)
+	s.historyLines += len(records)
+
+	return s.rewriteHistoryIfStale()
+}
+
+func (s *FileStorage) DeleteHistory(ctx context.Context, resolution time.Duration, before time.Time) error {
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
+
+	s.staleLines += s.storage.deleteHistory(resolution, before)
 
-	return err
+	return s.rewriteHistoryIfStale()
+}
+
+// rewriteHistoryIfStale replaces the history file with records kept in memory when half of the file is stale,
+// so the file doesn't grow with expired history
+func (s *FileStorage) rewriteHistoryIfStale() error {
+	if s.historyFile == nil || s.staleLines == 0 || s.staleLines*2 < s.historyLines {
+		return nil
+	}
+
+	return s.rewriteHistory()
+}
+
+// rewriteHistory replaces the history file with records kept in memory
+func (s *FileStorage) rewriteHistory() error {
+	records := s.storage.historyRecords()
+
+	buf, err := marshalRecords(records)
+
+	if err != nil {
+		return err
+	}
+
+	path := s.cfg.FileStoragePath + historyFileSuffix
+
+	if err := writeFileAtomic(path, buf); err != nil {
+		return err
+	}
+
+	historyFile, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+
+	if err != nil {
+		return err
+	}
+
+	s.historyFile.Close()
+	s.historyFile = historyFile
+
+	s.logger.Infow("success rewrite history", "records", len(records), "removed", s.historyLines-len(records), "filePath", path)
+
+	s.historyLines, s.staleLines = len(records), 0
+
+	return nil
 }
 
 func (s *FileStorage) Init(ctx context.Context, backoff retry.Backoff) error {
@@ -74,8 +291,8 @@ func (s *FileStorage) Init(ctx context.Context, backoff retry.Backoff) error {
 		return errors.New("file not provided for start file storage")
 	}
 
-	file, err := retry.DoWithData(ctx, backoff, func(ctx context.Context) (*os.File, error) {
-		f, err := os.OpenFile(s.cfg.FileStoragePath, os.O_RDWR|os.O_CREATE, 0666)
+	wal, err := retry.DoWithData(ctx, backoff, func(ctx context.Context) (*os.File, error) {
+		f, err := os.OpenFile(s.cfg.FileStoragePath+walFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 
 		if err != nil {
 			return f, retry.RetryableError(err)
@@ -88,17 +305,32 @@ func (s *FileStorage) Init(ctx context.Context, backoff retry.Backoff) error {
 		return err
 	}
 
-	s.file = file
+	s.wal = wal
 
-	err = s.storeInterval(ctx)
+	historyFile, err := os.OpenFile(s.cfg.FileStoragePath+historyFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 
 	if err != nil {
 		return err
 	}
 
+	s.historyFile = historyFile
+
 	err = s.load()
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	// replayed wal is folded into a fresh snapshot, so the next start replays only new updates
+	err = s.compact(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	s.storeInterval(ctx)
+
+	return nil
 }
 
 func (s *FileStorage) Ping(ctx context.Context) error {
@@ -106,23 +338,58 @@ func (s *FileStorage) Ping(ctx context.Context) error {
 }
 
 func (s *FileStorage) Close(ctx context.Context) error {
-	if s.file == nil {
+	if s.wal == nil {
 		return nil
 	}
 
-	defer s.file.Close()
+	defer s.wal.Close()
+
+	if s.historyFile != nil {
+		defer s.historyFile.Close()
+	}
+
+	// stop background compaction before the final one, so they never write the files concurrently
+	if s.stopStore != nil {
+		s.stopStore()
+		<-s.storeDone
+	}
 
-	err := s.save(ctx)
+	s.writeM.Lock()
+	defer s.writeM.Unlock()
 
-	return err
+	return s.compact(ctx)
 }
 
 func (s *FileStorage) load() error {
-	if s.file == nil || !s.cfg.Restore {
-		return nil
+	if !s.cfg.Restore {
+		if err := s.wal.Truncate(0); err != nil {
+			return err
+		}
+
+		return s.historyFile.Truncate(0)
+	}
+
+	err := s.loadHistory()
+
+	if err != nil {
+		return err
 	}
 
-	data, err := io.ReadAll(s.file)
+	err = s.loadSnapshot()
+
+	if err != nil {
+		return err
+	}
+
+	return s.replayWAL()
+}
+
+func (s *FileStorage) loadSnapshot() error {
+	data, err := os.ReadFile(s.cfg.FileStoragePath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 
 	if err != nil {
 		return err
@@ -132,27 +399,188 @@ func (s *FileStorage) load() error {
 		return nil
 	}
 
-	var metrics entities.TotalMetrics
+	var snap snapshot
 
-	jsonErr := json.Unmarshal(data, &metrics)
+	jsonErr := json.Unmarshal(data, &snap)
 
 	if jsonErr != nil {
 		return jsonErr
 	}
 
-	initMetricsErr := s.storage.InitMetrics(metrics)
+	initMetricsErr := s.storage.InitMetrics(snap.TotalMetrics)
 
 	if initMetricsErr != nil {
 		return initMetricsErr
 	}
 
-	s.logger.Infow("success load metrics", "metrics", metrics, "filePath", s.cfg.FileStoragePath)
+	s.storage.restoreUpdated(snap.Updated)
+
+	s.logger.Infow("success load metrics", "metrics", snap.TotalMetrics, "filePath", s.cfg.FileStoragePath)
+
+	return nil
+}
+
+func (s *FileStorage) loadHistory() error {
+	var records []historyRecord
+
+	torn := false
+
+	scanner := bufio.NewScanner(s.historyFile)
+	scanner.Buffer(nil, walCompactSize)
+
+	for scanner.Scan() {
+		var record historyRecord
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// only the last record may be torn by a crash in the middle of append
+			if scanner.Scan() {
+				return err
+			}
+
+			s.logger.Warnw("skip corrupted history tail", "error", err, "loaded", len(records))
+			torn = true
+
+			break
+		}
+
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	s.storage.initHistory(records)
+	s.historyLines = len(records)
+
+	// new records must not be appended to the torn line
+	if torn {
+		return s.rewriteHistory()
+	}
 
 	return nil
 }
 
-func (s *FileStorage) save(ctx context.Context) error {
-	if s.file == nil {
+// replayWAL applies wal records on top of loaded snapshot. Records hold series values after update,
+// so replaying records which are already in the snapshot doesn't change anything
+func (s *FileStorage) replayWAL() error {
+	scanner := bufio.NewScanner(s.wal)
+	scanner.Buffer(nil, walCompactSize)
+
+	replayed := 0
+
+	for scanner.Scan() {
+		var record historyRecord
+
+		// only the last record may be torn by a crash in the middle of append
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			s.logger.Warnw("skip corrupted wal tail", "error", err, "replayed", replayed)
+			break
+		}
+
+		s.storage.restoreRecord(record)
+		replayed++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	s.logger.Infow("success replay wal", "records", replayed, "filePath", s.cfg.FileStoragePath+walFileSuffix)
+
+	return nil
+}
+
+// appendUpdates writes latest samples of the series as json lines to the wal and the history file.
+// updates holds number of samples for every key, missing key means one sample
+func (s *FileStorage) appendUpdates(ctx context.Context, keys []seriesKey, updates map[seriesKey]int) error {
+	var records []historyRecord
+
+	for _, key := range keys {
+		n, ok := updates[key]
+
+		if !ok {
+			n = 1
+		}
+
+		for _, sample := range s.storage.lastSamples(key, n) {
+			records = append(records, historyRecord{seriesKey: key, MetricSample: sample})
+		}
+	}
+
+	return s.appendRecords(ctx, records)
+}
+
+// appendDeletes writes deletion records of the series to the wal and the history file
+func (s *FileStorage) appendDeletes(ctx context.Context, keys []seriesKey) error {
+	records := make([]historyRecord, 0, len(keys))
+	now := time.Now()
+
+	for _, key := range keys {
+		records = append(records, historyRecord{seriesKey: key, MetricSample: entities.MetricSample{Timestamp: now}, Deleted: true})
+	}
+
+	return s.appendRecords(ctx, records)
+}
+
+func (s *FileStorage) appendRecords(ctx context.Context, records []historyRecord) error {
+	if s.wal == nil || len(records) == 0 {
+		return nil
+	}
+
+	buf, err := marshalRecords(records)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.historyFile.Write(buf); err != nil {
+		return err
+	}
+
+	s.historyLines += len(records)
+
+	if _, err := s.wal.Write(buf); err != nil {
+		return err
+	}
+
+	s.walSize += int64(len(buf))
+
+	if s.cfg.StoreInterval != 0 {
+		return nil
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+
+	if s.walSize < walCompactSize {
+		return nil
+	}
+
+	return s.compact(ctx)
+}
+
+// marshalRecords encodes records as json lines
+func marshalRecords(records []historyRecord) ([]byte, error) {
+	var buf []byte
+
+	for _, record := range records {
+		res, err := json.Marshal(record)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf = append(append(buf, res...), '\n')
+	}
+
+	return buf, nil
+}
+
+// compact writes snapshot of all metrics and truncates the wal
+func (s *FileStorage) compact(ctx context.Context) error {
+	if s.wal == nil {
 		return errors.New("file not found")
 	}
 
@@ -162,57 +590,108 @@ func (s *FileStorage) save(ctx context.Context) error {
 		return err
 	}
 
-	res, err := json.Marshal(metrics)
+	res, err := json.Marshal(snapshot{TotalMetrics: metrics, Updated: s.storage.updatedTimes()})
 
 	if err != nil {
 		return err
 	}
 
-	cleanErr := s.cleanFile()
+	err = writeFileAtomic(s.cfg.FileStoragePath, res)
 
-	if cleanErr != nil {
-		return cleanErr
+	if err != nil {
+		return err
 	}
 
-	_, writeErr := s.file.Write(res)
+	// wal records are idempotent, so crash before truncate only replays them once more
+	err = s.wal.Truncate(0)
 
-	if writeErr != nil {
-		return writeErr
+	if err != nil {
+		return err
 	}
 
-	s.logger.Infow("success save in file", "metrics", string(res), "filePath", s.cfg.FileStoragePath)
+	s.walSize = 0
+
+	s.logger.Infow("success save snapshot", "metrics", string(res), "filePath", s.cfg.FileStoragePath)
 
 	return nil
 }
 
-func (s *FileStorage) storeInterval(ctx context.Context) error {
+// storeInterval compacts wal into snapshot in background every StoreInterval seconds until ctx is done or storage is closed
+func (s *FileStorage) storeInterval(ctx context.Context) {
 	if s.cfg.StoreInterval == 0 {
-		return nil
+		return
 	}
-	storeDuration := time.Duration(s.cfg.StoreInterval) * time.Second
+
+	ctx, s.stopStore = context.WithCancel(ctx)
+	s.storeDone = make(chan struct{})
+
+	ticker := time.NewTicker(time.Duration(s.cfg.StoreInterval) * time.Second)
+
 	go func() {
+		defer close(s.storeDone)
+		defer ticker.Stop()
+
 		for {
-			time.Sleep(storeDuration)
-			err := s.save(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			s.writeM.Lock()
+			err := s.compact(ctx)
+			s.writeM.Unlock()
 
 			if err != nil {
 				s.logger.Errorw("error while saving", "error", err)
 			}
 		}
 	}()
-	return nil //TODO: fix this
 }
 
-func (s *FileStorage) cleanFile() error {
-	_, err := s.file.Seek(0, io.SeekStart)
+// writeFileAtomic writes data to temp file in the same directory, syncs it and renames over path,
+// so readers see either old or new content, never a half-written file
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// rename is durable only after directory entry is synced
+	d, err := os.Open(dir)
+
 	if err != nil {
 		return err
 	}
 
-	err = s.file.Truncate(0)
-	return err
+	defer d.Close()
+
+	return d.Sync()
 }
 
 func NewFileStorage(cfg *config.Config, storage *MemStorage, logger logger.ILogger) *FileStorage {
-	return &FileStorage{cfg, storage, nil, logger}
+	return &FileStorage{cfg: cfg, storage: storage, logger: logger}
 }
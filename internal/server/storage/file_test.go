@@ -4,11 +4,14 @@ package storage_test
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sodiqit/metricpulse.git/internal/constants"
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/internal/logger"
 	"github.com/sodiqit/metricpulse.git/internal/server/config"
@@ -18,13 +21,32 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func readFile(t *testing.T, file *os.File) string {
-	fileBody, err := io.ReadAll(file)
+func readFile(t *testing.T, path string) string {
+	fileBody, err := os.ReadFile(path)
 	require.NoError(t, err)
 
 	return string(fileBody)
 }
 
+// readSnapshotMetrics returns snapshot file without update times, which depend on the test run
+func readSnapshotMetrics(t *testing.T, path string) string {
+	var snapshot map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal([]byte(readFile(t, path)), &snapshot))
+
+	delete(snapshot, "updated")
+
+	res, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+
+	return string(res)
+}
+
+func removeStorageFiles(path string) {
+	os.Remove(path)
+	os.Remove(path + ".wal")
+	os.Remove(path + ".history")
+}
+
 func TestFileStorage_SaveInFile(t *testing.T) {
 	logger, err := logger.Initialize("info")
 	require.NoError(t, err)
@@ -40,7 +62,7 @@ func TestFileStorage_SaveInFile(t *testing.T) {
 				file, err := os.CreateTemp("./", "*db.json")
 				require.NoError(t, err)
 
-				defer os.Remove(file.Name())
+				defer removeStorageFiles(file.Name())
 
 				cfg := config.Config{FileStoragePath: file.Name()}
 				store := storage.NewMemStorage()
@@ -53,17 +75,15 @@ func TestFileStorage_SaveInFile(t *testing.T) {
 			},
 		},
 		{
-			name: "should sync save in file if store interval == 0",
+			name: "should append update to wal if store interval == 0",
 			tBody: func() {
 				file, err := os.CreateTemp("./", "*db.json")
 				require.NoError(t, err)
 
-				defer os.Remove(file.Name())
+				defer removeStorageFiles(file.Name())
 
 				cfg := config.Config{FileStoragePath: file.Name()}
 
-				expectedRes := `{"counter": {"test": 1}, "gauge": {}}`
-
 				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
 				defer fileStorage.Close(ctx)
 				err = fileStorage.Init(ctx, retry.EmptyBackoff)
@@ -72,17 +92,22 @@ func TestFileStorage_SaveInFile(t *testing.T) {
 				_, err = fileStorage.SaveCounterMetric(ctx, "test", 1)
 				require.NoError(t, err)
 
-				res := readFile(t, file)
-				assert.JSONEq(t, expectedRes, res)
+				// snapshot is not rewritten on every update
+				assert.JSONEq(t, `{"counter": {}, "gauge": {}}`, readFile(t, file.Name()))
+
+				wal := strings.Split(strings.TrimSpace(readFile(t, file.Name()+".wal")), "\n")
+				require.Len(t, wal, 1)
+				assert.Contains(t, wal[0], `"type":"counter","name":"test"`)
+				assert.Contains(t, wal[0], `"counter":1`)
 			},
 		},
 		{
-			name: "should async save in file if store interval > 0",
+			name: "should compact wal into snapshot if store interval > 0",
 			tBody: func() {
 				file, err := os.CreateTemp("./", "*db.json")
 				require.NoError(t, err)
 
-				defer os.Remove(file.Name())
+				defer removeStorageFiles(file.Name())
 
 				cfg := config.Config{FileStoragePath: file.Name(), StoreInterval: 1}
 
@@ -97,46 +122,75 @@ func TestFileStorage_SaveInFile(t *testing.T) {
 				require.NoError(t, err)
 
 				//read file before async update
-				res := readFile(t, file)
-				assert.Equal(t, "", res)
+				assert.JSONEq(t, `{"counter": {}, "gauge": {}}`, readFile(t, file.Name()))
+				assert.NotEmpty(t, readFile(t, file.Name()+".wal"))
 
 				sleepDur := time.Duration(cfg.StoreInterval)*time.Second + time.Duration(100)*time.Millisecond
 
 				time.Sleep(sleepDur)
 
-				//read file before async update
-				res = readFile(t, file)
-				assert.JSONEq(t, expectedRes, res)
+				//read file after async update
+				assert.JSONEq(t, expectedRes, readSnapshotMetrics(t, file.Name()))
+				assert.Empty(t, readFile(t, file.Name()+".wal"))
+			},
+		},
+		{
+			name: "should flush metrics on close before store interval elapsed",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				cfg := config.Config{FileStoragePath: file.Name(), StoreInterval: 300}
+
+				expectedRes := `{"counter": {"test": 1}, "gauge": {}}`
+
+				initCtx, cancel := context.WithCancel(ctx)
+
+				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				err = fileStorage.Init(initCtx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				_, err = fileStorage.SaveCounterMetric(ctx, "test", 1)
+				require.NoError(t, err)
+
+				// cancelled init context stops background saving, but not the final flush
+				cancel()
+
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				assert.JSONEq(t, expectedRes, readSnapshotMetrics(t, file.Name()))
+				assert.Empty(t, readFile(t, file.Name()+".wal"))
 			},
 		},
 		{
-			name: "should clean file before update",
+			name: "should overwrite snapshot if restore option is false",
 			tBody: func() {
 				file, err := os.CreateTemp("./", "*db.json")
 				require.NoError(t, err)
 
-				defer os.Remove(file.Name())
+				defer removeStorageFiles(file.Name())
 
 				_, writeErr := file.Write([]byte(`{"test123": true}`))
 				require.NoError(t, writeErr)
 
-				_, seekErr := file.Seek(0, io.SeekStart)
-				require.NoError(t, seekErr)
-
 				cfg := config.Config{FileStoragePath: file.Name()}
 
 				expectedRes := `{"counter": {"test": 1}, "gauge": {}}`
 
 				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
-				defer fileStorage.Close(ctx)
 				err = fileStorage.Init(ctx, retry.EmptyBackoff)
 				require.NoError(t, err)
 
 				_, err = fileStorage.SaveCounterMetric(ctx, "test", 1)
 				require.NoError(t, err)
 
-				res := readFile(t, file)
-				assert.JSONEq(t, expectedRes, res)
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				assert.JSONEq(t, expectedRes, readSnapshotMetrics(t, file.Name()))
 			},
 		},
 	}
@@ -163,7 +217,7 @@ func TestFileStorage_LoadFromFile(t *testing.T) {
 				file, err := os.CreateTemp("./", "*db.json")
 				require.NoError(t, err)
 
-				defer os.Remove(file.Name())
+				defer removeStorageFiles(file.Name())
 
 				_, writeErr := file.Write([]byte(`{"counter": {"test": 1}}`))
 				require.NoError(t, writeErr)
@@ -197,7 +251,7 @@ func TestFileStorage_LoadFromFile(t *testing.T) {
 				file, err := os.CreateTemp("./", "*db.json")
 				require.NoError(t, err)
 
-				defer os.Remove(file.Name())
+				defer removeStorageFiles(file.Name())
 
 				_, writeErr := file.Write([]byte(`{"counter": {"test": 1}}`))
 				require.NoError(t, writeErr)
@@ -213,9 +267,11 @@ func TestFileStorage_LoadFromFile(t *testing.T) {
 				err = fileStorage.Init(ctx, retry.EmptyBackoff)
 				require.NoError(t, err)
 
-				expectedMetrics := entities.TotalMetrics{Counter: map[string]int64{
-					"test": 1,
-				}}
+				expectedMetrics := entities.TotalMetrics{
+					Gauge:     map[string]float64{},
+					Counter:   map[string]int64{"test": 1},
+					Histogram: map[string]entities.Histogram{},
+				}
 
 				resultMetrics, err := fileStorage.GetAllMetrics(ctx)
 				require.NoError(t, err)
@@ -223,6 +279,275 @@ func TestFileStorage_LoadFromFile(t *testing.T) {
 				assert.Equal(t, expectedMetrics, resultMetrics)
 			},
 		},
+		{
+			name: "should restore metric history from history file",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+				start := time.Now()
+
+				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				err = fileStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				_, err = fileStorage.SaveGaugeMetric(ctx, "test", 1.5)
+				require.NoError(t, err)
+
+				delta := int64(2)
+				_, err = fileStorage.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "test", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "test", MType: constants.MetricTypeCounter, Delta: &delta},
+				})
+				require.NoError(t, err)
+
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				restoredStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				gaugeHistory, err := restoredStorage.GetMetricHistory(ctx, constants.MetricTypeGauge, "test", start, time.Now())
+				require.NoError(t, err)
+				require.Len(t, gaugeHistory, 1)
+				assert.Equal(t, 1.5, gaugeHistory[0].Gauge)
+
+				counterHistory, err := restoredStorage.GetMetricHistory(ctx, constants.MetricTypeCounter, "test", start, time.Now())
+				require.NoError(t, err)
+				require.Len(t, counterHistory, 2)
+				assert.Equal(t, int64(2), counterHistory[0].Counter)
+				assert.Equal(t, int64(4), counterHistory[1].Counter)
+			},
+		},
+		{
+			name: "should replay wal on top of snapshot",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				_, err = file.Write([]byte(`{"counter": {"test": 1, "other": 7}, "gauge": {"temp": 1.5}}`))
+				require.NoError(t, err)
+
+				wal := `{"type":"counter","name":"test","timestamp":"2024-01-01T00:00:00Z","gauge":0,"counter":3}
+{"type":"gauge","name":"temp","timestamp":"2024-01-01T00:00:01Z","gauge":2.5,"counter":0}
+{"type":"counter","name":"test","timestamp":"2024-01-01T00:00:02Z","gauge":0,"counter":5}
+{"type":"gauge","name":"te`
+				err = os.WriteFile(file.Name()+".wal", []byte(wal), 0666)
+				require.NoError(t, err)
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+
+				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer fileStorage.Close(ctx)
+				err = fileStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				resultMetrics, err := fileStorage.GetAllMetrics(ctx)
+				require.NoError(t, err)
+
+				assert.Equal(t, map[string]int64{"test": 5, "other": 7}, resultMetrics.Counter)
+				assert.Equal(t, map[string]float64{"temp": 2.5}, resultMetrics.Gauge)
+
+				// replayed wal is compacted into snapshot on start
+				assert.JSONEq(t, `{"counter": {"test": 5, "other": 7}, "gauge": {"temp": 2.5}}`, readSnapshotMetrics(t, file.Name()))
+				assert.Contains(t, readFile(t, file.Name()), `"gauge":{"temp":"2024-01-01T00:00:01Z"}`)
+				assert.Empty(t, readFile(t, file.Name()+".wal"))
+			},
+		},
+		{
+			name: "should skip torn last line of history file and keep appending after it",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				history := `{"type":"gauge","name":"temp","timestamp":"2024-01-01T00:00:00Z","gauge":1.5,"counter":0}
+{"type":"gauge","name":"temp","timestamp":"2024-01-01T00:00:01Z","gauge":2.5,"counter":0}
+{"type":"gauge","name":"te`
+				err = os.WriteFile(file.Name()+".history", []byte(history), 0666)
+				require.NoError(t, err)
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+
+				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				err = fileStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				_, err = fileStorage.SaveGaugeMetric(ctx, "temp", 3.5)
+				require.NoError(t, err)
+
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				restoredStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				samples, err := restoredStorage.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", time.Time{}, time.Now())
+				require.NoError(t, err)
+				gauges, _ := samplesValues(samples)
+				assert.Equal(t, []float64{1.5, 2.5, 3.5}, gauges)
+			},
+		},
+		{
+			name: "should return error if history file is corrupted in the middle",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				history := `{"type":"gauge","name":"te
+{"type":"gauge","name":"temp","timestamp":"2024-01-01T00:00:01Z","gauge":2.5,"counter":0}
+`
+				err = os.WriteFile(file.Name()+".history", []byte(history), 0666)
+				require.NoError(t, err)
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+
+				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer fileStorage.Close(ctx)
+				err = fileStorage.Init(ctx, retry.EmptyBackoff)
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "should keep update time of restored series, so expiry doesn't start over",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+
+				fileStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				err = fileStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				_, err = fileStorage.SaveGaugeMetric(ctx, "stale", 1)
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond)
+				updatedBefore := time.Now()
+
+				_, err = fileStorage.SaveGaugeMetric(ctx, "fresh", 1)
+				require.NoError(t, err)
+
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				restoredStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				expired, err := restoredStorage.ExpireMetrics(ctx, constants.MetricTypeGauge, updatedBefore)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"stale"}, expired)
+			},
+		},
+		{
+			name: "should restore updates from wal after crash",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+
+				// storage is never closed, as if process was killed
+				crashedStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				err = crashedStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				_, err = crashedStorage.SaveCounterMetric(ctx, "test", 2)
+				require.NoError(t, err)
+				_, err = crashedStorage.SaveCounterMetric(ctx, "test", 3)
+				require.NoError(t, err)
+				_, err = crashedStorage.SaveGaugeMetric(ctx, "temp", 1.5)
+				require.NoError(t, err)
+
+				restoredStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				counter, err := restoredStorage.GetCounterMetric(ctx, "test")
+				require.NoError(t, err)
+				assert.Equal(t, int64(5), counter)
+
+				gauge, err := restoredStorage.GetGaugeMetric(ctx, "temp")
+				require.NoError(t, err)
+				assert.Equal(t, 1.5, gauge)
+			},
+		},
+		{
+			name: "should restore deletions from wal and history after crash",
+			tBody: func() {
+				file, err := os.CreateTemp("./", "*db.json")
+				require.NoError(t, err)
+
+				defer removeStorageFiles(file.Name())
+
+				cfg := config.Config{FileStoragePath: file.Name(), Restore: true}
+
+				// storage is never closed, as if process was killed
+				crashedStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				err = crashedStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				_, err = crashedStorage.SaveGaugeMetric(ctx, "deleted", 1)
+				require.NoError(t, err)
+				_, err = crashedStorage.SaveGaugeMetric(ctx, "expired", 1)
+				require.NoError(t, err)
+				_, err = crashedStorage.SaveGaugeMetric(ctx, "recreated", 1)
+				require.NoError(t, err)
+
+				err = crashedStorage.DeleteMetric(ctx, constants.MetricTypeGauge, "deleted")
+				require.NoError(t, err)
+				err = crashedStorage.DeleteMetricBatch(ctx, []entities.Metrics{{ID: "recreated", MType: constants.MetricTypeGauge}})
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond)
+				updatedBefore := time.Now()
+
+				_, err = crashedStorage.SaveGaugeMetric(ctx, "recreated", 2)
+				require.NoError(t, err)
+
+				expired, err := crashedStorage.ExpireMetrics(ctx, constants.MetricTypeGauge, updatedBefore)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"expired"}, expired)
+
+				restoredStorage := storage.NewFileStorage(&cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				metrics, err := restoredStorage.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]float64{"recreated": 2}, metrics.Gauge)
+
+				history, err := restoredStorage.GetMetricHistory(ctx, constants.MetricTypeGauge, "recreated", time.Time{}, time.Now())
+				require.NoError(t, err)
+				gauges, _ := samplesValues(history)
+				assert.Equal(t, []float64{2}, gauges)
+
+				history, err = restoredStorage.GetMetricHistory(ctx, constants.MetricTypeGauge, "deleted", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, history)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -230,3 +555,105 @@ func TestFileStorage_LoadFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestFileStorage_Retention(t *testing.T) {
+	logger, err := logger.Initialize("info")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		tBody func(cfg *config.Config)
+	}{
+		{
+			name: "should restore rollups from history file",
+			tBody: func(cfg *config.Config) {
+				fileStorage := storage.NewFileStorage(cfg, storage.NewMemStorage(), logger)
+				err := fileStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				bucket := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+				err = fileStorage.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket, Min: 1, Max: 1, Sum: 1, Count: 1},
+				})
+				require.NoError(t, err)
+
+				// replaced rollup wins after restore
+				err = fileStorage.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket, Min: 1, Max: 3, Sum: 4, Count: 2},
+				})
+				require.NoError(t, err)
+
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				restoredStorage := storage.NewFileStorage(cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				rollups, err := restoredStorage.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, bucket, bucket)
+				require.NoError(t, err)
+				assert.Equal(t, []entities.Rollup{{Timestamp: bucket, Min: 1, Max: 3, Sum: 4, Count: 2}}, rollups)
+			},
+		},
+		{
+			name: "should rewrite history file without expired samples",
+			tBody: func(cfg *config.Config) {
+				fileStorage := storage.NewFileStorage(cfg, storage.NewMemStorage(), logger)
+				err := fileStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				for i := 0; i < 3; i++ {
+					_, err = fileStorage.SaveGaugeMetric(ctx, "expired", float64(i))
+					require.NoError(t, err)
+				}
+
+				time.Sleep(time.Millisecond)
+				before := time.Now()
+
+				_, err = fileStorage.SaveGaugeMetric(ctx, "kept", 1)
+				require.NoError(t, err)
+
+				err = fileStorage.DeleteHistory(ctx, 0, before)
+				require.NoError(t, err)
+
+				history := readFile(t, cfg.FileStoragePath+".history")
+				assert.Equal(t, 1, strings.Count(history, "\n"))
+				assert.Contains(t, history, `"name":"kept"`)
+
+				// appends go to the rewritten file
+				_, err = fileStorage.SaveGaugeMetric(ctx, "kept", 2)
+				require.NoError(t, err)
+
+				err = fileStorage.Close(ctx)
+				require.NoError(t, err)
+
+				restoredStorage := storage.NewFileStorage(cfg, storage.NewMemStorage(), logger)
+				defer restoredStorage.Close(ctx)
+				err = restoredStorage.Init(ctx, retry.EmptyBackoff)
+				require.NoError(t, err)
+
+				samples, err := restoredStorage.GetMetricHistory(ctx, constants.MetricTypeGauge, "kept", time.Time{}, time.Now())
+				require.NoError(t, err)
+				gauges, _ := samplesValues(samples)
+				assert.Equal(t, []float64{1, 2}, gauges)
+
+				samples, err = restoredStorage.GetMetricHistory(ctx, constants.MetricTypeGauge, "expired", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, samples)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.CreateTemp("./", "*db.json")
+			require.NoError(t, err)
+
+			defer removeStorageFiles(file.Name())
+
+			tt.tBody(&config.Config{FileStoragePath: file.Name(), Restore: true})
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+)
+
+type seriesKey struct {
+	MType string `json:"type"`
+	Name  string `json:"name"`
+}
+
+// historyRecord is a line of history and wal files. Deleted record means the series was removed,
+// record with rollup keeps rollup of the given resolution instead of the sample
+type historyRecord struct {
+	seriesKey
+	entities.MetricSample
+	Deleted    bool             `json:"deleted,omitempty"`
+	Resolution time.Duration    `json:"resolution,omitempty"`
+	Rollup     *entities.Rollup `json:"rollup,omitempty"`
+}
+
+type metricHistory struct {
+	series map[seriesKey][]entities.MetricSample
+}
+
+func (h *metricHistory) append(key seriesKey, sample entities.MetricSample) {
+	h.series[key] = append(h.series[key], sample)
+}
+
+func (h *metricHistory) delete(key seriesKey) {
+	delete(h.series, key)
+}
+
+// last returns up to n latest samples of the series
+func (h *metricHistory) last(key seriesKey, n int) []entities.MetricSample {
+	samples := h.series[key]
+
+	if n > len(samples) {
+		n = len(samples)
+	}
+
+	return samples[len(samples)-n:]
+}
+
+// between returns samples with timestamps in [from, to]. Samples are appended in time order,
+// so boundaries are found with binary search.
+func (h *metricHistory) between(key seriesKey, from, to time.Time) []entities.MetricSample {
+	samples := h.series[key]
+
+	start := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Timestamp.Before(from)
+	})
+	end := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp.After(to)
+	})
+
+	if start >= end {
+		return []entities.MetricSample{}
+	}
+
+	result := make([]entities.MetricSample, end-start)
+	copy(result, samples[start:end])
+
+	return result
+}
+
+// deleteBefore removes samples older than before and returns number of removed samples
+func (h *metricHistory) deleteBefore(before time.Time) int {
+	removed := 0
+
+	for key, samples := range h.series {
+		i := sort.Search(len(samples), func(i int) bool {
+			return !samples[i].Timestamp.Before(before)
+		})
+
+		if i == 0 {
+			continue
+		}
+
+		removed += i
+
+		if i == len(samples) {
+			delete(h.series, key)
+			continue
+		}
+
+		// copy keeps removed samples from pinning the old array
+		h.series[key] = append([]entities.MetricSample(nil), samples[i:]...)
+	}
+
+	return removed
+}
+
+func newMetricHistory() *metricHistory {
+	return &metricHistory{series: make(map[seriesKey][]entities.MetricSample)}
+}
+
+// rollupHistory keeps rollups of one resolution sorted by start time
+type rollupHistory struct {
+	series map[seriesKey][]entities.Rollup
+}
+
+// save inserts rollups keeping order, rollup with the same start is replaced. Returns number of replaced rollups
+func (h *rollupHistory) save(key seriesKey, rollups []entities.Rollup) int {
+	replaced := 0
+
+	for _, rollup := range rollups {
+		current := h.series[key]
+
+		i := sort.Search(len(current), func(i int) bool {
+			return !current[i].Timestamp.Before(rollup.Timestamp)
+		})
+
+		if i < len(current) && current[i].Timestamp.Equal(rollup.Timestamp) {
+			current[i] = rollup
+			replaced++
+			continue
+		}
+
+		current = append(current, entities.Rollup{})
+		copy(current[i+1:], current[i:])
+		current[i] = rollup
+
+		h.series[key] = current
+	}
+
+	return replaced
+}
+
+// between returns rollups which start in [from, to]
+func (h *rollupHistory) between(key seriesKey, from, to time.Time) []entities.Rollup {
+	rollups := h.series[key]
+
+	start := sort.Search(len(rollups), func(i int) bool {
+		return !rollups[i].Timestamp.Before(from)
+	})
+	end := sort.Search(len(rollups), func(i int) bool {
+		return rollups[i].Timestamp.After(to)
+	})
+
+	if start >= end {
+		return []entities.Rollup{}
+	}
+
+	result := make([]entities.Rollup, end-start)
+	copy(result, rollups[start:end])
+
+	return result
+}
+
+func (h *rollupHistory) delete(key seriesKey) {
+	delete(h.series, key)
+}
+
+// deleteBefore removes rollups which start before before and returns number of removed rollups
+func (h *rollupHistory) deleteBefore(before time.Time) int {
+	removed := 0
+
+	for key, rollups := range h.series {
+		i := sort.Search(len(rollups), func(i int) bool {
+			return !rollups[i].Timestamp.Before(before)
+		})
+
+		if i == 0 {
+			continue
+		}
+
+		removed += i
+
+		if i == len(rollups) {
+			delete(h.series, key)
+			continue
+		}
+
+		h.series[key] = append([]entities.Rollup(nil), rollups[i:]...)
+	}
+
+	return removed
+}
+
+func newRollupHistory() *rollupHistory {
+	return &rollupHistory{series: make(map[seriesKey][]entities.Rollup)}
+}
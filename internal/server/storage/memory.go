@@ -3,36 +3,205 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
 
 	"github.com/sodiqit/metricpulse.git/internal/constants"
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 )
 
+// memShardsCount is number of independently locked parts of MemStorage
+const memShardsCount = 32
+
+type memShard struct {
+	sync.RWMutex
+	gauge     map[string]float64
+	counter   map[string]int64
+	histogram map[string]entities.Histogram
+	history   *metricHistory
+	// rollups keeps downsampled history by resolution
+	rollups map[time.Duration]*rollupHistory
+	// updated keeps time of the last update of every series for expiry
+	updated map[seriesKey]time.Time
+}
+
+func newMemShard() *memShard {
+	return &memShard{
+		gauge:     make(map[string]float64),
+		counter:   make(map[string]int64),
+		histogram: make(map[string]entities.Histogram),
+		history:   newMetricHistory(),
+		rollups:   make(map[time.Duration]*rollupHistory),
+		updated:   make(map[seriesKey]time.Time),
+	}
+}
+
+// append records the sample in history and marks the series updated at the sample time
+func (s *memShard) append(key seriesKey, sample entities.MetricSample) {
+	s.history.append(key, sample)
+	s.updated[key] = sample.Timestamp
+}
+
+// current returns current value of the series, zero sample if series doesn't exist
+func (s *memShard) current(key seriesKey) entities.MetricSample {
+	var sample entities.MetricSample
+
+	switch key.MType {
+	case constants.MetricTypeGauge:
+		sample.Gauge = s.gauge[key.Name]
+	case constants.MetricTypeCounter:
+		sample.Counter = s.counter[key.Name]
+	case constants.MetricTypeHistogram:
+		if val, ok := s.histogram[key.Name]; ok {
+			sample.Histogram = &val
+		}
+	}
+
+	return sample
+}
+
+// set replaces value of the series with value from the sample
+func (s *memShard) set(key seriesKey, sample entities.MetricSample) {
+	switch key.MType {
+	case constants.MetricTypeGauge:
+		s.gauge[key.Name] = sample.Gauge
+	case constants.MetricTypeCounter:
+		s.counter[key.Name] = sample.Counter
+	case constants.MetricTypeHistogram:
+		if sample.Histogram != nil {
+			s.histogram[key.Name] = sample.Histogram.Copy()
+		}
+	}
+}
+
+// delete removes the series with its history and rollups. Returns false if series doesn't exist
+func (s *memShard) delete(key seriesKey) bool {
+	s.history.delete(key)
+
+	for _, rollups := range s.rollups {
+		rollups.delete(key)
+	}
+
+	return s.deleteValue(key)
+}
+
+// deleteValue removes current value of the series, history is kept
+func (s *memShard) deleteValue(key seriesKey) bool {
+	var ok bool
+
+	switch key.MType {
+	case constants.MetricTypeGauge:
+		_, ok = s.gauge[key.Name]
+		delete(s.gauge, key.Name)
+	case constants.MetricTypeCounter:
+		_, ok = s.counter[key.Name]
+		delete(s.counter, key.Name)
+	case constants.MetricTypeHistogram:
+		_, ok = s.histogram[key.Name]
+		delete(s.histogram, key.Name)
+	}
+
+	delete(s.updated, key)
+
+	return ok
+}
+
+// MemStorage is safe for concurrent use. Series are spread over shards by series id,
+// so updates of different series rarely wait for each other
 type MemStorage struct {
-	gauge   map[string]float64
-	counter map[string]int64
+	shards []*memShard
+}
+
+func (m *MemStorage) shard(metricName string) *memShard {
+	h := fnv.New32a()
+	h.Write([]byte(metricName))
+
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
 }
 
 func (m *MemStorage) SaveGaugeMetric(ctx context.Context, metricType string, value float64) (float64, error) {
-	m.gauge[metricType] = value
+	s := m.shard(metricType)
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.gauge[metricType] = value
+
+	s.append(seriesKey{constants.MetricTypeGauge, metricType}, entities.MetricSample{Timestamp: time.Now(), Gauge: value})
+
 	return value, nil
 }
 
+func (m *MemStorage) AddGaugeMetric(ctx context.Context, metricType string, delta float64) (float64, error) {
+	s := m.shard(metricType)
+
+	s.Lock()
+	defer s.Unlock()
+
+	result := s.gauge[metricType] + delta
+
+	s.gauge[metricType] = result
+
+	s.append(seriesKey{constants.MetricTypeGauge, metricType}, entities.MetricSample{Timestamp: time.Now(), Gauge: result})
+
+	return result, nil
+}
+
 func (m *MemStorage) SaveCounterMetric(ctx context.Context, metricType string, value int64) (int64, error) {
-	val, ok := m.counter[metricType]
+	s := m.shard(metricType)
 
-	if ok {
-		m.counter[metricType] = val + value
-	} else {
-		m.counter[metricType] = value
+	s.Lock()
+	defer s.Unlock()
+
+	result, err := entities.AddCounter(s.counter[metricType], value)
+
+	if err != nil {
+		return 0, fmt.Errorf("error while save counter metric; metricName: %s, metricValue: %d, err: %w", metricType, value, err)
 	}
 
-	return m.counter[metricType], nil
+	s.counter[metricType] = result
+
+	s.append(seriesKey{constants.MetricTypeCounter, metricType}, entities.MetricSample{Timestamp: time.Now(), Counter: result})
+
+	return result, nil
+}
+
+func (m *MemStorage) SaveHistogramMetric(ctx context.Context, metricType string, value entities.Histogram) (entities.Histogram, error) {
+	s := m.shard(metricType)
+
+	s.Lock()
+	defer s.Unlock()
+
+	result := value.Copy()
+
+	if val, ok := s.histogram[metricType]; ok {
+		merged, err := val.Merge(value)
+
+		if err != nil {
+			return entities.Histogram{}, fmt.Errorf("error while merge histogram metric; metricName: %s, err: %w", metricType, err)
+		}
+
+		result = merged
+	}
+
+	s.histogram[metricType] = result
+
+	sample := result.Copy()
+	s.append(seriesKey{constants.MetricTypeHistogram, metricType}, entities.MetricSample{Timestamp: time.Now(), Histogram: &sample})
+
+	return result.Copy(), nil
 }
 
 func (m *MemStorage) GetGaugeMetric(ctx context.Context, metricName string) (float64, error) {
-	val, ok := m.gauge[metricName]
+	s := m.shard(metricName)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	val, ok := s.gauge[metricName]
 
 	if ok {
 		return val, nil
@@ -42,7 +211,12 @@ func (m *MemStorage) GetGaugeMetric(ctx context.Context, metricName string) (flo
 }
 
 func (m *MemStorage) GetCounterMetric(ctx context.Context, metricName string) (int64, error) {
-	val, ok := m.counter[metricName]
+	s := m.shard(metricName)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	val, ok := s.counter[metricName]
 
 	if ok {
 		return val, nil
@@ -51,28 +225,445 @@ func (m *MemStorage) GetCounterMetric(ctx context.Context, metricName string) (i
 	}
 }
 
+func (m *MemStorage) GetHistogramMetric(ctx context.Context, metricName string) (entities.Histogram, error) {
+	s := m.shard(metricName)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	val, ok := s.histogram[metricName]
+
+	if ok {
+		return val.Copy(), nil
+	} else {
+		return val, NewErrNotFound(errors.New("not found metric"), map[string]interface{}{"metricName": metricName})
+	}
+}
+
+// GetAllMetrics returns copy of all metrics. All shards are locked together, so the copy
+// doesn't contain half of a concurrent batch
 func (m *MemStorage) GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error) {
-	return entities.TotalMetrics{Gauge: m.gauge, Counter: m.counter}, nil
+	for _, s := range m.shards {
+		s.RLock()
+		defer s.RUnlock()
+	}
+
+	result := entities.TotalMetrics{
+		Gauge:     make(map[string]float64),
+		Counter:   make(map[string]int64),
+		Histogram: make(map[string]entities.Histogram),
+	}
+
+	for _, s := range m.shards {
+		for name, val := range s.gauge {
+			result.Gauge[name] = val
+		}
+
+		for name, val := range s.counter {
+			result.Counter[name] = val
+		}
+
+		for name, val := range s.histogram {
+			result.Histogram[name] = val.Copy()
+		}
+	}
+
+	return result, nil
 }
 
-func (m *MemStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+func (m *MemStorage) GetMetricHistory(ctx context.Context, metricType string, metricName string, from time.Time, to time.Time) ([]entities.MetricSample, error) {
+	s := m.shard(metricName)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.history.between(seriesKey{metricType, metricName}, from, to), nil
+}
+
+// SaveMetricBatch locks all shards of the batch and computes every update before applying any of them,
+// so rejected item leaves storage unchanged and readers never see half of the batch
+func (m *MemStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) ([]entities.Metrics, error) {
+	if err := validateBatch(metrics); err != nil {
+		return nil, err
+	}
+
+	defer m.lockShards(metrics)()
+
+	now := time.Now()
+	samples := make([]entities.MetricSample, len(metrics))
+	pending := make(map[seriesKey]entities.MetricSample)
+
+	for i, metric := range metrics {
+		key := seriesKey{metric.MType, metric.SeriesID()}
+
+		current, ok := pending[key]
+
+		if !ok {
+			current = m.shard(key.Name).current(key)
+		}
+
+		sample, err := applyBatchItem(current, metric)
+
+		if err != nil {
+			return nil, NewErrBatchItem(i, fmt.Errorf("error while save metrics batch; metricName: %s, err: %w", key.Name, err))
+		}
+
+		sample.Timestamp = now
+		samples[i] = sample
+		pending[key] = sample
+	}
+
+	result := make([]entities.Metrics, len(metrics))
+
+	for i, metric := range metrics {
+		key := seriesKey{metric.MType, metric.SeriesID()}
+		s := m.shard(key.Name)
+
+		s.set(key, samples[i])
+		s.append(key, samples[i])
+
+		result[i] = batchResult(metric, samples[i])
+	}
+
+	return result, nil
+}
+
+// lockShards locks shards of all batch series in shards order, which keeps concurrent batches from deadlock.
+// Returns unlock function
+func (m *MemStorage) lockShards(metrics []entities.Metrics) func() {
+	used := make(map[*memShard]bool)
+
 	for _, metric := range metrics {
-		if metric.MType == constants.MetricTypeGauge {
-			m.SaveGaugeMetric(ctx, metric.ID, *metric.Value)
-		} else {
-			m.SaveCounterMetric(ctx, metric.ID, *metric.Delta)
+		used[m.shard(metric.SeriesID())] = true
+	}
+
+	var locked []*memShard
+
+	for _, s := range m.shards {
+		if used[s] {
+			s.Lock()
+			locked = append(locked, s)
+		}
+	}
+
+	return func() {
+		for _, s := range locked {
+			s.Unlock()
 		}
 	}
+}
+
+// applyBatchItem returns value of the series after the update without changing the storage
+func applyBatchItem(current entities.MetricSample, metric entities.Metrics) (entities.MetricSample, error) {
+	switch metric.MType {
+	case constants.MetricTypeGauge:
+		return entities.MetricSample{Gauge: *metric.Value}, nil
+	case constants.MetricTypeCounter:
+		result, err := entities.AddCounter(current.Counter, *metric.Delta)
+
+		return entities.MetricSample{Counter: result}, err
+	default:
+		result := metric.Histogram.Copy()
+
+		if current.Histogram != nil {
+			merged, err := current.Histogram.Merge(*metric.Histogram)
+
+			if err != nil {
+				return entities.MetricSample{}, err
+			}
+
+			result = merged
+		}
+
+		return entities.MetricSample{Histogram: &result}, nil
+	}
+}
+
+func (m *MemStorage) DeleteMetric(ctx context.Context, metricType string, metricName string) error {
+	s := m.shard(metricName)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.delete(seriesKey{metricType, metricName}) {
+		return NewErrNotFound(errors.New("not found metric"), map[string]interface{}{"metricType": metricType, "metricName": metricName})
+	}
+
+	return nil
+}
+
+func (m *MemStorage) DeleteMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+	for _, metric := range metrics {
+		s := m.shard(metric.SeriesID())
+
+		s.Lock()
+		s.delete(seriesKey{metric.MType, metric.SeriesID()})
+		s.Unlock()
+	}
 
 	return nil
 }
 
+func (m *MemStorage) ExpireMetrics(ctx context.Context, metricType string, updatedBefore time.Time) ([]string, error) {
+	var expired []string
+
+	for _, s := range m.shards {
+		s.Lock()
+
+		for key, updated := range s.updated {
+			if key.MType == metricType && updated.Before(updatedBefore) {
+				s.delete(key)
+				expired = append(expired, key.Name)
+			}
+		}
+
+		s.Unlock()
+	}
+
+	return expired, nil
+}
+
+func (m *MemStorage) GetRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, from time.Time, to time.Time) ([]entities.Rollup, error) {
+	s := m.shard(metricName)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	rollups, ok := s.rollups[resolution]
+
+	if !ok {
+		return []entities.Rollup{}, nil
+	}
+
+	return rollups.between(seriesKey{metricType, metricName}, from, to), nil
+}
+
+func (m *MemStorage) SaveRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, rollups []entities.Rollup) error {
+	m.saveRollups(seriesKey{metricType, metricName}, resolution, rollups)
+
+	return nil
+}
+
+// saveRollups returns number of replaced rollups
+func (m *MemStorage) saveRollups(key seriesKey, resolution time.Duration, rollups []entities.Rollup) int {
+	s := m.shard(key.Name)
+
+	s.Lock()
+	defer s.Unlock()
+
+	history, ok := s.rollups[resolution]
+
+	if !ok {
+		history = newRollupHistory()
+		s.rollups[resolution] = history
+	}
+
+	return history.save(key, rollups)
+}
+
+func (m *MemStorage) DeleteHistory(ctx context.Context, resolution time.Duration, before time.Time) error {
+	m.deleteHistory(resolution, before)
+
+	return nil
+}
+
+// deleteHistory returns number of removed samples or rollups
+func (m *MemStorage) deleteHistory(resolution time.Duration, before time.Time) int {
+	removed := 0
+
+	for _, s := range m.shards {
+		s.Lock()
+
+		if resolution == 0 {
+			removed += s.history.deleteBefore(before)
+		} else if rollups, ok := s.rollups[resolution]; ok {
+			removed += rollups.deleteBefore(before)
+		}
+
+		s.Unlock()
+	}
+
+	return removed
+}
+
+// InitMetrics replaces all stored metrics with the given ones and marks them updated now.
+// File storage restores times of the last update from snapshot after it with restoreUpdated
 func (m *MemStorage) InitMetrics(metrics entities.TotalMetrics) error {
-	m.counter = metrics.Counter
-	m.gauge = metrics.Gauge
+	for _, s := range m.shards {
+		s.Lock()
+		defer s.Unlock()
+
+		s.gauge = make(map[string]float64)
+		s.counter = make(map[string]int64)
+		s.histogram = make(map[string]entities.Histogram)
+		s.updated = make(map[seriesKey]time.Time)
+	}
+
+	now := time.Now()
+
+	for name, val := range metrics.Gauge {
+		s := m.shard(name)
+		s.gauge[name] = val
+		s.updated[seriesKey{constants.MetricTypeGauge, name}] = now
+	}
+
+	for name, val := range metrics.Counter {
+		s := m.shard(name)
+		s.counter[name] = val
+		s.updated[seriesKey{constants.MetricTypeCounter, name}] = now
+	}
+
+	for name, val := range metrics.Histogram {
+		s := m.shard(name)
+		s.histogram[name] = val.Copy()
+		s.updated[seriesKey{constants.MetricTypeHistogram, name}] = now
+	}
+
 	return nil
 }
 
+// updatedTimes returns time of the last update of every series by metric type
+func (m *MemStorage) updatedTimes() map[string]map[string]time.Time {
+	result := make(map[string]map[string]time.Time)
+
+	for _, s := range m.shards {
+		s.RLock()
+
+		for key, at := range s.updated {
+			if result[key.MType] == nil {
+				result[key.MType] = make(map[string]time.Time)
+			}
+
+			result[key.MType][key.Name] = at
+		}
+
+		s.RUnlock()
+	}
+
+	return result
+}
+
+// restoreUpdated sets time of the last update of restored series, so their expiry continues.
+// Series missing in updated stay updated at restore time
+func (m *MemStorage) restoreUpdated(updated map[string]map[string]time.Time) {
+	for metricType, series := range updated {
+		for name, at := range series {
+			key := seriesKey{metricType, name}
+			s := m.shard(name)
+
+			s.Lock()
+
+			if _, ok := s.updated[key]; ok {
+				s.updated[key] = at
+			}
+
+			s.Unlock()
+		}
+	}
+}
+
+func (m *MemStorage) initHistory(records []historyRecord) {
+	for _, s := range m.shards {
+		s.Lock()
+		defer s.Unlock()
+
+		s.history = newMetricHistory()
+		s.rollups = make(map[time.Duration]*rollupHistory)
+	}
+
+	for _, record := range records {
+		s := m.shard(record.Name)
+
+		switch {
+		case record.Deleted:
+			s.history.delete(record.seriesKey)
+
+			for _, rollups := range s.rollups {
+				rollups.delete(record.seriesKey)
+			}
+		case record.Rollup != nil:
+			rollups, ok := s.rollups[record.Resolution]
+
+			if !ok {
+				rollups = newRollupHistory()
+				s.rollups[record.Resolution] = rollups
+			}
+
+			rollups.save(record.seriesKey, []entities.Rollup{*record.Rollup})
+		default:
+			s.history.append(record.seriesKey, record.MetricSample)
+		}
+	}
+}
+
+// historyRecords returns all samples and rollups as history file records
+func (m *MemStorage) historyRecords() []historyRecord {
+	var records []historyRecord
+
+	for _, s := range m.shards {
+		s.RLock()
+
+		for key, samples := range s.history.series {
+			for _, sample := range samples {
+				records = append(records, historyRecord{seriesKey: key, MetricSample: sample})
+			}
+		}
+
+		for resolution, history := range s.rollups {
+			for key, rollups := range history.series {
+				for _, rollup := range rollups {
+					rollup := rollup
+
+					records = append(records, historyRecord{
+						seriesKey:    key,
+						MetricSample: entities.MetricSample{Timestamp: rollup.Timestamp},
+						Resolution:   resolution,
+						Rollup:       &rollup,
+					})
+				}
+			}
+		}
+
+		s.RUnlock()
+	}
+
+	return records
+}
+
+// restoreRecord applies wal record: sets series value from the sample without appending it to history
+// or deletes the series
+func (m *MemStorage) restoreRecord(record historyRecord) {
+	key, sample := record.seriesKey, record.MetricSample
+	s := m.shard(key.Name)
+
+	s.Lock()
+	defer s.Unlock()
+
+	// history was restored from history file, which has the same deletions
+	if record.Deleted {
+		s.deleteValue(key)
+		return
+	}
+
+	s.updated[key] = sample.Timestamp
+	s.set(key, sample)
+}
+
+// lastSamples returns copy of up to n latest samples of the series
+func (m *MemStorage) lastSamples(key seriesKey, n int) []entities.MetricSample {
+	s := m.shard(key.Name)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	samples := s.history.last(key, n)
+
+	result := make([]entities.MetricSample, len(samples))
+	copy(result, samples)
+
+	return result
+}
+
 func (m *MemStorage) Init(context.Context, retry.Backoff) error {
 	return nil
 }
@@ -86,8 +677,11 @@ func (m *MemStorage) Close(context.Context) error {
 }
 
 func NewMemStorage() *MemStorage {
-	return &MemStorage{
-		gauge:   make(map[string]float64),
-		counter: make(map[string]int64),
+	shards := make([]*memShard, memShardsCount)
+
+	for i := range shards {
+		shards[i] = newMemShard()
 	}
+
+	return &MemStorage{shards: shards}
 }
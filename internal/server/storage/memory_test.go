@@ -0,0 +1,619 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func samplesValues(samples []entities.MetricSample) ([]float64, []int64) {
+	gauges := []float64{}
+	counters := []int64{}
+
+	for _, sample := range samples {
+		gauges = append(gauges, sample.Gauge)
+		counters = append(counters, sample.Counter)
+	}
+
+	return gauges, counters
+}
+
+func TestMemStorage_GetMetricHistory(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		tBody func()
+	}{
+		{
+			name: "should append sample on every save",
+			tBody: func() {
+				store := storage.NewMemStorage()
+				start := time.Now()
+
+				_, err := store.SaveGaugeMetric(ctx, "temp", 1.5)
+				require.NoError(t, err)
+				_, err = store.SaveGaugeMetric(ctx, "temp", 2.5)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "temp", 2)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "temp", 3)
+				require.NoError(t, err)
+
+				gaugeHistory, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", start, time.Now())
+				require.NoError(t, err)
+
+				gauges, _ := samplesValues(gaugeHistory)
+				assert.Equal(t, []float64{1.5, 2.5}, gauges)
+
+				counterHistory, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, "temp", start, time.Now())
+				require.NoError(t, err)
+
+				_, counters := samplesValues(counterHistory)
+				assert.Equal(t, []int64{2, 5}, counters)
+			},
+		},
+		{
+			name: "should append samples from batch",
+			tBody: func() {
+				store := storage.NewMemStorage()
+				start := time.Now()
+
+				delta := int64(1)
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+				})
+				require.NoError(t, err)
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, "PollCount", start, time.Now())
+				require.NoError(t, err)
+
+				_, counters := samplesValues(history)
+				assert.Equal(t, []int64{1, 2}, counters)
+			},
+		},
+		{
+			name: "should keep separate series for different labels",
+			tBody: func() {
+				store := storage.NewMemStorage()
+
+				web01, web02 := 1.0, 2.0
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &web01, Labels: map[string]string{"host": "web01", "env": "prod"}},
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &web02, Labels: map[string]string{"env": "prod", "host": "web02"}},
+				})
+				require.NoError(t, err)
+
+				val, err := store.GetGaugeMetric(ctx, entities.SeriesID("Alloc", map[string]string{"env": "prod", "host": "web01"}))
+				require.NoError(t, err)
+				assert.Equal(t, web01, val)
+
+				val, err = store.GetGaugeMetric(ctx, `Alloc{env="prod",host="web02"}`)
+				require.NoError(t, err)
+				assert.Equal(t, web02, val)
+
+				_, err = store.GetGaugeMetric(ctx, "Alloc")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should merge histogram updates",
+			tBody: func() {
+				store := storage.NewMemStorage()
+				start := time.Now()
+
+				first := entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{1, 0, 0}, Sum: 0.05, Count: 1}
+				second := entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{0, 1, 1}, Sum: 2.5, Count: 2}
+
+				_, err := store.SaveHistogramMetric(ctx, "latency", first)
+				require.NoError(t, err)
+
+				merged, err := store.SaveHistogramMetric(ctx, "latency", second)
+				require.NoError(t, err)
+				assert.Equal(t, entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{1, 1, 1}, Sum: 2.55, Count: 3}, merged)
+
+				_, err = store.SaveHistogramMetric(ctx, "latency", entities.Histogram{Bounds: []float64{1}, Counts: []int64{1, 0}, Count: 1})
+				assert.ErrorIs(t, err, entities.ErrHistogramBoundsMismatch)
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeHistogram, "latency", start, time.Now())
+				require.NoError(t, err)
+				require.Len(t, history, 2)
+				assert.Equal(t, int64(1), history[0].Histogram.Count)
+				assert.Equal(t, int64(3), history[1].Histogram.Count)
+			},
+		},
+		{
+			name: "should return only samples inside range",
+			tBody: func() {
+				store := storage.NewMemStorage()
+
+				_, err := store.SaveGaugeMetric(ctx, "temp", 1)
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond)
+				from := time.Now()
+
+				_, err = store.SaveGaugeMetric(ctx, "temp", 2)
+				require.NoError(t, err)
+
+				to := time.Now()
+				time.Sleep(time.Millisecond)
+
+				_, err = store.SaveGaugeMetric(ctx, "temp", 3)
+				require.NoError(t, err)
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", from, to)
+				require.NoError(t, err)
+
+				gauges, _ := samplesValues(history)
+				assert.Equal(t, []float64{2}, gauges)
+			},
+		},
+		{
+			name: "should return empty history for unknown metric",
+			tBody: func() {
+				store := storage.NewMemStorage()
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "unknown", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, history)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody()
+		})
+	}
+}
+
+func TestMemStorage_ConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemStorage()
+
+	const writers, iterations = 8, 200
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			delta, value := int64(1), float64(i)
+			histogram := entities.Histogram{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: 0.5, Count: 1}
+
+			for j := 0; j < iterations; j++ {
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: fmt.Sprintf("Gauge%d", j%10), MType: constants.MetricTypeGauge, Value: &value},
+					{ID: "latency", MType: constants.MetricTypeHistogram, Histogram: &histogram},
+				})
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+
+	// readers iterate snapshots while writers update the same series
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				metrics, err := store.GetAllMetrics(ctx)
+				assert.NoError(t, err)
+
+				_, err = json.Marshal(metrics)
+				assert.NoError(t, err)
+
+				_, err = store.GetMetricHistory(ctx, constants.MetricTypeCounter, "PollCount", time.Time{}, time.Now())
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	counter, err := store.GetCounterMetric(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(writers*iterations), counter)
+
+	histogram, err := store.GetHistogramMetric(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, int64(writers*iterations), histogram.Count)
+}
+
+func TestMemStorage_GetAllMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemStorage()
+
+	_, err := store.SaveCounterMetric(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	metrics, err := store.GetAllMetrics(ctx)
+	require.NoError(t, err)
+
+	metrics.Counter["PollCount"] = 100
+
+	val, err := store.GetCounterMetric(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), val, "returned metrics must be a copy")
+}
+
+func BenchmarkMemStorage_SaveMetricBatch(b *testing.B) {
+	ctx := context.Background()
+	store := storage.NewMemStorage()
+
+	delta, value := int64(1), 1.5
+
+	metrics := make([]entities.Metrics, 0, 50)
+	for i := 0; i < 25; i++ {
+		metrics = append(metrics,
+			entities.Metrics{ID: fmt.Sprintf("Counter%d", i), MType: constants.MetricTypeCounter, Delta: &delta},
+			entities.Metrics{ID: fmt.Sprintf("Gauge%d", i), MType: constants.MetricTypeGauge, Value: &value},
+		)
+	}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := store.SaveMetricBatch(ctx, metrics); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestMemStorage_SaveCounterMetric(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		deltas      []int64
+		expected    int64
+		expectedErr error
+	}{
+		{
+			name:     "should keep exact values near max int64",
+			deltas:   []int64{math.MaxInt64 - 1, 1},
+			expected: math.MaxInt64,
+		},
+		{
+			name:        "should detect overflow",
+			deltas:      []int64{math.MaxInt64, 1},
+			expected:    math.MaxInt64,
+			expectedErr: entities.ErrCounterOverflow,
+		},
+		{
+			name:        "should detect negative overflow",
+			deltas:      []int64{math.MinInt64, -1},
+			expected:    math.MinInt64,
+			expectedErr: entities.ErrCounterOverflow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemStorage()
+
+			var err error
+
+			for _, delta := range tt.deltas {
+				_, err = store.SaveCounterMetric(ctx, "bytes", delta)
+			}
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			val, err := store.GetCounterMetric(ctx, "bytes")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, val)
+		})
+	}
+}
+
+func TestMemStorage_AddGaugeMetric(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemStorage()
+
+	const writers, iterations = 8, 200
+
+	var wg sync.WaitGroup
+
+	// concurrent additions to the same gauge are not lost
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				_, err := store.AddGaugeMetric(ctx, "connections", 1)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	val, err := store.AddGaugeMetric(ctx, "connections", -0.5)
+	require.NoError(t, err)
+	assert.Equal(t, float64(writers*iterations)-0.5, val)
+
+	gauge, err := store.GetGaugeMetric(ctx, "connections")
+	require.NoError(t, err)
+	assert.Equal(t, val, gauge)
+}
+
+func TestMemStorage_DeleteMetric(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		tBody func(store *storage.MemStorage)
+	}{
+		{
+			name: "should delete series with history",
+			tBody: func(store *storage.MemStorage) {
+				_, err := store.SaveGaugeMetric(ctx, "temp", 1.5)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "temp", 1)
+				require.NoError(t, err)
+
+				err = store.DeleteMetric(ctx, constants.MetricTypeGauge, "temp")
+				require.NoError(t, err)
+
+				_, err = store.GetGaugeMetric(ctx, "temp")
+				assert.True(t, storage.IsErrNotFound(err))
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, history)
+
+				// series of other type with the same name is kept
+				val, err := store.GetCounterMetric(ctx, "temp")
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), val)
+			},
+		},
+		{
+			name: "should return not found error for unknown series",
+			tBody: func(store *storage.MemStorage) {
+				err := store.DeleteMetric(ctx, constants.MetricTypeGauge, "unknown")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should delete batch and skip unknown series",
+			tBody: func(store *storage.MemStorage) {
+				_, err := store.SaveGaugeMetric(ctx, `Alloc{host="web01"}`, 1)
+				require.NoError(t, err)
+				_, err = store.SaveGaugeMetric(ctx, `Alloc{host="web02"}`, 2)
+				require.NoError(t, err)
+
+				err = store.DeleteMetricBatch(ctx, []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}},
+					{ID: "unknown", MType: constants.MetricTypeCounter},
+				})
+				require.NoError(t, err)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]float64{`Alloc{host="web02"}`: 2}, metrics.Gauge)
+			},
+		},
+		{
+			name: "should expire series not updated since given time",
+			tBody: func(store *storage.MemStorage) {
+				_, err := store.SaveGaugeMetric(ctx, "stale", 1)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "stale", 1)
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond)
+				updatedBefore := time.Now()
+
+				_, err = store.SaveGaugeMetric(ctx, "fresh", 1)
+				require.NoError(t, err)
+
+				expired, err := store.ExpireMetrics(ctx, constants.MetricTypeGauge, updatedBefore)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"stale"}, expired)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]float64{"fresh": 1}, metrics.Gauge)
+				assert.Equal(t, map[string]int64{"stale": 1}, metrics.Counter)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody(storage.NewMemStorage())
+		})
+	}
+}
+
+func TestMemStorage_SaveMetricBatch(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		tBody func(store *storage.MemStorage)
+	}{
+		{
+			name: "should return resulting values in batch order",
+			tBody: func(store *storage.MemStorage) {
+				_, err := store.SaveCounterMetric(ctx, "PollCount", 10)
+				require.NoError(t, err)
+
+				delta, value := int64(1), 1.5
+				histogram := entities.Histogram{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: 0.5, Count: 1}
+
+				result, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &value, Labels: map[string]string{"host": "web01"}},
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "latency", MType: constants.MetricTypeHistogram, Histogram: &histogram},
+					{ID: "latency", MType: constants.MetricTypeHistogram, Histogram: &histogram},
+				})
+				require.NoError(t, err)
+				require.Len(t, result, 5)
+
+				assert.Equal(t, int64(11), *result[0].Delta)
+				assert.Equal(t, value, *result[1].Value)
+				assert.Equal(t, map[string]string{"host": "web01"}, result[1].Labels)
+				assert.Equal(t, int64(12), *result[2].Delta)
+				assert.Equal(t, int64(1), result[3].Histogram.Count)
+				assert.Equal(t, int64(2), result[4].Histogram.Count)
+			},
+		},
+		{
+			name: "should reject batch with invalid item",
+			tBody: func(store *storage.MemStorage) {
+				value := 1.5
+
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &value},
+					{ID: "PollCount", MType: constants.MetricTypeCounter},
+				})
+
+				var itemErr *storage.ErrBatchItem
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 1, itemErr.Index)
+
+				_, err = store.GetGaugeMetric(ctx, "Alloc")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should save nothing if any item is rejected",
+			tBody: func(store *storage.MemStorage) {
+				_, err := store.SaveCounterMetric(ctx, "bytes", math.MaxInt64-1)
+				require.NoError(t, err)
+
+				one, value := int64(1), 1.5
+				start := time.Now()
+
+				_, err = store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &value},
+					{ID: "bytes", MType: constants.MetricTypeCounter, Delta: &one},
+					{ID: "bytes", MType: constants.MetricTypeCounter, Delta: &one},
+				})
+				assert.ErrorIs(t, err, entities.ErrCounterOverflow)
+
+				var itemErr *storage.ErrBatchItem
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 2, itemErr.Index)
+
+				_, err = store.GetGaugeMetric(ctx, "Alloc")
+				assert.True(t, storage.IsErrNotFound(err))
+
+				val, err := store.GetCounterMetric(ctx, "bytes")
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64-1), val)
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, "bytes", start, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, history)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody(storage.NewMemStorage())
+		})
+	}
+}
+
+func TestMemStorage_Rollups(t *testing.T) {
+	ctx := context.Background()
+	bucket := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		tBody func(store *storage.MemStorage)
+	}{
+		{
+			name: "should replace rollups with same timestamp and return them in range",
+			tBody: func(store *storage.MemStorage) {
+				err := store.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket, Min: 1, Max: 1, Sum: 1, Count: 1},
+					{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 2, Sum: 2, Count: 1},
+					{Timestamp: bucket.Add(2 * time.Minute), Min: 3, Max: 3, Sum: 3, Count: 1},
+				})
+				require.NoError(t, err)
+
+				err = store.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 4, Sum: 6, Count: 2},
+				})
+				require.NoError(t, err)
+
+				rollups, err := store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, bucket.Add(time.Minute), bucket.Add(2*time.Minute))
+				require.NoError(t, err)
+				assert.Equal(t, []entities.Rollup{
+					{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 4, Sum: 6, Count: 2},
+					{Timestamp: bucket.Add(2 * time.Minute), Min: 3, Max: 3, Sum: 3, Count: 1},
+				}, rollups)
+
+				rollups, err = store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Hour, bucket, bucket.Add(time.Hour))
+				require.NoError(t, err)
+				assert.Empty(t, rollups)
+			},
+		},
+		{
+			name: "should delete history of resolution before time",
+			tBody: func(store *storage.MemStorage) {
+				err := store.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket, Min: 1, Max: 1, Sum: 1, Count: 1},
+					{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 2, Sum: 2, Count: 1},
+				})
+				require.NoError(t, err)
+
+				_, err = store.SaveGaugeMetric(ctx, "temp", 1)
+				require.NoError(t, err)
+
+				err = store.DeleteHistory(ctx, time.Minute, bucket.Add(time.Minute))
+				require.NoError(t, err)
+
+				rollups, err := store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, time.Time{}, bucket.Add(time.Hour))
+				require.NoError(t, err)
+				assert.Equal(t, []entities.Rollup{{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 2, Sum: 2, Count: 1}}, rollups)
+
+				// raw samples are kept until raw history is deleted
+				samples, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Len(t, samples, 1)
+
+				err = store.DeleteHistory(ctx, 0, time.Now().Add(time.Second))
+				require.NoError(t, err)
+
+				samples, err = store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, samples)
+
+				val, err := store.GetGaugeMetric(ctx, "temp")
+				require.NoError(t, err)
+				assert.Equal(t, float64(1), val)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tBody(storage.NewMemStorage())
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// lockID is key of postgres advisory lock which keeps concurrently started servers from migrating together
+const lockID = 4_725_110_031
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrNoDownMigration = errors.New("migration has no down script")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Parse reads migrations from files named <version>_<name>.up.sql and <version>_<name>.down.sql
+// in root of fsys and returns them sorted by version
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNameRe.FindStringSubmatch(entry.Name())
+
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s, err: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations %d have different names: %s, %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+
+		result = append(result, *m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// Embedded returns migrations compiled into the binary
+func Embedded() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(sub)
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	logger     logger.ILogger
+	migrations []Migration
+}
+
+// Up applies all migrations which are not applied yet
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn, version int64) error {
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+
+			err := pgx.BeginTxFunc(ctx, conn, pgx.TxOptions{}, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return err
+				}
+
+				_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+
+				return err
+			})
+
+			if err != nil {
+				return fmt.Errorf("error while apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			m.logger.Infow("migration applied", "version", migration.Version, "name", migration.Name)
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back steps latest applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn, version int64) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+
+			if migration.Version > version {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+			}
+
+			err := pgx.BeginTxFunc(ctx, conn, pgx.TxOptions{}, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return err
+				}
+
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+
+				return err
+			})
+
+			if err != nil {
+				return fmt.Errorf("error while rollback migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			m.logger.Infow("migration rolled back", "version", migration.Version, "name", migration.Name)
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Version returns version of the latest applied migration, 0 if nothing is applied
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var result int64
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, version int64) error {
+		result = version
+		return nil
+	})
+
+	return result, err
+}
+
+// withLock runs fn on a single connection holding advisory lock, so migrations of
+// concurrently started servers never interleave
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn, version int64) error) error {
+	conn, err := m.pool.Acquire(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("error while acquire migration lock: %w", err)
+	}
+
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)
+	`)
+
+	if err != nil {
+		return fmt.Errorf("error while create schema_migrations table: %w", err)
+	}
+
+	var version int64
+
+	err = conn.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+
+	if err != nil {
+		return fmt.Errorf("error while get schema version: %w", err)
+	}
+
+	return fn(conn, version)
+}
+
+func New(pool *pgxpool.Pool, logger logger.ILogger) (*Migrator, error) {
+	migrations, err := Embedded()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{pool, logger, migrations}, nil
+}
@@ -0,0 +1,75 @@
+package migrations_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/sodiqit/metricpulse.git/internal/server/storage/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expected    []migrations.Migration
+		expectedErr string
+	}{
+		{
+			name: "should pair up and down scripts and sort by version",
+			files: fstest.MapFS{
+				"0002_add_labels.up.sql":      {Data: []byte("ALTER 2")},
+				"0001_create_metric.up.sql":   {Data: []byte("CREATE 1")},
+				"0001_create_metric.down.sql": {Data: []byte("DROP 1")},
+			},
+			expected: []migrations.Migration{
+				{Version: 1, Name: "create_metric", Up: "CREATE 1", Down: "DROP 1"},
+				{Version: 2, Name: "add_labels", Up: "ALTER 2"},
+			},
+		},
+		{
+			name:        "should fail on invalid file name",
+			files:       fstest.MapFS{"create_metric.sql": {Data: []byte("CREATE")}},
+			expectedErr: "invalid migration file name: create_metric.sql",
+		},
+		{
+			name:        "should fail without up script",
+			files:       fstest.MapFS{"0001_create_metric.down.sql": {Data: []byte("DROP")}},
+			expectedErr: "migration 1_create_metric has no up script",
+		},
+		{
+			name: "should fail on different names of one version",
+			files: fstest.MapFS{
+				"0001_create_metric.up.sql":  {Data: []byte("CREATE")},
+				"0001_create_table.down.sql": {Data: []byte("DROP")},
+			},
+			expectedErr: "migrations 1 have different names",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := migrations.Parse(tt.files)
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	result, err := migrations.Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, result)
+
+	for i, migration := range result {
+		assert.Equal(t, int64(i+1), migration.Version, "versions must go without gaps")
+		assert.NotEmpty(t, migration.Down, "migration %d_%s has no down script", migration.Version, migration.Name)
+	}
+}
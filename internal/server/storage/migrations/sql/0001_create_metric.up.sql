@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS metric (
+    id serial PRIMARY KEY,
+    type varchar(128) NOT NULL,
+    name varchar(128) NOT NULL,
+    value double precision NOT NULL
+);
+
+-- databases created before migrations may already keep several labeled series per name
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'metric' AND column_name = 'labels') THEN
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_type_name ON metric(type, name);
+    END IF;
+END $$;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS metric_history (
+    id bigserial PRIMARY KEY,
+    type varchar(128) NOT NULL,
+    name varchar(128) NOT NULL,
+    value double precision NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_type_name_created_at ON metric_history(type, name, created_at);
@@ -0,0 +1,16 @@
+-- series with labels can't be represented without them
+DELETE FROM metric WHERE labels <> '{}';
+
+DELETE FROM metric_history WHERE labels <> '{}';
+
+DROP INDEX IF EXISTS idx_type_name_labels;
+
+DROP INDEX IF EXISTS idx_history_type_name_labels_created_at;
+
+ALTER TABLE metric DROP COLUMN IF EXISTS labels;
+
+ALTER TABLE metric_history DROP COLUMN IF EXISTS labels;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_type_name ON metric(type, name);
+
+CREATE INDEX IF NOT EXISTS idx_history_type_name_created_at ON metric_history(type, name, created_at);
@@ -0,0 +1,11 @@
+ALTER TABLE metric ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+
+DROP INDEX IF EXISTS idx_type_name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_type_name_labels ON metric(type, name, labels);
+
+ALTER TABLE metric_history ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+
+DROP INDEX IF EXISTS idx_history_type_name_created_at;
+
+CREATE INDEX IF NOT EXISTS idx_history_type_name_labels_created_at ON metric_history(type, name, labels, created_at);
@@ -0,0 +1,7 @@
+DELETE FROM metric WHERE type = 'histogram';
+
+DELETE FROM metric_history WHERE type = 'histogram';
+
+ALTER TABLE metric DROP COLUMN IF EXISTS histogram;
+
+ALTER TABLE metric_history DROP COLUMN IF EXISTS histogram;
@@ -0,0 +1,3 @@
+ALTER TABLE metric ADD COLUMN IF NOT EXISTS histogram jsonb;
+
+ALTER TABLE metric_history ADD COLUMN IF NOT EXISTS histogram jsonb;
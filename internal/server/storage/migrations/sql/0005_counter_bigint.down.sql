@@ -0,0 +1,11 @@
+UPDATE metric SET value = counter WHERE type = 'counter';
+
+ALTER TABLE metric DROP COLUMN IF EXISTS counter;
+
+ALTER TABLE metric ALTER COLUMN value SET NOT NULL;
+
+UPDATE metric_history SET value = counter WHERE type = 'counter';
+
+ALTER TABLE metric_history DROP COLUMN IF EXISTS counter;
+
+ALTER TABLE metric_history ALTER COLUMN value SET NOT NULL;
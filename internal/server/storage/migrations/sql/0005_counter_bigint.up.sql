@@ -0,0 +1,26 @@
+-- counters are kept in bigint column, value column is left for gauges and histogram counts
+ALTER TABLE metric ADD COLUMN IF NOT EXISTS counter bigint;
+
+ALTER TABLE metric ALTER COLUMN value DROP NOT NULL;
+
+UPDATE metric
+SET counter = CASE
+        WHEN value >= 9223372036854775807 THEN 9223372036854775807
+        WHEN value <= -9223372036854775808 THEN -9223372036854775808
+        ELSE round(value)::bigint
+    END,
+    value = NULL
+WHERE type = 'counter';
+
+ALTER TABLE metric_history ADD COLUMN IF NOT EXISTS counter bigint;
+
+ALTER TABLE metric_history ALTER COLUMN value DROP NOT NULL;
+
+UPDATE metric_history
+SET counter = CASE
+        WHEN value >= 9223372036854775807 THEN 9223372036854775807
+        WHEN value <= -9223372036854775808 THEN -9223372036854775808
+        ELSE round(value)::bigint
+    END,
+    value = NULL
+WHERE type = 'counter';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_type_updated_at;
+
+ALTER TABLE metric DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,3 @@
+ALTER TABLE metric ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_type_updated_at ON metric(type, updated_at);
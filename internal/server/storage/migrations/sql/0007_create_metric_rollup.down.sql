@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_history_created_at;
+
+DROP TABLE IF EXISTS metric_rollup;
@@ -0,0 +1,17 @@
+-- downsampled history: resolution is bucket size in seconds, ts is bucket start
+CREATE TABLE IF NOT EXISTS metric_rollup (
+    type varchar(128) NOT NULL,
+    name varchar(128) NOT NULL,
+    labels jsonb NOT NULL DEFAULT '{}',
+    resolution bigint NOT NULL,
+    ts timestamptz NOT NULL,
+    min double precision NOT NULL,
+    max double precision NOT NULL,
+    sum double precision NOT NULL,
+    count bigint NOT NULL,
+    PRIMARY KEY (type, name, labels, resolution, ts)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rollup_resolution_ts ON metric_rollup(resolution, ts);
+
+CREATE INDEX IF NOT EXISTS idx_history_created_at ON metric_history(created_at);
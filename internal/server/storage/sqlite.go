@@ -0,0 +1,813 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/pkg/retry"
+
+	// registers "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order, number of applied ones is kept in user_version pragma.
+// Labels are stored as json with sorted keys, so equal label sets are equal strings;
+// timestamps are unix nanoseconds
+var sqliteMigrations = []string{`
+	CREATE TABLE IF NOT EXISTS metric (
+		id INTEGER PRIMARY KEY,
+		type TEXT NOT NULL,
+		name TEXT NOT NULL,
+		labels TEXT NOT NULL DEFAULT '{}',
+		value REAL,
+		counter INTEGER,
+		histogram TEXT,
+		UNIQUE(type, name, labels)
+	);
+
+	CREATE TABLE IF NOT EXISTS metric_history (
+		id INTEGER PRIMARY KEY,
+		type TEXT NOT NULL,
+		name TEXT NOT NULL,
+		labels TEXT NOT NULL DEFAULT '{}',
+		value REAL,
+		counter INTEGER,
+		histogram TEXT,
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_history_type_name_labels_created_at ON metric_history(type, name, labels, created_at);
+`, `
+	ALTER TABLE metric ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0;
+
+	UPDATE metric SET updated_at = CAST(strftime('%s', 'now') AS INTEGER) * 1000000000;
+
+	CREATE INDEX idx_type_updated_at ON metric(type, updated_at);
+`, `
+	CREATE TABLE metric_rollup (
+		type TEXT NOT NULL,
+		name TEXT NOT NULL,
+		labels TEXT NOT NULL DEFAULT '{}',
+		resolution INTEGER NOT NULL,
+		ts INTEGER NOT NULL,
+		min REAL NOT NULL,
+		max REAL NOT NULL,
+		sum REAL NOT NULL,
+		count INTEGER NOT NULL,
+		PRIMARY KEY (type, name, labels, resolution, ts)
+	);
+
+	CREATE INDEX idx_rollup_resolution_ts ON metric_rollup(resolution, ts);
+
+	CREATE INDEX idx_history_created_at ON metric_history(created_at);
+`}
+
+var sqliteUpsertMetricQuery = `
+	INSERT INTO metric
+		(type, name, labels, value, counter, histogram, updated_at)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(type, name, labels) DO UPDATE
+	SET value = excluded.value, counter = excluded.counter, histogram = excluded.histogram, updated_at = excluded.updated_at
+`
+
+var sqliteInsertHistoryQuery = `
+	INSERT INTO metric_history
+		(type, name, labels, value, counter, histogram, created_at)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?)
+`
+
+var sqliteDeleteMetricQuery = `DELETE FROM metric WHERE type = ? AND name = ? AND labels = ?`
+
+var sqliteDeleteHistoryQuery = `DELETE FROM metric_history WHERE type = ? AND name = ? AND labels = ?`
+
+var sqliteDeleteRollupsQuery = `DELETE FROM metric_rollup WHERE type = ? AND name = ? AND labels = ?`
+
+var sqliteExpireMetricsQuery = `DELETE FROM metric WHERE type = ? AND updated_at < ? RETURNING name, labels`
+
+var sqliteSelectMetricQuery = `SELECT value, counter, histogram FROM metric WHERE type = ? AND name = ? AND labels = ?`
+
+var sqliteSelectAllMetricsQuery = `SELECT type, name, labels, value, counter, histogram FROM metric`
+
+var sqliteSelectRollupsQuery = `
+	SELECT ts, min, max, sum, count FROM metric_rollup
+	WHERE type = ? AND name = ? AND labels = ? AND resolution = ? AND ts BETWEEN ? AND ?
+	ORDER BY ts
+`
+
+var sqliteUpsertRollupQuery = `
+	INSERT INTO metric_rollup
+		(type, name, labels, resolution, ts, min, max, sum, count)
+	VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(type, name, labels, resolution, ts) DO UPDATE
+	SET min = excluded.min, max = excluded.max, sum = excluded.sum, count = excluded.count
+`
+
+var sqliteDeleteOldHistoryQuery = `DELETE FROM metric_history WHERE created_at < ?`
+
+var sqliteDeleteOldRollupsQuery = `DELETE FROM metric_rollup WHERE resolution = ? AND ts < ?`
+
+var sqliteSelectMetricHistoryQuery = `
+	SELECT created_at, value, counter, histogram FROM metric_history
+	WHERE type = ? AND name = ? AND labels = ? AND created_at BETWEEN ? AND ?
+	ORDER BY created_at, id
+`
+
+// sqliteRow is value columns of metric and metric_history tables
+type sqliteRow struct {
+	Value     sql.NullFloat64
+	Counter   sql.NullInt64
+	Histogram sql.NullString
+}
+
+func (r sqliteRow) histogram() (*entities.Histogram, error) {
+	if !r.Histogram.Valid {
+		return nil, nil
+	}
+
+	var result entities.Histogram
+
+	if err := json.Unmarshal([]byte(r.Histogram.String), &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SQLiteStorage keeps metrics in embedded sqlite database file. Every update runs in immediate
+// transaction: current value is read, updated in application code and written back together with history
+type SQLiteStorage struct {
+	cfg    *config.Config
+	logger logger.ILogger
+	db     *sql.DB
+}
+
+func (s *SQLiteStorage) SaveGaugeMetric(ctx context.Context, metricType string, value float64) (float64, error) {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		return s.save(ctx, tx, constants.MetricTypeGauge, metricType, sqliteRow{Value: sql.NullFloat64{Float64: value, Valid: true}})
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error while save gauge metric; metricName: %s, metricValue: %f, err: %w", metricType, value, err)
+	}
+
+	return value, nil
+}
+
+func (s *SQLiteStorage) AddGaugeMetric(ctx context.Context, metricType string, delta float64) (float64, error) {
+	var result float64
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.get(ctx, tx, constants.MetricTypeGauge, metricType)
+
+		if err != nil && !IsErrNotFound(err) {
+			return err
+		}
+
+		result = current.Value.Float64 + delta
+
+		return s.save(ctx, tx, constants.MetricTypeGauge, metricType, sqliteRow{Value: sql.NullFloat64{Float64: result, Valid: true}})
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error while add gauge metric; metricName: %s, delta: %f, err: %w", metricType, delta, err)
+	}
+
+	return result, nil
+}
+
+func (s *SQLiteStorage) SaveCounterMetric(ctx context.Context, metricType string, value int64) (int64, error) {
+	var result int64
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		result, err = s.saveCounter(ctx, tx, metricType, value)
+
+		return err
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error while save counter metric; metricName: %s, metricValue: %d, err: %w", metricType, value, err)
+	}
+
+	return result, nil
+}
+
+func (s *SQLiteStorage) SaveHistogramMetric(ctx context.Context, metricType string, value entities.Histogram) (entities.Histogram, error) {
+	var result entities.Histogram
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		result, err = s.saveHistogram(ctx, tx, metricType, value)
+
+		return err
+	})
+
+	if err != nil {
+		return entities.Histogram{}, fmt.Errorf("error while save histogram metric; metricName: %s, err: %w", metricType, err)
+	}
+
+	return result, nil
+}
+
+// SaveMetricBatch saves all metrics in one transaction, so rejected item rolls back the whole batch
+func (s *SQLiteStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) ([]entities.Metrics, error) {
+	if err := validateBatch(metrics); err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.Metrics, len(metrics))
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		for i, metric := range metrics {
+			var sample entities.MetricSample
+			var err error
+
+			switch metric.MType {
+			case constants.MetricTypeGauge:
+				sample.Gauge = *metric.Value
+				err = s.save(ctx, tx, metric.MType, metric.SeriesID(), sqliteRow{Value: sql.NullFloat64{Float64: sample.Gauge, Valid: true}})
+			case constants.MetricTypeCounter:
+				sample.Counter, err = s.saveCounter(ctx, tx, metric.SeriesID(), *metric.Delta)
+			case constants.MetricTypeHistogram:
+				var val entities.Histogram
+				val, err = s.saveHistogram(ctx, tx, metric.SeriesID(), *metric.Histogram)
+				sample.Histogram = &val
+			}
+
+			if errors.Is(err, entities.ErrCounterOverflow) || errors.Is(err, entities.ErrHistogramBoundsMismatch) {
+				return NewErrBatchItem(i, err)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			result[i] = batchResult(metric, sample)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while save metrics batch; err: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *SQLiteStorage) DeleteMetric(ctx context.Context, metricType string, metricName string) error {
+	var deleted bool
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		deleted, err = s.delete(ctx, tx, metricType, metricName)
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while delete metric; metricName: %s, err: %w", metricName, err)
+	}
+
+	if !deleted {
+		return NewErrNotFound(sql.ErrNoRows, map[string]interface{}{"metricType": metricType, "metricName": metricName})
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, metric := range metrics {
+			if _, err := s.delete(ctx, tx, metric.MType, metric.SeriesID()); err != nil {
+				return fmt.Errorf("error while delete metrics batch; metricName: %s, err: %w", metric.SeriesID(), err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLiteStorage) ExpireMetrics(ctx context.Context, metricType string, updatedBefore time.Time) ([]string, error) {
+	var expired []string
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, sqliteExpireMetricsQuery, metricType, updatedBefore.UnixNano())
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		var series [][2]string
+
+		for rows.Next() {
+			var name, labels string
+
+			if err := rows.Scan(&name, &labels); err != nil {
+				return err
+			}
+
+			series = append(series, [2]string{name, labels})
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, item := range series {
+			name, rawLabels := item[0], item[1]
+
+			if err := s.deleteHistory(ctx, tx, metricType, name, rawLabels); err != nil {
+				return err
+			}
+
+			var labels map[string]string
+
+			if err := json.Unmarshal([]byte(rawLabels), &labels); err != nil {
+				return err
+			}
+
+			expired = append(expired, entities.SeriesID(name, labels))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while expire metrics; metricType: %s, err: %w", metricType, err)
+	}
+
+	return expired, nil
+}
+
+func (s *SQLiteStorage) GetGaugeMetric(ctx context.Context, metricName string) (float64, error) {
+	row, err := s.get(ctx, s.db, constants.MetricTypeGauge, metricName)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return row.Value.Float64, nil
+}
+
+func (s *SQLiteStorage) GetCounterMetric(ctx context.Context, metricName string) (int64, error) {
+	row, err := s.get(ctx, s.db, constants.MetricTypeCounter, metricName)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return row.Counter.Int64, nil
+}
+
+func (s *SQLiteStorage) GetHistogramMetric(ctx context.Context, metricName string) (entities.Histogram, error) {
+	row, err := s.get(ctx, s.db, constants.MetricTypeHistogram, metricName)
+
+	if err != nil {
+		return entities.Histogram{}, err
+	}
+
+	histogram, err := row.histogram()
+
+	if err != nil || histogram == nil {
+		return entities.Histogram{}, err
+	}
+
+	return *histogram, nil
+}
+
+func (s *SQLiteStorage) GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error) {
+	if s.db == nil {
+		return entities.TotalMetrics{}, ErrNotConnection
+	}
+
+	rows, err := s.db.QueryContext(ctx, sqliteSelectAllMetricsQuery)
+
+	if err != nil {
+		return entities.TotalMetrics{}, fmt.Errorf("error while get metrics; err: %w", err)
+	}
+
+	defer rows.Close()
+
+	result := entities.TotalMetrics{Gauge: make(map[string]float64), Counter: make(map[string]int64), Histogram: make(map[string]entities.Histogram)}
+
+	for rows.Next() {
+		var metricType, name, rawLabels string
+		var row sqliteRow
+
+		if err := rows.Scan(&metricType, &name, &rawLabels, &row.Value, &row.Counter, &row.Histogram); err != nil {
+			return entities.TotalMetrics{}, fmt.Errorf("error while get metrics; err: %w", err)
+		}
+
+		var labels map[string]string
+
+		if err := json.Unmarshal([]byte(rawLabels), &labels); err != nil {
+			return entities.TotalMetrics{}, fmt.Errorf("error while get metrics; err: %w", err)
+		}
+
+		seriesID := entities.SeriesID(name, labels)
+
+		switch metricType {
+		case constants.MetricTypeGauge:
+			result.Gauge[seriesID] = row.Value.Float64
+		case constants.MetricTypeHistogram:
+			histogram, err := row.histogram()
+
+			if err != nil {
+				return entities.TotalMetrics{}, fmt.Errorf("error while get metrics; err: %w", err)
+			}
+
+			if histogram != nil {
+				result.Histogram[seriesID] = *histogram
+			}
+		default:
+			result.Counter[seriesID] = row.Counter.Int64
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func (s *SQLiteStorage) GetMetricHistory(ctx context.Context, metricType string, metricName string, from time.Time, to time.Time) ([]entities.MetricSample, error) {
+	if s.db == nil {
+		return nil, ErrNotConnection
+	}
+
+	name, labels := sqliteSeriesArgs(metricName)
+
+	rows, err := s.db.QueryContext(ctx, sqliteSelectMetricHistoryQuery, metricType, name, labels, from.UnixNano(), to.UnixNano())
+
+	if err != nil {
+		return nil, fmt.Errorf("error while get metric history; metricName: %s, err: %w", metricName, err)
+	}
+
+	defer rows.Close()
+
+	result := []entities.MetricSample{}
+
+	for rows.Next() {
+		var createdAt int64
+		var row sqliteRow
+
+		if err := rows.Scan(&createdAt, &row.Value, &row.Counter, &row.Histogram); err != nil {
+			return nil, fmt.Errorf("error while get metric history; metricName: %s, err: %w", metricName, err)
+		}
+
+		histogram, err := row.histogram()
+
+		if err != nil {
+			return nil, fmt.Errorf("error while get metric history; metricName: %s, err: %w", metricName, err)
+		}
+
+		result = append(result, entities.MetricSample{
+			Timestamp: time.Unix(0, createdAt),
+			Gauge:     row.Value.Float64,
+			Counter:   row.Counter.Int64,
+			Histogram: histogram,
+		})
+	}
+
+	return result, rows.Err()
+}
+
+func (s *SQLiteStorage) GetRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, from time.Time, to time.Time) ([]entities.Rollup, error) {
+	if s.db == nil {
+		return nil, ErrNotConnection
+	}
+
+	name, labels := sqliteSeriesArgs(metricName)
+
+	rows, err := s.db.QueryContext(ctx, sqliteSelectRollupsQuery, metricType, name, labels, int64(resolution.Seconds()), from.UnixNano(), to.UnixNano())
+
+	if err != nil {
+		return nil, fmt.Errorf("error while get metric rollups; metricName: %s, err: %w", metricName, err)
+	}
+
+	defer rows.Close()
+
+	result := []entities.Rollup{}
+
+	for rows.Next() {
+		var ts int64
+		var rollup entities.Rollup
+
+		if err := rows.Scan(&ts, &rollup.Min, &rollup.Max, &rollup.Sum, &rollup.Count); err != nil {
+			return nil, fmt.Errorf("error while get metric rollups; metricName: %s, err: %w", metricName, err)
+		}
+
+		rollup.Timestamp = time.Unix(0, ts)
+		result = append(result, rollup)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *SQLiteStorage) SaveRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, rollups []entities.Rollup) error {
+	name, labels := sqliteSeriesArgs(metricName)
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, rollup := range rollups {
+			_, err := tx.ExecContext(ctx, sqliteUpsertRollupQuery, metricType, name, labels, int64(resolution.Seconds()),
+				rollup.Timestamp.UnixNano(), rollup.Min, rollup.Max, rollup.Sum, rollup.Count)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while save metric rollups; metricName: %s, err: %w", metricName, err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteHistory(ctx context.Context, resolution time.Duration, before time.Time) error {
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		if resolution == 0 {
+			_, err = tx.ExecContext(ctx, sqliteDeleteOldHistoryQuery, before.UnixNano())
+		} else {
+			_, err = tx.ExecContext(ctx, sqliteDeleteOldRollupsQuery, int64(resolution.Seconds()), before.UnixNano())
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while delete history; resolution: %s, err: %w", resolution, err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) Init(ctx context.Context, backoff retry.Backoff) error {
+	// writers wait for each other instead of failing with SQLITE_BUSY, readers don't block writers in wal mode
+	dsn := fmt.Sprintf("file:%s?_txlock=immediate&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", s.cfg.SQLitePath)
+
+	db, err := sql.Open("sqlite", dsn)
+
+	if err != nil {
+		return err
+	}
+
+	err = retry.Do(ctx, backoff, func(ctx context.Context) error {
+		s.logger.Infow("try open sqlite database", "path", s.cfg.SQLitePath)
+
+		if err := db.PingContext(ctx); err != nil {
+			return retry.RetryableError(err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	if err := migrateSQLite(ctx, db); err != nil {
+		db.Close()
+		return fmt.Errorf("error while migrate sqlite schema: %w", err)
+	}
+
+	s.db = db
+
+	s.logger.Infow("success open sqlite database", "path", s.cfg.SQLitePath)
+
+	return nil
+}
+
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return ErrNotConnection
+	}
+
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStorage) Close(ctx context.Context) error {
+	if s.db == nil {
+		return ErrNotConnection
+	}
+
+	return s.db.Close()
+}
+
+func NewSQLiteStorage(cfg *config.Config, logger logger.ILogger) *SQLiteStorage {
+	return &SQLiteStorage{cfg, logger, nil}
+}
+
+func (s *SQLiteStorage) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if s.db == nil {
+		return ErrNotConnection
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type sqliteQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *SQLiteStorage) get(ctx context.Context, q sqliteQuerier, metricType string, metricName string) (sqliteRow, error) {
+	if s.db == nil {
+		return sqliteRow{}, ErrNotConnection
+	}
+
+	name, labels := sqliteSeriesArgs(metricName)
+
+	var row sqliteRow
+
+	err := q.QueryRowContext(ctx, sqliteSelectMetricQuery, metricType, name, labels).Scan(&row.Value, &row.Counter, &row.Histogram)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return row, NewErrNotFound(err, map[string]interface{}{"metricName": metricName})
+	}
+
+	if err != nil {
+		return row, fmt.Errorf("error while get %s metric; metricName: %s, err: %w", metricType, metricName, err)
+	}
+
+	return row, nil
+}
+
+// delete removes the series with its history. Returns false if series doesn't exist
+func (s *SQLiteStorage) delete(ctx context.Context, tx *sql.Tx, metricType string, metricName string) (bool, error) {
+	name, labels := sqliteSeriesArgs(metricName)
+
+	res, err := tx.ExecContext(ctx, sqliteDeleteMetricQuery, metricType, name, labels)
+
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, s.deleteHistory(ctx, tx, metricType, name, labels)
+}
+
+// deleteHistory removes history and rollups of the series
+func (s *SQLiteStorage) deleteHistory(ctx context.Context, tx *sql.Tx, metricType string, name string, labels string) error {
+	if _, err := tx.ExecContext(ctx, sqliteDeleteHistoryQuery, metricType, name, labels); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, sqliteDeleteRollupsQuery, metricType, name, labels)
+
+	return err
+}
+
+// save upserts the series value and appends it to history
+func (s *SQLiteStorage) save(ctx context.Context, tx *sql.Tx, metricType string, metricName string, row sqliteRow) error {
+	name, labels := sqliteSeriesArgs(metricName)
+
+	now := time.Now().UnixNano()
+
+	_, err := tx.ExecContext(ctx, sqliteUpsertMetricQuery, metricType, name, labels, row.Value, row.Counter, row.Histogram, now)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, sqliteInsertHistoryQuery, metricType, name, labels, row.Value, row.Counter, row.Histogram, now)
+
+	return err
+}
+
+// saveCounter adds delta in application code: sqlite silently turns overflowed integers into floats
+func (s *SQLiteStorage) saveCounter(ctx context.Context, tx *sql.Tx, metricName string, delta int64) (int64, error) {
+	current, err := s.get(ctx, tx, constants.MetricTypeCounter, metricName)
+
+	if err != nil && !IsErrNotFound(err) {
+		return 0, err
+	}
+
+	result, err := entities.AddCounter(current.Counter.Int64, delta)
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.save(ctx, tx, constants.MetricTypeCounter, metricName, sqliteRow{Counter: sql.NullInt64{Int64: result, Valid: true}})
+
+	return result, err
+}
+
+// saveHistogram merges value into the stored histogram and saves the result; value column keeps count of observations
+func (s *SQLiteStorage) saveHistogram(ctx context.Context, tx *sql.Tx, metricName string, value entities.Histogram) (entities.Histogram, error) {
+	current, err := s.get(ctx, tx, constants.MetricTypeHistogram, metricName)
+
+	if err != nil && !IsErrNotFound(err) {
+		return entities.Histogram{}, err
+	}
+
+	histogram, err := current.histogram()
+
+	if err != nil {
+		return entities.Histogram{}, err
+	}
+
+	result := value
+
+	if histogram != nil {
+		result, err = histogram.Merge(value)
+
+		if err != nil {
+			return entities.Histogram{}, err
+		}
+	}
+
+	data, err := json.Marshal(result)
+
+	if err != nil {
+		return entities.Histogram{}, err
+	}
+
+	err = s.save(ctx, tx, constants.MetricTypeHistogram, metricName, sqliteRow{
+		Value:     sql.NullFloat64{Float64: float64(result.Count), Valid: true},
+		Histogram: sql.NullString{String: string(data), Valid: true},
+	})
+
+	return result, err
+}
+
+// migrateSQLite applies migrations which are not applied yet in one transaction
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var version int
+
+	if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+
+	// pragma doesn't accept query parameters
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(sqliteMigrations))); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// sqliteSeriesArgs splits series id into name and labels json. json.Marshal sorts map keys,
+// so the same labels always give the same string
+func sqliteSeriesArgs(seriesID string) (string, string) {
+	name, labels := entities.ParseSeriesID(seriesID)
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	data, _ := json.Marshal(labels)
+
+	return name, string(data)
+}
@@ -0,0 +1,392 @@
+package storage_test
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/internal/constants"
+	"github.com/sodiqit/metricpulse.git/internal/entities"
+	"github.com/sodiqit/metricpulse.git/internal/logger"
+	"github.com/sodiqit/metricpulse.git/internal/server/config"
+	"github.com/sodiqit/metricpulse.git/internal/server/storage"
+	"github.com/sodiqit/metricpulse.git/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLiteStorage(t *testing.T, path string) *storage.SQLiteStorage {
+	logger, err := logger.Initialize("error")
+	require.NoError(t, err)
+
+	store := storage.NewSQLiteStorage(&config.Config{SQLitePath: path}, logger)
+
+	err = store.Init(context.Background(), retry.EmptyBackoff)
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		tBody func(store *storage.SQLiteStorage)
+	}{
+		{
+			name: "should replace gauge and add counter",
+			tBody: func(store *storage.SQLiteStorage) {
+				start := time.Now()
+
+				_, err := store.SaveGaugeMetric(ctx, "temp", 1.5)
+				require.NoError(t, err)
+				val, err := store.SaveGaugeMetric(ctx, "temp", 2.5)
+				require.NoError(t, err)
+				assert.Equal(t, 2.5, val)
+
+				_, err = store.SaveCounterMetric(ctx, "temp", 2)
+				require.NoError(t, err)
+				counter, err := store.SaveCounterMetric(ctx, "temp", 3)
+				require.NoError(t, err)
+				assert.Equal(t, int64(5), counter)
+
+				gauge, err := store.GetGaugeMetric(ctx, "temp")
+				require.NoError(t, err)
+				assert.Equal(t, 2.5, gauge)
+
+				counter, err = store.GetCounterMetric(ctx, "temp")
+				require.NoError(t, err)
+				assert.Equal(t, int64(5), counter)
+
+				gaugeHistory, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", start, time.Now())
+				require.NoError(t, err)
+				gauges, _ := samplesValues(gaugeHistory)
+				assert.Equal(t, []float64{1.5, 2.5}, gauges)
+
+				counterHistory, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, "temp", start, time.Now())
+				require.NoError(t, err)
+				_, counters := samplesValues(counterHistory)
+				assert.Equal(t, []int64{2, 5}, counters)
+			},
+		},
+		{
+			name: "should add delta to gauge",
+			tBody: func(store *storage.SQLiteStorage) {
+				val, err := store.AddGaugeMetric(ctx, "connections", 2)
+				require.NoError(t, err)
+				assert.Equal(t, 2.0, val)
+
+				val, err = store.AddGaugeMetric(ctx, "connections", -0.5)
+				require.NoError(t, err)
+				assert.Equal(t, 1.5, val)
+
+				gauge, err := store.GetGaugeMetric(ctx, "connections")
+				require.NoError(t, err)
+				assert.Equal(t, 1.5, gauge)
+			},
+		},
+		{
+			name: "should return not found error for unknown metric",
+			tBody: func(store *storage.SQLiteStorage) {
+				_, err := store.GetGaugeMetric(ctx, "unknown")
+				assert.True(t, storage.IsErrNotFound(err))
+
+				_, err = store.GetCounterMetric(ctx, "unknown")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should keep separate series for different labels",
+			tBody: func(store *storage.SQLiteStorage) {
+				web01, web02 := 1.0, 2.0
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &web01, Labels: map[string]string{"host": "web01", "env": "prod"}},
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Value: &web02, Labels: map[string]string{"env": "prod", "host": "web02"}},
+				})
+				require.NoError(t, err)
+
+				val, err := store.GetGaugeMetric(ctx, `Alloc{env="prod",host="web01"}`)
+				require.NoError(t, err)
+				assert.Equal(t, web01, val)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]float64{`Alloc{env="prod",host="web01"}`: web01, `Alloc{env="prod",host="web02"}`: web02}, metrics.Gauge)
+
+				_, err = store.GetGaugeMetric(ctx, "Alloc")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should merge histogram updates",
+			tBody: func(store *storage.SQLiteStorage) {
+				first := entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{1, 0, 0}, Sum: 0.05, Count: 1}
+				second := entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{0, 1, 1}, Sum: 2.5, Count: 2}
+
+				_, err := store.SaveHistogramMetric(ctx, "latency", first)
+				require.NoError(t, err)
+
+				merged, err := store.SaveHistogramMetric(ctx, "latency", second)
+				require.NoError(t, err)
+				assert.Equal(t, entities.Histogram{Bounds: []float64{0.1, 1}, Counts: []int64{1, 1, 1}, Sum: 2.55, Count: 3}, merged)
+
+				_, err = store.SaveHistogramMetric(ctx, "latency", entities.Histogram{Bounds: []float64{1}, Counts: []int64{1, 0}, Count: 1})
+				assert.ErrorIs(t, err, entities.ErrHistogramBoundsMismatch)
+
+				val, err := store.GetHistogramMetric(ctx, "latency")
+				require.NoError(t, err)
+				assert.Equal(t, merged, val)
+			},
+		},
+		{
+			name: "should keep exact counter values near max int64 and detect overflow",
+			tBody: func(store *storage.SQLiteStorage) {
+				_, err := store.SaveCounterMetric(ctx, "bytes", math.MaxInt64-1)
+				require.NoError(t, err)
+
+				val, err := store.SaveCounterMetric(ctx, "bytes", 1)
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64), val)
+
+				_, err = store.SaveCounterMetric(ctx, "bytes", 1)
+				assert.ErrorIs(t, err, entities.ErrCounterOverflow)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, int64(math.MaxInt64), metrics.Counter["bytes"])
+			},
+		},
+		{
+			name: "should roll back whole batch on error",
+			tBody: func(store *storage.SQLiteStorage) {
+				maxDelta, one, gauge := int64(math.MaxInt64), int64(1), 1.5
+
+				_, err := store.SaveCounterMetric(ctx, "bytes", maxDelta)
+				require.NoError(t, err)
+
+				_, err = store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "temp", MType: constants.MetricTypeGauge, Value: &gauge},
+					{ID: "bytes", MType: constants.MetricTypeCounter, Delta: &one},
+				})
+				assert.ErrorIs(t, err, entities.ErrCounterOverflow)
+
+				var itemErr *storage.ErrBatchItem
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 1, itemErr.Index)
+
+				_, err = store.GetGaugeMetric(ctx, "temp")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should return resulting values of batch",
+			tBody: func(store *storage.SQLiteStorage) {
+				delta, value := int64(2), 1.5
+				histogram := entities.Histogram{Bounds: []float64{1}, Counts: []int64{1, 0}, Sum: 0.5, Count: 1}
+
+				result, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+					{ID: "temp", MType: constants.MetricTypeGauge, Value: &value},
+					{ID: "latency", MType: constants.MetricTypeHistogram, Histogram: &histogram},
+				})
+				require.NoError(t, err)
+				require.Len(t, result, 4)
+
+				assert.Equal(t, int64(2), *result[0].Delta)
+				assert.Equal(t, int64(4), *result[1].Delta)
+				assert.Equal(t, value, *result[2].Value)
+				assert.Equal(t, histogram, *result[3].Histogram)
+			},
+		},
+		{
+			name: "should reject batch with invalid item",
+			tBody: func(store *storage.SQLiteStorage) {
+				value := 1.5
+
+				_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+					{ID: "temp", MType: constants.MetricTypeGauge, Value: &value},
+					{ID: "temp", MType: "unknown", Value: &value},
+				})
+
+				var itemErr *storage.ErrBatchItem
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 1, itemErr.Index)
+
+				_, err = store.GetGaugeMetric(ctx, "temp")
+				assert.True(t, storage.IsErrNotFound(err))
+			},
+		},
+		{
+			name: "should delete series with history",
+			tBody: func(store *storage.SQLiteStorage) {
+				_, err := store.SaveGaugeMetric(ctx, `Alloc{host="web01"}`, 1)
+				require.NoError(t, err)
+				_, err = store.SaveGaugeMetric(ctx, `Alloc{host="web02"}`, 2)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "PollCount", 1)
+				require.NoError(t, err)
+
+				err = store.DeleteMetric(ctx, constants.MetricTypeCounter, "PollCount")
+				require.NoError(t, err)
+
+				err = store.DeleteMetric(ctx, constants.MetricTypeCounter, "PollCount")
+				assert.True(t, storage.IsErrNotFound(err))
+
+				err = store.DeleteMetricBatch(ctx, []entities.Metrics{
+					{ID: "Alloc", MType: constants.MetricTypeGauge, Labels: map[string]string{"host": "web01"}},
+					{ID: "unknown", MType: constants.MetricTypeGauge},
+				})
+				require.NoError(t, err)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]float64{`Alloc{host="web02"}`: 2}, metrics.Gauge)
+				assert.Empty(t, metrics.Counter)
+
+				history, err := store.GetMetricHistory(ctx, constants.MetricTypeCounter, "PollCount", time.Time{}, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, history)
+			},
+		},
+		{
+			name: "should expire series not updated since given time",
+			tBody: func(store *storage.SQLiteStorage) {
+				_, err := store.SaveGaugeMetric(ctx, `stale{host="web01"}`, 1)
+				require.NoError(t, err)
+				_, err = store.SaveCounterMetric(ctx, "stale", 1)
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond)
+				updatedBefore := time.Now()
+
+				_, err = store.SaveGaugeMetric(ctx, "fresh", 1)
+				require.NoError(t, err)
+
+				expired, err := store.ExpireMetrics(ctx, constants.MetricTypeGauge, updatedBefore)
+				require.NoError(t, err)
+				assert.Equal(t, []string{`stale{host="web01"}`}, expired)
+
+				metrics, err := store.GetAllMetrics(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]float64{"fresh": 1}, metrics.Gauge)
+				assert.Equal(t, map[string]int64{"stale": 1}, metrics.Counter)
+			},
+		},
+		{
+			name: "should apply parallel updates",
+			tBody: func(store *storage.SQLiteStorage) {
+				const writers, iterations = 4, 25
+
+				var wg sync.WaitGroup
+
+				for i := 0; i < writers; i++ {
+					wg.Add(1)
+
+					go func() {
+						defer wg.Done()
+
+						delta := int64(1)
+
+						for j := 0; j < iterations; j++ {
+							_, err := store.SaveMetricBatch(ctx, []entities.Metrics{
+								{ID: "PollCount", MType: constants.MetricTypeCounter, Delta: &delta},
+							})
+							assert.NoError(t, err)
+						}
+					}()
+				}
+
+				wg.Wait()
+
+				val, err := store.GetCounterMetric(ctx, "PollCount")
+				require.NoError(t, err)
+				assert.Equal(t, int64(writers*iterations), val)
+			},
+		},
+		{
+			name: "should upsert rollups and delete expired history",
+			tBody: func(store *storage.SQLiteStorage) {
+				bucket := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+				err := store.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket, Min: 1, Max: 1, Sum: 1, Count: 1},
+					{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 2, Sum: 2, Count: 1},
+				})
+				require.NoError(t, err)
+
+				err = store.SaveRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, []entities.Rollup{
+					{Timestamp: bucket, Min: 1, Max: 3, Sum: 4, Count: 2},
+				})
+				require.NoError(t, err)
+
+				rollups, err := store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, bucket, bucket.Add(time.Hour))
+				require.NoError(t, err)
+				require.Len(t, rollups, 2)
+				assert.Equal(t, entities.Rollup{Timestamp: bucket, Min: 1, Max: 3, Sum: 4, Count: 2}, withUTC(rollups[0]))
+
+				err = store.DeleteHistory(ctx, time.Minute, bucket.Add(time.Minute))
+				require.NoError(t, err)
+
+				rollups, err = store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, bucket, bucket.Add(time.Hour))
+				require.NoError(t, err)
+				require.Len(t, rollups, 1)
+				assert.Equal(t, entities.Rollup{Timestamp: bucket.Add(time.Minute), Min: 2, Max: 2, Sum: 2, Count: 1}, withUTC(rollups[0]))
+
+				_, err = store.SaveGaugeMetric(ctx, "temp", 1)
+				require.NoError(t, err)
+
+				err = store.DeleteHistory(ctx, 0, time.Now().Add(time.Second))
+				require.NoError(t, err)
+
+				samples, err := store.GetMetricHistory(ctx, constants.MetricTypeGauge, "temp", time.Time{}, time.Now().Add(time.Second))
+				require.NoError(t, err)
+				assert.Empty(t, samples)
+
+				err = store.DeleteMetric(ctx, constants.MetricTypeGauge, "temp")
+				require.NoError(t, err)
+
+				rollups, err = store.GetRollups(ctx, constants.MetricTypeGauge, "temp", time.Minute, bucket, bucket.Add(time.Hour))
+				require.NoError(t, err)
+				assert.Empty(t, rollups)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "metrics.db"))
+			defer store.Close(ctx)
+
+			tt.tBody(store)
+		})
+	}
+}
+
+func TestSQLiteStorage_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.db")
+
+	store := newTestSQLiteStorage(t, path)
+
+	_, err := store.SaveCounterMetric(ctx, "PollCount", 3)
+	require.NoError(t, err)
+
+	err = store.Close(ctx)
+	require.NoError(t, err)
+
+	reopened := newTestSQLiteStorage(t, path)
+	defer reopened.Close(ctx)
+
+	val, err := reopened.GetCounterMetric(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), val)
+}
+
+func withUTC(rollup entities.Rollup) entities.Rollup {
+	rollup.Timestamp = rollup.Timestamp.UTC()
+	return rollup
+}
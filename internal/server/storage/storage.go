@@ -3,7 +3,9 @@ package storage
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/sodiqit/metricpulse.git/internal/constants"
 	"github.com/sodiqit/metricpulse.git/internal/entities"
 	"github.com/sodiqit/metricpulse.git/pkg/retry"
 )
@@ -33,13 +35,82 @@ func NewErrNotFound(err error, args map[string]interface{}) error {
 	}
 }
 
+// ErrBatchItem reports the batch item which was rejected. Batches are saved all-or-nothing,
+// so none of the batch items is saved
+type ErrBatchItem struct {
+	Index int
+	err   error
+}
+
+func (e *ErrBatchItem) Error() string {
+	return fmt.Sprintf("batch item %d rejected: %s", e.Index, e.err)
+}
+
+func (e *ErrBatchItem) Unwrap() error {
+	return e.err
+}
+
+func NewErrBatchItem(index int, err error) error {
+	return &ErrBatchItem{
+		index,
+		err,
+	}
+}
+
+// validateBatch keeps storages from saving metrics without value or of unknown type
+func validateBatch(metrics []entities.Metrics) error {
+	for i, metric := range metrics {
+		if err := metric.Validate(); err != nil {
+			return NewErrBatchItem(i, err)
+		}
+	}
+
+	return nil
+}
+
+// batchResult returns batch item with value of the series after the update
+func batchResult(metric entities.Metrics, sample entities.MetricSample) entities.Metrics {
+	result := entities.Metrics{ID: metric.ID, MType: metric.MType, Labels: metric.Labels}
+
+	switch metric.MType {
+	case constants.MetricTypeGauge:
+		result.Value = &sample.Gauge
+	case constants.MetricTypeCounter:
+		result.Delta = &sample.Counter
+	case constants.MetricTypeHistogram:
+		h := sample.Histogram.Copy()
+		result.Histogram = &h
+	}
+
+	return result
+}
+
 type Storage interface {
 	SaveGaugeMetric(ctx context.Context, metricType string, value float64) (float64, error)
 	SaveCounterMetric(ctx context.Context, metricType string, value int64) (int64, error)
+	// AddGaugeMetric adds delta to the gauge atomically and returns the result, missing gauge starts from zero
+	AddGaugeMetric(ctx context.Context, metricType string, delta float64) (float64, error)
 	GetCounterMetric(ctx context.Context, metricType string) (int64, error)
 	GetGaugeMetric(ctx context.Context, metricType string) (float64, error)
+	SaveHistogramMetric(ctx context.Context, metricType string, value entities.Histogram) (entities.Histogram, error)
+	GetHistogramMetric(ctx context.Context, metricType string) (entities.Histogram, error)
 	GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error)
-	SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) error
+	GetMetricHistory(ctx context.Context, metricType string, metricName string, from time.Time, to time.Time) ([]entities.MetricSample, error)
+	// SaveMetricBatch saves all metrics or none of them and returns resulting value of every item in batch order.
+	// Rejected item is reported with ErrBatchItem
+	SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) ([]entities.Metrics, error)
+	// DeleteMetric removes the series with its history, returns ErrNotFound if series doesn't exist
+	DeleteMetric(ctx context.Context, metricType string, metricName string) error
+	// DeleteMetricBatch removes listed series with their history, missing series are skipped
+	DeleteMetricBatch(ctx context.Context, metrics []entities.Metrics) error
+	// ExpireMetrics removes series of metricType not updated since updatedBefore and returns their ids
+	ExpireMetrics(ctx context.Context, metricType string, updatedBefore time.Time) ([]string, error)
+	// GetRollups returns rollups of the series with given resolution which start in [from, to]
+	GetRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, from time.Time, to time.Time) ([]entities.Rollup, error)
+	// SaveRollups saves rollups of the series, rollup with the same resolution and start is replaced
+	SaveRollups(ctx context.Context, metricType string, metricName string, resolution time.Duration, rollups []entities.Rollup) error
+	// DeleteHistory removes samples (resolution 0) or rollups of given resolution older than before for all series
+	DeleteHistory(ctx context.Context, resolution time.Duration, before time.Time) error
 	Init(context.Context, retry.Backoff) error
 	Ping(context.Context) error
 	Close(context.Context) error
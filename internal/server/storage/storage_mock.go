@@ -12,6 +12,7 @@ package storage
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	entities "github.com/sodiqit/metricpulse.git/internal/entities"
 	retry "github.com/sodiqit/metricpulse.git/pkg/retry"
@@ -41,6 +42,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AddGaugeMetric mocks base method.
+func (m *MockStorage) AddGaugeMetric(ctx context.Context, metricType string, delta float64) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddGaugeMetric", ctx, metricType, delta)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddGaugeMetric indicates an expected call of AddGaugeMetric.
+func (mr *MockStorageMockRecorder) AddGaugeMetric(ctx, metricType, delta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddGaugeMetric", reflect.TypeOf((*MockStorage)(nil).AddGaugeMetric), ctx, metricType, delta)
+}
+
 // Close mocks base method.
 func (m *MockStorage) Close(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -55,6 +71,63 @@ func (mr *MockStorageMockRecorder) Close(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close), arg0)
 }
 
+// DeleteHistory mocks base method.
+func (m *MockStorage) DeleteHistory(ctx context.Context, resolution time.Duration, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteHistory", ctx, resolution, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteHistory indicates an expected call of DeleteHistory.
+func (mr *MockStorageMockRecorder) DeleteHistory(ctx, resolution, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteHistory", reflect.TypeOf((*MockStorage)(nil).DeleteHistory), ctx, resolution, before)
+}
+
+// DeleteMetric mocks base method.
+func (m *MockStorage) DeleteMetric(ctx context.Context, metricType, metricName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMetric", ctx, metricType, metricName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMetric indicates an expected call of DeleteMetric.
+func (mr *MockStorageMockRecorder) DeleteMetric(ctx, metricType, metricName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMetric", reflect.TypeOf((*MockStorage)(nil).DeleteMetric), ctx, metricType, metricName)
+}
+
+// DeleteMetricBatch mocks base method.
+func (m *MockStorage) DeleteMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMetricBatch", ctx, metrics)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMetricBatch indicates an expected call of DeleteMetricBatch.
+func (mr *MockStorageMockRecorder) DeleteMetricBatch(ctx, metrics any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMetricBatch", reflect.TypeOf((*MockStorage)(nil).DeleteMetricBatch), ctx, metrics)
+}
+
+// ExpireMetrics mocks base method.
+func (m *MockStorage) ExpireMetrics(ctx context.Context, metricType string, updatedBefore time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireMetrics", ctx, metricType, updatedBefore)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireMetrics indicates an expected call of ExpireMetrics.
+func (mr *MockStorageMockRecorder) ExpireMetrics(ctx, metricType, updatedBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireMetrics", reflect.TypeOf((*MockStorage)(nil).ExpireMetrics), ctx, metricType, updatedBefore)
+}
+
 // GetAllMetrics mocks base method.
 func (m *MockStorage) GetAllMetrics(ctx context.Context) (entities.TotalMetrics, error) {
 	m.ctrl.T.Helper()
@@ -100,6 +173,51 @@ func (mr *MockStorageMockRecorder) GetGaugeMetric(ctx, metricType any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGaugeMetric", reflect.TypeOf((*MockStorage)(nil).GetGaugeMetric), ctx, metricType)
 }
 
+// GetHistogramMetric mocks base method.
+func (m *MockStorage) GetHistogramMetric(ctx context.Context, metricType string) (entities.Histogram, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistogramMetric", ctx, metricType)
+	ret0, _ := ret[0].(entities.Histogram)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistogramMetric indicates an expected call of GetHistogramMetric.
+func (mr *MockStorageMockRecorder) GetHistogramMetric(ctx, metricType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistogramMetric", reflect.TypeOf((*MockStorage)(nil).GetHistogramMetric), ctx, metricType)
+}
+
+// GetMetricHistory mocks base method.
+func (m *MockStorage) GetMetricHistory(ctx context.Context, metricType, metricName string, from, to time.Time) ([]entities.MetricSample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetricHistory", ctx, metricType, metricName, from, to)
+	ret0, _ := ret[0].([]entities.MetricSample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMetricHistory indicates an expected call of GetMetricHistory.
+func (mr *MockStorageMockRecorder) GetMetricHistory(ctx, metricType, metricName, from, to any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetricHistory", reflect.TypeOf((*MockStorage)(nil).GetMetricHistory), ctx, metricType, metricName, from, to)
+}
+
+// GetRollups mocks base method.
+func (m *MockStorage) GetRollups(ctx context.Context, metricType, metricName string, resolution time.Duration, from, to time.Time) ([]entities.Rollup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollups", ctx, metricType, metricName, resolution, from, to)
+	ret0, _ := ret[0].([]entities.Rollup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRollups indicates an expected call of GetRollups.
+func (mr *MockStorageMockRecorder) GetRollups(ctx, metricType, metricName, resolution, from, to any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollups", reflect.TypeOf((*MockStorage)(nil).GetRollups), ctx, metricType, metricName, resolution, from, to)
+}
+
 // Init mocks base method.
 func (m *MockStorage) Init(arg0 context.Context, arg1 retry.Backoff) error {
 	m.ctrl.T.Helper()
@@ -158,12 +276,28 @@ func (mr *MockStorageMockRecorder) SaveGaugeMetric(ctx, metricType, value any) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveGaugeMetric", reflect.TypeOf((*MockStorage)(nil).SaveGaugeMetric), ctx, metricType, value)
 }
 
+// SaveHistogramMetric mocks base method.
+func (m *MockStorage) SaveHistogramMetric(ctx context.Context, metricType string, value entities.Histogram) (entities.Histogram, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveHistogramMetric", ctx, metricType, value)
+	ret0, _ := ret[0].(entities.Histogram)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveHistogramMetric indicates an expected call of SaveHistogramMetric.
+func (mr *MockStorageMockRecorder) SaveHistogramMetric(ctx, metricType, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveHistogramMetric", reflect.TypeOf((*MockStorage)(nil).SaveHistogramMetric), ctx, metricType, value)
+}
+
 // SaveMetricBatch mocks base method.
-func (m *MockStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) error {
+func (m *MockStorage) SaveMetricBatch(ctx context.Context, metrics []entities.Metrics) ([]entities.Metrics, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SaveMetricBatch", ctx, metrics)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].([]entities.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SaveMetricBatch indicates an expected call of SaveMetricBatch.
@@ -171,3 +305,17 @@ func (mr *MockStorageMockRecorder) SaveMetricBatch(ctx, metrics any) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMetricBatch", reflect.TypeOf((*MockStorage)(nil).SaveMetricBatch), ctx, metrics)
 }
+
+// SaveRollups mocks base method.
+func (m *MockStorage) SaveRollups(ctx context.Context, metricType, metricName string, resolution time.Duration, rollups []entities.Rollup) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRollups", ctx, metricType, metricName, resolution, rollups)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRollups indicates an expected call of SaveRollups.
+func (mr *MockStorageMockRecorder) SaveRollups(ctx, metricType, metricName, resolution, rollups any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRollups", reflect.TypeOf((*MockStorage)(nil).SaveRollups), ctx, metricType, metricName, resolution, rollups)
+}
@@ -0,0 +1,182 @@
+// Package encryption implements hybrid encryption of payloads with RSA or X25519 public key.
+// Every payload is encrypted with new AES-256-GCM key, the key is transferred encrypted with
+// RSA-OAEP or derived from X25519 key agreement with ephemeral key.
+//
+// Keys are read from PEM files, e.g. generated by openssl:
+//
+//	openssl genpkey -algorithm X25519 -out private.pem
+//	openssl pkey -in private.pem -pubout -out public.pem
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	SchemeRSA    = "rsa-oaep-aes256gcm"
+	SchemeX25519 = "x25519-aes256gcm"
+)
+
+const keySize = 32
+
+var ErrInvalidPayload = errors.New("invalid encrypted payload")
+
+type Encryptor interface {
+	// Scheme returns name of the scheme, decryptor must use the same one
+	Scheme() string
+	Encrypt(data []byte) ([]byte, error)
+}
+
+type Decryptor interface {
+	Scheme() string
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// LoadEncryptor reads public key in PEM file: PKIX RSA or X25519 key or PKCS1 RSA key
+func LoadEncryptor(path string) (Encryptor, error) {
+	block, err := readPEM(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q, expected public key", path, block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return NewRSAEncryptor(key), nil
+	case *ecdh.PublicKey:
+		if key.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("%s: unsupported curve, expected X25519", path)
+		}
+
+		return NewX25519Encryptor(key), nil
+	}
+
+	return nil, fmt.Errorf("%s: unsupported public key %T, expected RSA or X25519", path, key)
+}
+
+// LoadDecryptor reads private key in PEM file: PKCS8 RSA or X25519 key or PKCS1 RSA key
+func LoadDecryptor(path string) (Decryptor, error) {
+	block, err := readPEM(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q, expected private key", path, block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return NewRSADecryptor(key), nil
+	case *ecdh.PrivateKey:
+		if key.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("%s: unsupported curve, expected X25519", path)
+		}
+
+		return NewX25519Decryptor(key), nil
+	}
+
+	return nil, fmt.Errorf("%s: unsupported private key %T, expected RSA or X25519", path, key)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, fmt.Errorf("%s: PEM block not found", path)
+	}
+
+	return block, nil
+}
+
+// seal encrypts data with AES-256-GCM, nonce is prepended to ciphertext.
+// Additional data binds ciphertext to the encrypted key
+func seal(key []byte, data []byte, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, additional), nil
+}
+
+func open(key []byte, data []byte, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, ErrInvalidPayload
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	plain, err := aead.Open(nil, nonce, ciphertext, additional)
+
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
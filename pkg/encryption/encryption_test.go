@@ -0,0 +1,126 @@
+package encryption_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sodiqit/metricpulse.git/pkg/encryption"
+)
+
+// writeKeys writes private and public key in PEM files as openssl does
+func writeKeys(t *testing.T, private interface{}, public interface{}) (string, string) {
+	dir := t.TempDir()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+
+	err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600)
+	require.NoError(t, err)
+
+	err = os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644)
+	require.NoError(t, err)
+
+	return privatePath, publicPath
+}
+
+func TestEncryption(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	otherKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	payload := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+
+	tests := []struct {
+		name   string
+		scheme string
+		keys   func() (string, string)
+	}{
+		{
+			name:   "rsa",
+			scheme: encryption.SchemeRSA,
+			keys: func() (string, string) {
+				return writeKeys(t, rsaKey, &rsaKey.PublicKey)
+			},
+		},
+		{
+			name:   "x25519",
+			scheme: encryption.SchemeX25519,
+			keys: func() (string, string) {
+				return writeKeys(t, x25519Key, x25519Key.PublicKey())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privatePath, publicPath := tt.keys()
+
+			encryptor, err := encryption.LoadEncryptor(publicPath)
+			require.NoError(t, err)
+
+			decryptor, err := encryption.LoadDecryptor(privatePath)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.scheme, encryptor.Scheme())
+			assert.Equal(t, tt.scheme, decryptor.Scheme())
+
+			encrypted, err := encryptor.Encrypt(payload)
+			require.NoError(t, err)
+			assert.NotContains(t, string(encrypted), "PollCount")
+
+			// every payload is encrypted with its own key
+			again, err := encryptor.Encrypt(payload)
+			require.NoError(t, err)
+			assert.NotEqual(t, encrypted, again)
+
+			decrypted, err := decryptor.Decrypt(encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, payload, decrypted)
+
+			for _, i := range []int{0, len(encrypted) / 2, len(encrypted) - 1} {
+				tampered := append([]byte(nil), encrypted...)
+				tampered[i] ^= 1
+
+				_, err = decryptor.Decrypt(tampered)
+				assert.ErrorIs(t, err, encryption.ErrInvalidPayload)
+			}
+
+			_, err = decryptor.Decrypt(encrypted[:10])
+			assert.ErrorIs(t, err, encryption.ErrInvalidPayload)
+		})
+	}
+
+	t.Run("wrong private key", func(t *testing.T) {
+		encrypted, err := encryption.NewX25519Encryptor(x25519Key.PublicKey()).Encrypt(payload)
+		require.NoError(t, err)
+
+		_, err = encryption.NewX25519Decryptor(otherKey).Decrypt(encrypted)
+		assert.ErrorIs(t, err, encryption.ErrInvalidPayload)
+	})
+
+	t.Run("private key instead of public one", func(t *testing.T) {
+		privatePath, _ := writeKeys(t, x25519Key, x25519Key.PublicKey())
+
+		_, err := encryption.LoadEncryptor(privatePath)
+		assert.Error(t, err)
+	})
+}
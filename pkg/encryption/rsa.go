@@ -0,0 +1,81 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// RSA payload: 2 bytes length of encrypted key, RSA-OAEP encrypted key, nonce and AES-GCM ciphertext
+
+type RSAEncryptor struct {
+	key *rsa.PublicKey
+}
+
+func (e *RSAEncryptor) Scheme() string {
+	return SchemeRSA
+}
+
+func (e *RSAEncryptor) Encrypt(data []byte) ([]byte, error) {
+	key := make([]byte, keySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, e.key, key, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := binary.BigEndian.AppendUint16(nil, uint16(len(encryptedKey)))
+	header = append(header, encryptedKey...)
+
+	ciphertext, err := seal(key, data, header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, ciphertext...), nil
+}
+
+func NewRSAEncryptor(key *rsa.PublicKey) *RSAEncryptor {
+	return &RSAEncryptor{key}
+}
+
+type RSADecryptor struct {
+	key *rsa.PrivateKey
+}
+
+func (d *RSADecryptor) Scheme() string {
+	return SchemeRSA
+}
+
+func (d *RSADecryptor) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, ErrInvalidPayload
+	}
+
+	size := int(binary.BigEndian.Uint16(data))
+
+	if len(data) < 2+size {
+		return nil, ErrInvalidPayload
+	}
+
+	header := data[:2+size]
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, d.key, header[2:], nil)
+
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidPayload
+	}
+
+	return open(key, data[len(header):], header)
+}
+
+func NewRSADecryptor(key *rsa.PrivateKey) *RSADecryptor {
+	return &RSADecryptor{key}
+}
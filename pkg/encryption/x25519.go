@@ -0,0 +1,111 @@
+package encryption
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// X25519 payload: ephemeral public key, nonce and AES-GCM ciphertext. The key is derived with HKDF-SHA256
+// from shared secret of ephemeral and recipient keys, both public keys are used as salt
+
+const x25519KeySize = 32
+
+var x25519Info = []byte("metricpulse payload encryption")
+
+type X25519Encryptor struct {
+	key *ecdh.PublicKey
+}
+
+func (e *X25519Encryptor) Scheme() string {
+	return SchemeX25519
+}
+
+func (e *X25519Encryptor) Encrypt(data []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := ephemeral.ECDH(e.key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := ephemeral.PublicKey().Bytes()
+
+	key, err := deriveKey(secret, header, e.key.Bytes())
+
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(key, data, header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header, ciphertext...), nil
+}
+
+func NewX25519Encryptor(key *ecdh.PublicKey) *X25519Encryptor {
+	return &X25519Encryptor{key}
+}
+
+type X25519Decryptor struct {
+	key *ecdh.PrivateKey
+}
+
+func (d *X25519Decryptor) Scheme() string {
+	return SchemeX25519
+}
+
+func (d *X25519Decryptor) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < x25519KeySize {
+		return nil, ErrInvalidPayload
+	}
+
+	header := data[:x25519KeySize]
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(header)
+
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	secret, err := d.key.ECDH(ephemeral)
+
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	key, err := deriveKey(secret, header, d.key.PublicKey().Bytes())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return open(key, data[x25519KeySize:], header)
+}
+
+func NewX25519Decryptor(key *ecdh.PrivateKey) *X25519Decryptor {
+	return &X25519Decryptor{key}
+}
+
+func deriveKey(secret []byte, ephemeral []byte, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte(nil), ephemeral...), recipient...)
+
+	key := make([]byte, keySize)
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, x25519Info), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
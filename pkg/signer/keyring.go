@@ -0,0 +1,206 @@
+package signer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyVerifier verifies signature made by any of several keys and reports id of the key
+type KeyVerifier interface {
+	VerifyKey(data []byte, signature string) (keyID string, ok bool)
+}
+
+// LegacyKeyID is reported for signatures without key id verified with legacy key
+const LegacyKeyID = "legacy"
+
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// Keyring signs data with primary key and verifies signatures made by any of its keys.
+// Signature carries id of the key: <id>:<hex digest>. Signature without id is verified with legacy key if it is set,
+// so agents which don't send key id are accepted during migration.
+//
+// Keyring loaded from file is reloaded when the file is changed, keys can be rolled without restart:
+// add new key, switch signers to it, then remove old key from the file.
+type Keyring struct {
+	path   string
+	legacy *Sha256Signer
+
+	mu      sync.Mutex
+	modTime time.Time
+	primary Key
+	keys    map[string]*Sha256Signer
+}
+
+func (k *Keyring) Sign(data []byte) string {
+	primary, keys := k.current()
+
+	return primary.ID + ":" + keys[primary.ID].Sign(data)
+}
+
+func (k *Keyring) Verify(data []byte, signature string) bool {
+	_, ok := k.VerifyKey(data, signature)
+
+	return ok
+}
+
+// VerifyKey returns id of the key which verified signature
+func (k *Keyring) VerifyKey(data []byte, signature string) (string, bool) {
+	id, digest, found := strings.Cut(signature, ":")
+
+	if !found {
+		if k.legacy == nil || !k.legacy.Verify(data, signature) {
+			return "", false
+		}
+
+		return LegacyKeyID, true
+	}
+
+	_, keys := k.current()
+
+	key, ok := keys[id]
+
+	if !ok || !key.Verify(data, digest) {
+		return "", false
+	}
+
+	return id, true
+}
+
+// WithLegacyKey sets key which verifies signatures without key id
+func (k *Keyring) WithLegacyKey(secret string) *Keyring {
+	k.legacy = NewSHA256Signer(secret)
+
+	return k
+}
+
+// current returns keys reloaded from file if it was changed. If file can't be read, previous keys are used
+func (k *Keyring) current() (Key, map[string]*Sha256Signer) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.path == "" {
+		return k.primary, k.keys
+	}
+
+	info, err := os.Stat(k.path)
+
+	if err != nil || info.ModTime().Equal(k.modTime) {
+		return k.primary, k.keys
+	}
+
+	keys, err := readKeys(k.path)
+
+	if err != nil {
+		return k.primary, k.keys
+	}
+
+	k.primary, k.keys, k.modTime = keys[0], signers(keys), info.ModTime()
+
+	return k.primary, k.keys
+}
+
+// NewKeyring returns keyring which signs with the first key
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if err := validateKeys(keys); err != nil {
+		return nil, err
+	}
+
+	return &Keyring{primary: keys[0], keys: signers(keys)}, nil
+}
+
+// LoadKeyring reads keyring file with one key per line in format id=secret, the first key is primary.
+// Empty lines and lines starting with # are skipped
+func LoadKeyring(path string) (*Keyring, error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := readKeys(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyring{path: path, modTime: info.ModTime(), primary: keys[0], keys: signers(keys)}, nil
+}
+
+func readKeys(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []Key
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		id, secret, found := strings.Cut(text, "=")
+
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected id=secret", path, line)
+		}
+
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: strings.TrimSpace(secret)})
+	}
+
+	if err := validateKeys(keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return keys, nil
+}
+
+func validateKeys(keys []Key) error {
+	if len(keys) == 0 {
+		return errors.New("keyring is empty")
+	}
+
+	seen := make(map[string]bool, len(keys))
+
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return fmt.Errorf("invalid key id %q: must be non-empty and must not contain ':'", key.ID)
+		}
+
+		if key.Secret == "" {
+			return fmt.Errorf("key %q has empty secret", key.ID)
+		}
+
+		if seen[key.ID] {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+
+		seen[key.ID] = true
+	}
+
+	return nil
+}
+
+func signers(keys []Key) map[string]*Sha256Signer {
+	result := make(map[string]*Sha256Signer, len(keys))
+
+	for _, key := range keys {
+		result[key.ID] = NewSHA256Signer(key.Secret)
+	}
+
+	return result
+}
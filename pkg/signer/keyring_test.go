@@ -0,0 +1,118 @@
+package signer_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/pkg/signer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyring(t *testing.T, path string, content string, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestKeyring(t *testing.T) {
+	data := []byte(`{"test": true}`)
+
+	keyring, err := signer.NewKeyring(signer.Key{ID: "k2", Secret: "new"}, signer.Key{ID: "k1", Secret: "old"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		signature string
+		keyID     string
+		ok        bool
+	}{
+		{name: "should sign with primary key", signature: keyring.Sign(data), keyID: "k2", ok: true},
+		{name: "should verify signature of not primary key", signature: "k1:" + signer.NewSHA256Signer("old").Sign(data), keyID: "k1", ok: true},
+		{name: "should reject signature of unknown key", signature: "k3:" + signer.NewSHA256Signer("old").Sign(data)},
+		{name: "should reject signature made by another key", signature: "k2:" + signer.NewSHA256Signer("old").Sign(data)},
+		{name: "should reject signature without key id if legacy key not set", signature: signer.NewSHA256Signer("old").Sign(data)},
+		{name: "should reject empty signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyID, ok := keyring.VerifyKey(data, tt.signature)
+
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.keyID, keyID)
+			assert.Equal(t, tt.ok, keyring.Verify(data, tt.signature))
+		})
+	}
+
+	t.Run("should verify signature without key id with legacy key", func(t *testing.T) {
+		keyring, err := signer.NewKeyring(signer.Key{ID: "k1", Secret: "new"})
+		require.NoError(t, err)
+
+		keyring.WithLegacyKey("legacy")
+
+		keyID, ok := keyring.VerifyKey(data, signer.NewSHA256Signer("legacy").Sign(data))
+		assert.True(t, ok)
+		assert.Equal(t, signer.LegacyKeyID, keyID)
+
+		_, ok = keyring.VerifyKey(data, signer.NewSHA256Signer("new").Sign(data))
+		assert.False(t, ok)
+	})
+
+	t.Run("should reject invalid keys", func(t *testing.T) {
+		for _, keys := range [][]signer.Key{
+			nil,
+			{{ID: "", Secret: "secret"}},
+			{{ID: "a:b", Secret: "secret"}},
+			{{ID: "k1", Secret: ""}},
+			{{ID: "k1", Secret: "a"}, {ID: "k1", Secret: "b"}},
+		} {
+			_, err := signer.NewKeyring(keys...)
+			assert.Error(t, err, keys)
+		}
+	})
+}
+
+func TestLoadKeyring(t *testing.T) {
+	data := []byte(`{"test": true}`)
+	path := filepath.Join(t.TempDir(), "keyring")
+	start := time.Now()
+
+	writeKeyring(t, path, "# primary key goes first\nk1 = old\n\n", start)
+
+	keyring, err := signer.LoadKeyring(path)
+	require.NoError(t, err)
+
+	oldSignature := keyring.Sign(data)
+	assert.True(t, strings.HasPrefix(oldSignature, "k1:"))
+
+	t.Run("should reload keyring when file changed", func(t *testing.T) {
+		writeKeyring(t, path, "k2=new\nk1=old\n", start.Add(time.Second))
+
+		assert.True(t, strings.HasPrefix(keyring.Sign(data), "k2:"))
+		assert.True(t, keyring.Verify(data, oldSignature))
+	})
+
+	t.Run("should keep previous keys if file is invalid", func(t *testing.T) {
+		writeKeyring(t, path, "k3\n", start.Add(2*time.Second))
+
+		assert.True(t, strings.HasPrefix(keyring.Sign(data), "k2:"))
+	})
+
+	t.Run("should reject removed key", func(t *testing.T) {
+		writeKeyring(t, path, "k2=new\n", start.Add(3*time.Second))
+
+		assert.False(t, keyring.Verify(data, oldSignature))
+	})
+
+	t.Run("should return error if file is invalid", func(t *testing.T) {
+		for _, content := range []string{"", "# comment only\n", "k1\n", "k1=a\nk1=b\n"} {
+			invalidPath := filepath.Join(t.TempDir(), "keyring")
+			writeKeyring(t, invalidPath, content, start)
+
+			_, err := signer.LoadKeyring(invalidPath)
+			assert.Error(t, err, content)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package signer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// MaxNonceLength bounds memory used by nonce cache of the server
+const MaxNonceLength = 64
+
+var ErrInvalidStamp = errors.New("invalid timestamp or nonce")
+
+// Stamp is timestamp and nonce of signed request. Signature covers them together with body,
+// so captured request can't be replayed after its timestamp gets stale or its nonce is seen
+type Stamp struct {
+	// Timestamp is unix time in seconds
+	Timestamp int64
+	Nonce     string
+}
+
+// NewStamp returns stamp with random nonce, every request is signed with new stamp
+func NewStamp(now time.Time) (Stamp, error) {
+	nonce := make([]byte, 16)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return Stamp{}, err
+	}
+
+	return Stamp{Timestamp: now.Unix(), Nonce: hex.EncodeToString(nonce)}, nil
+}
+
+// ParseStamp parses timestamp and nonce passed in request headers
+func ParseStamp(timestamp string, nonce string) (Stamp, error) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil || !validNonce(nonce) {
+		return Stamp{}, ErrInvalidStamp
+	}
+
+	return Stamp{Timestamp: ts, Nonce: nonce}, nil
+}
+
+// validNonce accepts only letters, digits, '-' and '_': separator in nonce would allow
+// to move bytes between nonce and body keeping the same signature
+func validNonce(nonce string) bool {
+	if nonce == "" || len(nonce) > MaxNonceLength {
+		return false
+	}
+
+	for _, c := range nonce {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s Stamp) TimestampString() string {
+	return strconv.FormatInt(s.Timestamp, 10)
+}
+
+// Material returns signed data: <timestamp>:<nonce>:<body>
+func (s Stamp) Material(body []byte) []byte {
+	result := make([]byte, 0, len(body)+len(s.Nonce)+22)
+	result = strconv.AppendInt(result, s.Timestamp, 10)
+	result = append(result, ':')
+	result = append(result, s.Nonce...)
+	result = append(result, ':')
+
+	return append(result, body...)
+}
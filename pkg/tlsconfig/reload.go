@@ -0,0 +1,83 @@
+package tlsconfig
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// reloadable holds value loaded from files and loads it again when any of files is changed
+type reloadable[T any] struct {
+	load   func(paths ...string) (T, error)
+	paths  []string
+	mu     sync.Mutex
+	stamps []fileStamp
+	value  T
+	loaded bool
+}
+
+func newReloadable[T any](load func(paths ...string) (T, error), paths ...string) *reloadable[T] {
+	return &reloadable[T]{load: load, paths: paths}
+}
+
+// get returns current value. Stamps are updated only after successful load,
+// so failed load is retried on the next call
+func (r *reloadable[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamps, err := statFiles(r.paths)
+
+	if err == nil && r.loaded && equalStamps(stamps, r.stamps) {
+		return r.value, nil
+	}
+
+	var value T
+
+	if err == nil {
+		value, err = r.load(r.paths...)
+	}
+
+	if err != nil {
+		if r.loaded {
+			return r.value, nil
+		}
+
+		return value, err
+	}
+
+	r.value, r.stamps, r.loaded = value, stamps, true
+
+	return value, nil
+}
+
+func statFiles(paths []string) ([]fileStamp, error) {
+	stamps := make([]fileStamp, 0, len(paths))
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		stamps = append(stamps, fileStamp{modTime: info.ModTime(), size: info.Size()})
+	}
+
+	return stamps, nil
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+// Package tlsconfig builds TLS configs from PEM files. Certificates are reloaded on handshake
+// when files are changed, so rotation does not require restart. If rotated files can't be
+// loaded, e.g. certificate is already written but key is not yet, previous certificate is used.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+type ServerOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is CA bundle for verifying client certificates. Clients without valid
+	// certificate are rejected if provided
+	ClientCAFile string
+}
+
+type ClientOptions struct {
+	// CAFile is CA bundle for verifying server certificate, system pool is used if empty.
+	// Bundle is read once on start
+	CAFile string
+	// CertFile and KeyFile are client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+}
+
+// NewServerConfig returns config which serves certificate from CertFile and KeyFile
+func NewServerConfig(options ServerOptions) (*tls.Config, error) {
+	cert := newReloadable(loadKeyPair, options.CertFile, options.KeyFile)
+
+	if _, err := cert.get(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// servers add h2 only to their own copy, per client configs are cloned from this one
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.get()
+		},
+	}
+
+	if options.ClientCAFile == "" {
+		return config, nil
+	}
+
+	clientCAs := newReloadable(loadCertPool, options.ClientCAFile)
+
+	if _, err := clientCAs.get(); err != nil {
+		return nil, err
+	}
+
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	// client CAs can be replaced only by config of the connection
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		pool, err := clientCAs.get()
+
+		if err != nil {
+			return nil, err
+		}
+
+		result := config.Clone()
+		result.ClientCAs = pool
+		result.GetConfigForClient = nil
+
+		return result, nil
+	}
+
+	return config, nil
+}
+
+// NewClientConfig returns config which verifies server with CAFile and presents client certificate if provided
+func NewClientConfig(options ClientOptions) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if options.CAFile != "" {
+		pool, err := loadCertPool(options.CAFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = pool
+	}
+
+	if options.CertFile == "" && options.KeyFile == "" {
+		return config, nil
+	}
+
+	cert := newReloadable(loadKeyPair, options.CertFile, options.KeyFile)
+
+	if _, err := cert.get(); err != nil {
+		return nil, err
+	}
+
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return cert.get()
+	}
+
+	return config, nil
+}
+
+func loadKeyPair(paths ...string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(paths[0], paths[1])
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot load certificate %s: %w", paths[0], err)
+	}
+
+	return &cert, nil
+}
+
+func loadCertPool(paths ...string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(paths[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", paths[0])
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,295 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sodiqit/metricpulse.git/pkg/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var writes int
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, der: der}
+}
+
+// issue writes certificate with serial signed by ca and its key into dir, returns paths of them
+func (ca *testCA) issue(t *testing.T, dir string, name string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "PRIVATE KEY", keyDER)
+
+	return certPath, keyPath
+}
+
+func (ca *testCA) write(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "ca.crt")
+	writePEM(t, path, "CERTIFICATE", ca.der)
+
+	return path
+}
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	require.NoError(t, err)
+
+	// files are rewritten in the same test, modification time must differ
+	writes++
+	modTime := time.Now().Add(time.Duration(writes) * time.Second)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// startServer returns url of https server. httptest server is not used: it sets own certificate
+func startServer(t *testing.T, config *tls.Config) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+
+	go server.Serve(tls.NewListener(lis, config))
+
+	t.Cleanup(func() { server.Close() })
+
+	return "https://" + lis.Addr().String()
+}
+
+// get returns serial of server certificate
+func get(t *testing.T, url string, config *tls.Config) (int64, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+
+	resp, err := client.Get(url)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestServerConfig_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile := ca.write(t, dir)
+
+	certFile, keyFile := ca.issue(t, dir, "server", 10)
+
+	serverConfig, err := tlsconfig.NewServerConfig(tlsconfig.ServerOptions{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	clientConfig, err := tlsconfig.NewClientConfig(tlsconfig.ClientOptions{CAFile: caFile})
+	require.NoError(t, err)
+
+	url := startServer(t, serverConfig)
+
+	serial, err := get(t, url, clientConfig)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), serial)
+
+	t.Run("should serve rotated certificate", func(t *testing.T) {
+		ca.issue(t, dir, "server", 11)
+
+		serial, err := get(t, url, clientConfig)
+		require.NoError(t, err)
+		assert.Equal(t, int64(11), serial)
+	})
+
+	t.Run("should serve previous certificate if rotated files are invalid", func(t *testing.T) {
+		writePEM(t, keyFile, "PRIVATE KEY", []byte("broken"))
+
+		serial, err := get(t, url, clientConfig)
+		require.NoError(t, err)
+		assert.Equal(t, int64(11), serial)
+
+		ca.issue(t, dir, "server", 12)
+
+		serial, err = get(t, url, clientConfig)
+		require.NoError(t, err)
+		assert.Equal(t, int64(12), serial)
+	})
+
+	t.Run("should reject server certificate of unknown ca", func(t *testing.T) {
+		otherDir := t.TempDir()
+		otherCAFile := newCA(t).write(t, otherDir)
+
+		config, err := tlsconfig.NewClientConfig(tlsconfig.ClientOptions{CAFile: otherCAFile})
+		require.NoError(t, err)
+
+		_, err = get(t, url, config)
+		assert.Error(t, err)
+	})
+}
+
+func TestServerConfig_ClientCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile := ca.write(t, dir)
+
+	certFile, keyFile := ca.issue(t, dir, "server", 10)
+	clientCertFile, clientKeyFile := ca.issue(t, dir, "client", 20)
+
+	serverConfig, err := tlsconfig.NewServerConfig(tlsconfig.ServerOptions{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+	})
+	require.NoError(t, err)
+
+	url := startServer(t, serverConfig)
+
+	otherDir := t.TempDir()
+	otherCertFile, otherKeyFile := newCA(t).issue(t, otherDir, "client", 30)
+
+	tests := []struct {
+		name        string
+		options     tlsconfig.ClientOptions
+		expectError bool
+	}{
+		{
+			name:    "should accept client certificate signed by client ca",
+			options: tlsconfig.ClientOptions{CAFile: caFile, CertFile: clientCertFile, KeyFile: clientKeyFile},
+		},
+		{
+			name:        "should reject client without certificate",
+			options:     tlsconfig.ClientOptions{CAFile: caFile},
+			expectError: true,
+		},
+		{
+			name:        "should reject client certificate of unknown ca",
+			options:     tlsconfig.ClientOptions{CAFile: caFile, CertFile: otherCertFile, KeyFile: otherKeyFile},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := tlsconfig.NewClientConfig(tc.options)
+			require.NoError(t, err)
+
+			_, err = get(t, url, config)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfig_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := newCA(t).issue(t, dir, "server", 10)
+
+	_, err := tlsconfig.NewServerConfig(tlsconfig.ServerOptions{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")})
+	assert.Error(t, err)
+
+	_, err = tlsconfig.NewClientConfig(tlsconfig.ClientOptions{CAFile: filepath.Join(dir, "server.key")})
+	assert.Error(t, err)
+}
+
+func TestServerConfig_NegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile := ca.write(t, dir)
+
+	certFile, keyFile := ca.issue(t, dir, "server", 10)
+	clientCertFile, clientKeyFile := ca.issue(t, dir, "client", 20)
+
+	for _, clientCAFile := range []string{"", caFile} {
+		serverConfig, err := tlsconfig.NewServerConfig(tlsconfig.ServerOptions{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: clientCAFile,
+		})
+		require.NoError(t, err)
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		// ServeTLS sets up http2 the same way as the metrics server
+		server := &http.Server{TLSConfig: serverConfig, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+
+		go server.ServeTLS(lis, "", "")
+
+		clientConfig, err := tlsconfig.NewClientConfig(tlsconfig.ClientOptions{CAFile: caFile, CertFile: clientCertFile, KeyFile: clientKeyFile})
+		require.NoError(t, err)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig, ForceAttemptHTTP2: true}}
+
+		resp, err := client.Get("https://" + lis.Addr().String())
+		require.NoError(t, err)
+
+		resp.Body.Close()
+		server.Close()
+
+		assert.Equal(t, "h2", resp.TLS.NegotiatedProtocol, "client ca %q", clientCAFile)
+		assert.Equal(t, 2, resp.ProtoMajor, "client ca %q", clientCAFile)
+	}
+}